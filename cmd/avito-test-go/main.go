@@ -1,7 +1,35 @@
 package main
 
-import "github.com/lov3allmy/avito-test-go/internal/infrastructure"
+import (
+	"errors"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/infrastructure"
+	"github.com/spf13/pflag"
+	"log"
+	"os"
+)
 
 func main() {
-	infrastructure.Run()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, pflag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		infrastructure.Migrate(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "keys" {
+		infrastructure.Keys(cfg, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "config" {
+		infrastructure.PrintConfig(cfg, args[1:])
+		return
+	}
+
+	infrastructure.Run(cfg)
 }
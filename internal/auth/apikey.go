@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"strings"
+)
+
+const (
+	apiKeyPrefix = "ak_"
+	// apiKeyPrefixLength is how much of the key, after apiKeyPrefix, is kept
+	// in the clear to tell keys apart.
+	apiKeyPrefixLength = 8
+)
+
+// HashAPIKey returns the hex encoded SHA-256 of the key, the form keys are
+// stored and looked up in. Keys are long random strings, so a fast hash is
+// enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueAPIKey creates a key for the client with the given scopes. The key is
+// returned only here; the repository keeps just its hash.
+func IssueAPIKey(ctx context.Context, repository domain.Repository, clientID string, scopes []string) (string, *domain.APIKey, error) {
+	if clientID == "" {
+		return "", nil, domain.ErrInvalidInput.WithMessage("client id must not be empty")
+	}
+	if len(scopes) == 0 {
+		return "", nil, domain.ErrInvalidInput.WithMessage("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return "", nil, domain.ErrInvalidInput.WithMessage(fmt.Sprintf("unknown scope %q, expected one of %s", scope, strings.Join(domain.Scopes, ", ")))
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	apiKey := &domain.APIKey{
+		ClientID: clientID,
+		Prefix:   key[:len(apiKeyPrefix)+apiKeyPrefixLength],
+		Hash:     HashAPIKey(key),
+		Scopes:   scopes,
+	}
+	if err := repository.CreateAPIKey(ctx, apiKey); err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+func isKnownScope(scope string) bool {
+	for _, known := range domain.Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+var hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims Claims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
+
+func validClaims() Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "shop",
+			Issuer:    "billing",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Scope: "balance:read transfer",
+	}
+}
+
+func TestAuthenticator_AuthenticateToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "someone else"
+
+	tests := []struct {
+		name           string
+		config         Config
+		token          string
+		expectedClient *domain.Client
+	}{
+		{
+			name:           "HS256",
+			config:         Config{HMACSecret: hmacSecret, Issuer: "billing"},
+			token:          signToken(t, jwt.SigningMethodHS256, hmacSecret, validClaims()),
+			expectedClient: &domain.Client{ID: "shop", Scopes: []string{domain.ScopeBalanceRead, domain.ScopeTransfer}},
+		},
+		{
+			name:           "RS256",
+			config:         Config{RSAPublicKey: &rsaKey.PublicKey},
+			token:          signToken(t, jwt.SigningMethodRS256, rsaKey, validClaims()),
+			expectedClient: &domain.Client{ID: "shop", Scopes: []string{domain.ScopeBalanceRead, domain.ScopeTransfer}},
+		},
+		{
+			name:   "Wrong HMAC secret",
+			config: Config{HMACSecret: hmacSecret},
+			token:  signToken(t, jwt.SigningMethodHS256, []byte("another secret"), validClaims()),
+		},
+		{
+			name:   "Wrong RSA key",
+			config: Config{RSAPublicKey: &rsaKey.PublicKey},
+			token:  signToken(t, jwt.SigningMethodRS256, otherRSAKey, validClaims()),
+		},
+		{
+			name:   "Method without a key",
+			config: Config{RSAPublicKey: &rsaKey.PublicKey},
+			token:  signToken(t, jwt.SigningMethodHS256, hmacSecret, validClaims()),
+		},
+		{
+			name:   "Unsigned",
+			config: Config{HMACSecret: hmacSecret},
+			token:  signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims()),
+		},
+		{
+			name:   "No keys configured",
+			config: Config{},
+			token:  signToken(t, jwt.SigningMethodHS256, []byte{}, validClaims()),
+		},
+		{
+			name:   "Expired",
+			config: Config{HMACSecret: hmacSecret},
+			token:  signToken(t, jwt.SigningMethodHS256, hmacSecret, expired),
+		},
+		{
+			name:   "No expiry",
+			config: Config{HMACSecret: hmacSecret},
+			token:  signToken(t, jwt.SigningMethodHS256, hmacSecret, noExpiry),
+		},
+		{
+			name:   "Wrong issuer",
+			config: Config{HMACSecret: hmacSecret, Issuer: "billing"},
+			token:  signToken(t, jwt.SigningMethodHS256, hmacSecret, wrongIssuer),
+		},
+		{
+			name:   "Malformed",
+			config: Config{HMACSecret: hmacSecret},
+			token:  "not a token",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := NewAuthenticator(nil, test.config).AuthenticateToken(context.Background(), test.token)
+			if test.expectedClient == nil {
+				assert.ErrorIs(t, err, domain.ErrUnauthorized)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedClient, client)
+		})
+	}
+}
+
+func TestAuthenticator_AuthenticateAPIKey(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+
+	revokedAt := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	repository.EXPECT().GetAPIKeyByHash(gomock.Any(), HashAPIKey("ak_valid")).Return(&domain.APIKey{ID: 1, ClientID: "shop", Scopes: []string{domain.ScopeBalanceRead}}, nil)
+	repository.EXPECT().GetAPIKeyByHash(gomock.Any(), HashAPIKey("ak_revoked")).Return(&domain.APIKey{ID: 2, ClientID: "shop", RevokedAt: &revokedAt}, nil)
+	repository.EXPECT().GetAPIKeyByHash(gomock.Any(), HashAPIKey("ak_unknown")).Return(nil, nil)
+
+	a := NewAuthenticator(repository, Config{})
+
+	client, err := a.AuthenticateAPIKey(context.Background(), "ak_valid")
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Client{ID: "shop", Scopes: []string{domain.ScopeBalanceRead}}, client)
+
+	_, err = a.AuthenticateAPIKey(context.Background(), "ak_revoked")
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+
+	_, err = a.AuthenticateAPIKey(context.Background(), "ak_unknown")
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+
+	_, err = a.AuthenticateAPIKey(context.Background(), "no prefix")
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
+
+func TestIssueAPIKey(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+
+	var stored *domain.APIKey
+	repository.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, apiKey *domain.APIKey) error {
+		stored = apiKey
+		apiKey.ID = 1
+		return nil
+	})
+
+	key, apiKey, err := IssueAPIKey(context.Background(), repository, "shop", []string{domain.ScopeBalanceWrite})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, apiKey.Prefix))
+	assert.Equal(t, HashAPIKey(key), stored.Hash)
+	assert.NotContains(t, stored.Hash, key)
+	assert.Equal(t, 1, apiKey.ID)
+
+	_, _, err = IssueAPIKey(context.Background(), repository, "shop", []string{"balance:everything"})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+
+	_, _, err = IssueAPIKey(context.Background(), repository, "", []string{domain.ScopeBalanceRead})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"os"
+	"strings"
+)
+
+// maxClientIDLength is the longest client id that fits the columns it is
+// recorded in.
+const maxClientIDLength = 255
+
+// Config holds the keys bearer tokens are verified with. Tokens signed with
+// HS256 need HMACSecret and tokens signed with RS256 need RSAPublicKey; when
+// neither is set only API keys are accepted. Issuer and Audience are checked
+// when set.
+type Config struct {
+	HMACSecret   []byte
+	RSAPublicKey *rsa.PublicKey
+	Issuer       string
+	Audience     string
+}
+
+// Claims are the claims of a bearer token: the client is the subject and its
+// scopes are a space separated "scope" claim, as in OAuth 2.0.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+type authenticator struct {
+	repository domain.Repository
+	config     Config
+	methods    []string
+	parser     *jwt.Parser
+}
+
+func NewAuthenticator(repository domain.Repository, config Config) domain.Authenticator {
+	var methods []string
+	if len(config.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if config.RSAPublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	return &authenticator{
+		repository: repository,
+		config:     config,
+		methods:    methods,
+		parser:     jwt.NewParser(jwt.WithValidMethods(methods)),
+	}
+}
+
+// LoadRSAPublicKey reads a PEM encoded RSA public key.
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseRSAPublicKeyFromPEM(data)
+}
+
+func (a *authenticator) AuthenticateAPIKey(ctx context.Context, key string) (*domain.Client, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, domain.ErrUnauthorized
+	}
+
+	apiKey, err := a.repository.GetAPIKeyByHash(ctx, HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil || apiKey.RevokedAt != nil {
+		return nil, domain.ErrUnauthorized
+	}
+
+	return &domain.Client{
+		ID:     apiKey.ClientID,
+		Scopes: apiKey.Scopes,
+	}, nil
+}
+
+func (a *authenticator) AuthenticateToken(_ context.Context, token string) (*domain.Client, error) {
+	// The parser accepts any method when given none, so without keys tokens
+	// must be refused here.
+	if len(a.methods) == 0 {
+		return nil, domain.ErrUnauthorized.WithMessage("bearer tokens are not accepted")
+	}
+
+	claims := &Claims{}
+	_, err := a.parser.ParseWithClaims(token, claims, a.key)
+	if err != nil {
+		return nil, domain.ErrUnauthorized.Wrap(err)
+	}
+
+	if claims.Subject == "" || len(claims.Subject) > maxClientIDLength {
+		return nil, domain.ErrUnauthorized.WithMessage(`token has no valid "sub" claim`)
+	}
+	if claims.ExpiresAt == nil {
+		return nil, domain.ErrUnauthorized.WithMessage(`token has no "exp" claim`)
+	}
+	if a.config.Issuer != "" && !claims.VerifyIssuer(a.config.Issuer, true) {
+		return nil, domain.ErrUnauthorized.WithMessage("token has a wrong issuer")
+	}
+	if a.config.Audience != "" && !claims.VerifyAudience(a.config.Audience, true) {
+		return nil, domain.ErrUnauthorized.WithMessage("token has a wrong audience")
+	}
+
+	return &domain.Client{
+		ID:     claims.Subject,
+		Scopes: strings.Fields(claims.Scope),
+	}, nil
+}
+
+// key picks the verification key by the signing method of the token. The
+// parser has already rejected methods without a configured key.
+func (a *authenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.config.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		return a.config.RSAPublicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the service. Keys in the config file,
+// environment variables and flags follow the mapstructure tags, e.g.
+// "db.max_open_conns" or AVITO_TEST_GO_DB_MAX_OPEN_CONNS.
+type Config struct {
+	Port            string         `mapstructure:"port" validate:"required,numeric"`
+	GRPCPort        string         `mapstructure:"grpc_port" validate:"required,numeric"`
+	ShutdownTimeout time.Duration  `mapstructure:"shutdown_timeout" validate:"gt=0"`
+	Log             Log            `mapstructure:"log"`
+	Metrics         Metrics        `mapstructure:"metrics"`
+	Tracing         Tracing        `mapstructure:"tracing"`
+	DB              DB             `mapstructure:"db"`
+	Health          Health         `mapstructure:"health"`
+	Rates           Rates          `mapstructure:"rates"`
+	Outbox          Outbox         `mapstructure:"outbox"`
+	Webhooks        Webhooks       `mapstructure:"webhooks"`
+	Auth            Auth           `mapstructure:"auth"`
+	RateLimit       RateLimit      `mapstructure:"rate_limit"`
+	SpendingLimits  SpendingLimits `mapstructure:"spending_limits"`
+
+	// settings are the effective settings the config was decoded from.
+	settings map[string]interface{}
+}
+
+type Log struct {
+	Level string `mapstructure:"level"`
+}
+
+type Metrics struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path" validate:"startswith=/"`
+}
+
+type Tracing struct {
+	Exporter    string  `mapstructure:"exporter" validate:"oneof=none stdout otlp"`
+	Endpoint    string  `mapstructure:"endpoint" validate:"required_if=Exporter otlp,omitempty,url"`
+	ServiceName string  `mapstructure:"service_name" validate:"required"`
+	SampleRatio float64 `mapstructure:"sample_ratio" validate:"min=0,max=1"`
+}
+
+// DB configures the Postgres connection pool. A zero MaxOpenConns means no
+// limit, zero lifetimes mean connections are reused forever.
+type DB struct {
+	Host              string        `mapstructure:"host" validate:"required"`
+	Port              string        `mapstructure:"port" validate:"required,numeric"`
+	User              string        `mapstructure:"user" validate:"required"`
+	Password          string        `mapstructure:"password"`
+	PasswordFile      string        `mapstructure:"password_file"`
+	Name              string        `mapstructure:"name" validate:"required"`
+	SSLMode           string        `mapstructure:"sslmode" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	ConnectTimeout    time.Duration `mapstructure:"connect_timeout" validate:"min=0"`
+	MaxOpenConns      int           `mapstructure:"max_open_conns" validate:"min=0"`
+	MaxIdleConns      int           `mapstructure:"max_idle_conns" validate:"min=0"`
+	ConnMaxLifetime   time.Duration `mapstructure:"conn_max_lifetime" validate:"min=0"`
+	ConnMaxIdleTime   time.Duration `mapstructure:"conn_max_idle_time" validate:"min=0"`
+	AutoMigrate       bool          `mapstructure:"auto_migrate"`
+	ConnectAttempts   int           `mapstructure:"connect_attempts" validate:"min=1"`
+	ConnectBackoff    time.Duration `mapstructure:"connect_backoff" validate:"gt=0"`
+	ConnectMaxBackoff time.Duration `mapstructure:"connect_max_backoff" validate:"gtefield=ConnectBackoff"`
+}
+
+// DSN returns the connection string lib/pq understands.
+func (db DB) DSN() string {
+	params := []string{
+		"host=" + quoteDSNValue(db.Host),
+		"port=" + quoteDSNValue(db.Port),
+		"user=" + quoteDSNValue(db.User),
+		"password=" + quoteDSNValue(db.Password),
+		"dbname=" + quoteDSNValue(db.Name),
+		"sslmode=" + quoteDSNValue(db.SSLMode),
+	}
+	if db.ConnectTimeout > 0 {
+		// Postgres takes whole seconds; a shorter timeout must not turn into
+		// none at all.
+		seconds := int((db.ConnectTimeout + time.Second - 1) / time.Second)
+		params = append(params, fmt.Sprintf("connect_timeout=%d", seconds))
+	}
+	return strings.Join(params, " ")
+}
+
+// quoteDSNValue quotes a value of a key=value connection string, so that
+// passwords with spaces or quotes survive.
+func quoteDSNValue(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return "'" + value + "'"
+}
+
+type Health struct {
+	Timeout time.Duration `mapstructure:"timeout" validate:"gt=0"`
+}
+
+type Rates struct {
+	Base string        `mapstructure:"base" validate:"len=3"`
+	URL  string        `mapstructure:"url" validate:"required_without=File,omitempty,url"`
+	File string        `mapstructure:"file"`
+	TTL  time.Duration `mapstructure:"ttl" validate:"min=0"`
+}
+
+type Outbox struct {
+	Publisher  string        `mapstructure:"publisher" validate:"oneof=stdout file http"`
+	File       string        `mapstructure:"file" validate:"required_if=Publisher file"`
+	URL        string        `mapstructure:"url" validate:"required_if=Publisher http,omitempty,url"`
+	Interval   time.Duration `mapstructure:"interval" validate:"gt=0"`
+	BatchSize  int           `mapstructure:"batch_size" validate:"min=1"`
+	Backoff    time.Duration `mapstructure:"backoff" validate:"gt=0"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff" validate:"gtefield=Backoff"`
+}
+
+type Webhooks struct {
+	Interval    time.Duration `mapstructure:"interval" validate:"gt=0"`
+	BatchSize   int           `mapstructure:"batch_size" validate:"min=1"`
+	Timeout     time.Duration `mapstructure:"timeout" validate:"gt=0"`
+	Backoff     time.Duration `mapstructure:"backoff" validate:"gt=0"`
+	MaxBackoff  time.Duration `mapstructure:"max_backoff" validate:"gtefield=Backoff"`
+	MaxAttempts int           `mapstructure:"max_attempts" validate:"min=1"`
+}
+
+type Auth struct {
+	JWTHMACSecret       string `mapstructure:"jwt_hmac_secret"`
+	JWTHMACSecretFile   string `mapstructure:"jwt_hmac_secret_file"`
+	JWTRSAPublicKeyFile string `mapstructure:"jwt_rsa_public_key_file"`
+	JWTIssuer           string `mapstructure:"jwt_issuer"`
+	JWTAudience         string `mapstructure:"jwt_audience"`
+}
+
+type RateLimit struct {
+	Enabled       bool           `mapstructure:"enabled"`
+	Store         string         `mapstructure:"store" validate:"oneof=memory postgres"`
+	SweepInterval time.Duration  `mapstructure:"sweep_interval" validate:"gt=0"`
+	Client        RateLimitClass `mapstructure:"client"`
+	User          RateLimitClass `mapstructure:"user"`
+}
+
+type RateLimitClass struct {
+	Read  RateLimitRule `mapstructure:"read"`
+	Write RateLimitRule `mapstructure:"write"`
+}
+
+type RateLimitRule struct {
+	Rate  float64 `mapstructure:"rate" validate:"gt=0"`
+	Burst int     `mapstructure:"burst" validate:"min=1"`
+}
+
+func (r RateLimitRule) RateLimit() domain.RateLimit {
+	return domain.RateLimit{
+		Rate:  r.Rate,
+		Burst: r.Burst,
+	}
+}
+
+// SpendingLimits are the global limits as decimal strings in the default
+// currency. An empty string turns the limit off.
+type SpendingLimits struct {
+	Daily       string `mapstructure:"daily"`
+	Monthly     string `mapstructure:"monthly"`
+	MaxTransfer string `mapstructure:"max_transfer"`
+}
+
+// Parse turns the amounts into money, failing on amounts that are not
+// positive.
+func (s SpendingLimits) Parse() (domain.SpendingLimits, error) {
+	limits := domain.SpendingLimits{}
+
+	for _, limit := range []struct {
+		key    string
+		value  string
+		amount **domain.Money
+	}{
+		{key: domain.SpendingLimitDaily, value: s.Daily, amount: &limits.Daily},
+		{key: domain.SpendingLimitMonthly, value: s.Monthly, amount: &limits.Monthly},
+		{key: domain.SpendingLimitMaxTransfer, value: s.MaxTransfer, amount: &limits.MaxTransfer},
+	} {
+		if limit.value == "" {
+			continue
+		}
+
+		amount, err := domain.ParseMoney(limit.value, domain.DefaultCurrency)
+		if err != nil || amount.MinorUnits <= 0 {
+			return domain.SpendingLimits{}, fmt.Errorf("spending_limits.%s must be a positive amount, got %q", limit.key, limit.value)
+		}
+		*limit.amount = &amount
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, args, err := Load([]string{"migrate", "up"})
+	require.NoError(t, err)
+
+	assert.Equal(t, args, []string{"migrate", "up"})
+	assert.Equal(t, cfg.Port, "8000")
+	assert.Equal(t, cfg.DB.Name, "avito_test_go")
+	assert.Equal(t, cfg.DB.SSLMode, "disable")
+	assert.Equal(t, cfg.DB.MaxOpenConns, 10)
+	assert.Equal(t, cfg.DB.ConnMaxLifetime, 3*time.Minute)
+	assert.Equal(t, cfg.RateLimit.User.Write.Burst, 5)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeFile(t, "main.yml", `
+port: "8080"
+grpc_port: "9090"
+db:
+  host: "db.internal"
+  max_open_conns: 20
+  conn_max_lifetime: "10m"
+`)
+	t.Setenv("AVITO_TEST_GO_GRPC_PORT", "9191")
+	t.Setenv("AVITO_TEST_GO_DB_MAX_OPEN_CONNS", "30")
+
+	cfg, _, err := Load([]string{"--config", file, "--port", "8181", "--set", "db.max_open_conns=40"})
+	require.NoError(t, err)
+
+	// Flags win over the environment, which wins over the file.
+	assert.Equal(t, cfg.Port, "8181")
+	assert.Equal(t, cfg.GRPCPort, "9191")
+	assert.Equal(t, cfg.DB.Host, "db.internal")
+	assert.Equal(t, cfg.DB.MaxOpenConns, 40)
+	assert.Equal(t, cfg.DB.ConnMaxLifetime, 10*time.Minute)
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	t.Setenv("AVITO_TEST_GO_DB_PASSWORD_FILE", writeFile(t, "password", "s3cret\n"))
+	t.Setenv("AVITO_TEST_GO_AUTH_JWT_HMAC_SECRET", "hmac")
+
+	cfg, _, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.DB.Password, "s3cret")
+	assert.Equal(t, cfg.Auth.JWTHMACSecret, "hmac")
+
+	redacted := cfg.Redacted()
+	assert.Equal(t, redacted["db"].(map[string]interface{})["password"], logging.Redacted)
+	assert.Equal(t, redacted["auth"].(map[string]interface{})["jwt_hmac_secret"], logging.Redacted)
+	assert.Equal(t, redacted["db"].(map[string]interface{})["name"], "avito_test_go")
+
+	t.Setenv("AVITO_TEST_GO_DB_PASSWORD", "qwerty")
+	_, _, err = Load(nil)
+	assert.EqualError(t, err, "db.password and db.password_file are both set")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "Missing config file",
+			args:          []string{"--config", filepath.Join(t.TempDir(), "missing.yml")},
+			expectedError: "reading config file",
+		},
+		{
+			name:          "Unknown setting",
+			args:          []string{"--set", "db.pool=5"},
+			expectedError: `unknown setting "db.pool"`,
+		},
+		{
+			name:          "Invalid value",
+			args:          []string{"--set", "db.sslmode=sometimes"},
+			expectedError: "invalid config: db.sslmode must be one of disable allow prefer require verify-ca verify-full",
+		},
+		{
+			name:          "Several invalid values",
+			args:          []string{"--set", "db.max_open_conns=-1", "--set", "tracing.sample_ratio=2"},
+			expectedError: "invalid config: tracing.sample_ratio must be at most 1; db.max_open_conns must be at least 0",
+		},
+		{
+			name:          "Required with another setting",
+			args:          []string{"--set", "outbox.publisher=http"},
+			expectedError: "invalid config: outbox.url is required when outbox.publisher is http",
+		},
+		{
+			name:          "Backoff out of order",
+			args:          []string{"--set", "db.connect_max_backoff=100ms"},
+			expectedError: "invalid config: db.connect_max_backoff must not be less than db.connect_backoff",
+		},
+		{
+			name:          "More idle than open connections",
+			args:          []string{"--set", "db.max_open_conns=5"},
+			expectedError: "invalid config: db.max_idle_conns must not exceed db.max_open_conns (5)",
+		},
+		{
+			name:          "Log level",
+			args:          []string{"--log-level", "loud"},
+			expectedError: "invalid config: log.level",
+		},
+		{
+			name:          "Spending limit",
+			args:          []string{"--set", "spending_limits.daily=-1"},
+			expectedError: `invalid config: spending_limits.daily must be a positive amount, got "-1"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := Load(test.args)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestDB_DSN(t *testing.T) {
+	db := DB{
+		Host:           "localhost",
+		Port:           "5432",
+		User:           "postgres",
+		Password:       `it's a \secret`,
+		Name:           "avito_test_go",
+		SSLMode:        "require",
+		ConnectTimeout: 1500 * time.Millisecond,
+	}
+	assert.Equal(t, db.DSN(), `host='localhost' port='5432' user='postgres' password='it\'s a \\secret' dbname='avito_test_go' sslmode='require' connect_timeout=2`)
+}
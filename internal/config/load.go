@@ -0,0 +1,289 @@
+package config
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix starts the names of environment variables overriding settings:
+// "db.password" is read from AVITO_TEST_GO_DB_PASSWORD.
+const EnvPrefix = "AVITO_TEST_GO"
+
+// DefaultFile is read when --config is not given. Unlike a file given
+// explicitly, it may be missing.
+const DefaultFile = "config/main.yml"
+
+// defaults lists every setting. Settings missing here are not read from the
+// environment.
+var defaults = map[string]interface{}{
+	"port":             "8000",
+	"grpc_port":        "9000",
+	"shutdown_timeout": "30s",
+
+	"log.level": "info",
+
+	"metrics.enabled": true,
+	"metrics.path":    "/metrics",
+
+	"tracing.exporter":     "none",
+	"tracing.endpoint":     "",
+	"tracing.service_name": "avito-test-go",
+	"tracing.sample_ratio": 1.0,
+
+	"db.host":                "localhost",
+	"db.port":                "5432",
+	"db.user":                "postgres",
+	"db.password":            "",
+	"db.password_file":       "",
+	"db.name":                "avito_test_go",
+	"db.sslmode":             "disable",
+	"db.connect_timeout":     "5s",
+	"db.max_open_conns":      10,
+	"db.max_idle_conns":      10,
+	"db.conn_max_lifetime":   "3m",
+	"db.conn_max_idle_time":  "0s",
+	"db.auto_migrate":        false,
+	"db.connect_attempts":    5,
+	"db.connect_backoff":     "1s",
+	"db.connect_max_backoff": "10s",
+
+	"health.timeout": "2s",
+
+	"rates.base": "RUB",
+	"rates.url":  "https://api.exchangerate.host/latest",
+	"rates.file": "",
+	"rates.ttl":  "1h",
+
+	"outbox.publisher":   "stdout",
+	"outbox.file":        "",
+	"outbox.url":         "",
+	"outbox.interval":    "1s",
+	"outbox.batch_size":  100,
+	"outbox.backoff":     "1s",
+	"outbox.max_backoff": "10m",
+
+	"webhooks.interval":     "1s",
+	"webhooks.batch_size":   100,
+	"webhooks.timeout":      "10s",
+	"webhooks.backoff":      "10s",
+	"webhooks.max_backoff":  "1h",
+	"webhooks.max_attempts": 10,
+
+	"auth.jwt_hmac_secret":         "",
+	"auth.jwt_hmac_secret_file":    "",
+	"auth.jwt_rsa_public_key_file": "",
+	"auth.jwt_issuer":              "",
+	"auth.jwt_audience":            "",
+
+	"rate_limit.enabled":            true,
+	"rate_limit.store":              "memory",
+	"rate_limit.sweep_interval":     "1m",
+	"rate_limit.client.read.rate":   50,
+	"rate_limit.client.read.burst":  100,
+	"rate_limit.client.write.rate":  10,
+	"rate_limit.client.write.burst": 20,
+	"rate_limit.user.read.rate":     5,
+	"rate_limit.user.read.burst":    20,
+	"rate_limit.user.write.rate":    1,
+	"rate_limit.user.write.burst":   5,
+
+	"spending_limits.daily":        "100000.00",
+	"spending_limits.monthly":      "1000000.00",
+	"spending_limits.max_transfer": "50000.00",
+}
+
+// secrets may be read from the file named by the setting with a "_file"
+// suffix, e.g. "db.password_file", instead of being set directly.
+var secrets = []string{"db.password", "auth.jwt_hmac_secret"}
+
+// Load builds the config from, in order of precedence, the flags in args,
+// the environment, the config file and the defaults, and validates it. It
+// returns the arguments left after the flags, i.e. the subcommand.
+func Load(args []string) (*Config, []string, error) {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	flags := pflag.NewFlagSet("avito-test-go", pflag.ContinueOnError)
+	flags.SetInterspersed(false)
+	file := flags.String("config", DefaultFile, "path to the config file")
+	flags.String("port", "", "port of the REST API")
+	flags.String("grpc-port", "", "port of the gRPC API")
+	flags.String("log-level", "", "log level: debug, info, warn or error")
+	overrides := flags.StringArray("set", nil, "override a setting, e.g. --set db.max_open_conns=20")
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	for key, flag := range map[string]string{"port": "port", "grpc_port": "grpc-port", "log.level": "log-level"} {
+		if err := v.BindPFlag(key, flags.Lookup(flag)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	v.SetConfigFile(*file)
+	if err := v.ReadInConfig(); err != nil {
+		if !os.IsNotExist(err) || flags.Changed("config") {
+			return nil, nil, fmt.Errorf("reading config file %s: %w", *file, err)
+		}
+	}
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	for _, override := range *overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			return nil, nil, fmt.Errorf("--set %s: want key=value", override)
+		}
+		key, value := strings.ToLower(parts[0]), parts[1]
+		if _, known := defaults[key]; !known {
+			return nil, nil, fmt.Errorf("--set %s: unknown setting %q", override, key)
+		}
+		v.Set(key, value)
+	}
+
+	for _, key := range secrets {
+		if err := readSecretFile(v, key); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cfg := &Config{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, nil, fmt.Errorf("decoding config: %w", err)
+	}
+	cfg.settings = v.AllSettings()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// readSecretFile sets the secret to the contents of its "_file" setting,
+// without the trailing newline editors and secret stores tend to add.
+func readSecretFile(v *viper.Viper, key string) error {
+	file := v.GetString(key + "_file")
+	if file == "" {
+		return nil
+	}
+	if v.GetString(key) != "" {
+		return fmt.Errorf("%s and %s_file are both set", key, key)
+	}
+
+	secret, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading %s_file: %w", key, err)
+	}
+	v.Set(key, strings.TrimRight(string(secret), "\r\n"))
+	return nil
+}
+
+// Validate checks the config, naming settings by their keys in errors.
+func (c *Config) Validate() error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("mapstructure")
+	})
+
+	if err := validate.Struct(c); err != nil {
+		fieldErrors, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return err
+		}
+		messages := make([]string, 0, len(fieldErrors))
+		for _, fieldError := range fieldErrors {
+			messages = append(messages, validationMessage(fieldError))
+		}
+		return fmt.Errorf("invalid config: %s", strings.Join(messages, "; "))
+	}
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		return fmt.Errorf("invalid config: log.level: %w", err)
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		return fmt.Errorf("invalid config: db.max_idle_conns must not exceed db.max_open_conns (%d)", c.DB.MaxOpenConns)
+	}
+	if _, err := c.SpendingLimits.Parse(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// validationMessage tells what is wrong with a setting, e.g.
+// "db.sslmode must be one of disable allow prefer require verify-ca verify-full".
+func validationMessage(fieldError validator.FieldError) string {
+	// The namespace starts with the name of the struct, which is not a key.
+	key := fieldError.Namespace()
+	if i := strings.Index(key, "."); i >= 0 {
+		key = key[i+1:]
+	}
+	// Other fields in params are named as in Go, they are keys of the same
+	// section.
+	section := ""
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		section = key[:i+1]
+	}
+
+	switch fieldError.Tag() {
+	case "required":
+		return key + " is required"
+	case "required_if":
+		params := strings.SplitN(fieldError.Param(), " ", 2)
+		return fmt.Sprintf("%s is required when %s%s is %s", key, section, snakeCase(params[0]), params[1])
+	case "required_without":
+		return fmt.Sprintf("%s is required when %s%s is not set", key, section, snakeCase(fieldError.Param()))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", key, fieldError.Param())
+	case "numeric":
+		return key + " must be a number"
+	case "url":
+		return key + " must be a URL"
+	case "startswith":
+		return fmt.Sprintf("%s must start with %q", key, fieldError.Param())
+	case "len":
+		return fmt.Sprintf("%s must be %s characters long", key, fieldError.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", key, fieldError.Param())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", key, fieldError.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", key, fieldError.Param())
+	case "gtefield":
+		return fmt.Sprintf("%s must not be less than %s%s", key, section, snakeCase(fieldError.Param()))
+	default:
+		return fmt.Sprintf("%s failed the %s check", key, fieldError.Tag())
+	}
+}
+
+// snakeCase turns a Go field name like ConnectBackoff into a key like
+// connect_backoff.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Redacted returns the effective settings with secrets masked, for logging
+// and "config print".
+func (c *Config) Redacted() map[string]interface{} {
+	return logging.RedactSettings(c.settings)
+}
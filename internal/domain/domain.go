@@ -1,38 +1,472 @@
 package domain
 
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
 //go:generate mockgen -source=domain.go -destination=../mocks/mock.go
 
+const (
+	TransactionTypeAdd      = "add"
+	TransactionTypeSubtract = "subtract"
+	TransactionTypeP2PIn    = "p2p_in"
+	TransactionTypeP2POut   = "p2p_out"
+	TransactionTypeReserve  = "reserve"
+	TransactionTypeCommit   = "commit"
+	TransactionTypeRelease  = "release"
+
+	ReservationStatusReserved  = "reserved"
+	ReservationStatusCommitted = "committed"
+	ReservationStatusReleased  = "released"
+
+	TransactionSortByDate   = "date"
+	TransactionSortByAmount = "amount"
+
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+
+	DefaultTransactionsLimit = 20
+	MaxTransactionsLimit     = 100
+
+	// MaxCommentLength is the length of transactions.comment in characters.
+	MaxCommentLength = 255
+
+	IdempotencyKeyTTL = 24 * time.Hour
+
+	EventTypeDeposit              = "balance.deposit"
+	EventTypeWithdrawal           = "balance.withdrawal"
+	EventTypeTransfer             = "balance.transfer"
+	EventTypeReservationCreated   = "balance.reservation.created"
+	EventTypeReservationCommitted = "balance.reservation.committed"
+	EventTypeReservationReleased  = "balance.reservation.released"
+
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	WebhookDeliveryStatusDead      = "dead"
+
+	DefaultWebhookAttemptsLimit = 20
+	MaxWebhookAttemptsLimit     = 100
+
+	ScopeBalanceRead    = "balance:read"
+	ScopeBalanceWrite   = "balance:write"
+	ScopeTransfer       = "transfer"
+	ScopeWebhooksManage = "webhooks:manage"
+	ScopeLimitsManage   = "limits:manage"
+
+	RateLimitRead  = "read"
+	RateLimitWrite = "write"
+
+	SpendingLimitDaily       = "daily"
+	SpendingLimitMonthly     = "monthly"
+	SpendingLimitMaxTransfer = "max_transfer"
+)
+
+// Scopes lists every scope a client can be granted.
+var Scopes = []string{ScopeBalanceRead, ScopeBalanceWrite, ScopeTransfer, ScopeWebhooksManage, ScopeLimitsManage}
+
 type User struct {
-	ID      int `json:"id" db:"id"`
-	Balance int `json:"balance" db:"balance"`
+	ID              int   `json:"id" db:"id"`
+	Balance         Money `json:"balance" db:"balance"`
+	ReservedBalance Money `json:"reserved_balance" db:"reserved_balance"`
+}
+
+type Transaction struct {
+	ID             int       `json:"id" db:"id"`
+	UserID         int       `json:"user_id" db:"user_id"`
+	Type           string    `json:"type" db:"type"`
+	Amount         Money     `json:"amount" db:"amount"`
+	CounterpartyID *int      `json:"counterparty_id,omitempty" db:"counterparty_id"`
+	ServiceID      *int      `json:"service_id,omitempty" db:"service_id"`
+	OrderID        *int      `json:"order_id,omitempty" db:"order_id"`
+	Comment        string    `json:"comment" db:"comment"`
+	ClientID       *string   `json:"client_id,omitempty" db:"client_id"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+type Reservation struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	ServiceID int       `json:"service_id" db:"service_id"`
+	OrderID   int       `json:"order_id" db:"order_id"`
+	Amount    Money     `json:"amount" db:"amount"`
+	Status    string    `json:"status" db:"status"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Matches reports whether the reservation was made for exactly the user,
+// service and amount given in the input.
+func (r *Reservation) Matches(input ReservationInput) bool {
+	return r.UserID == input.UserID &&
+		r.ServiceID == input.ServiceID &&
+		r.OrderID == input.OrderID &&
+		r.Amount == input.Amount
+}
+
+// IdempotencyKey stores the outcome of a request made with an
+// "Idempotency-Key" header. ResponseStatus is zero while the first request
+// with the key is still being processed.
+type IdempotencyKey struct {
+	Key            string    `json:"key" db:"key"`
+	RequestHash    string    `json:"request_hash" db:"request_hash"`
+	ResponseStatus int       `json:"response_status" db:"response_status"`
+	ResponseBody   []byte    `json:"response_body" db:"response_body"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// Event is a record in the outbox. It is written in the same database
+// transaction as the change it describes and published afterwards, possibly
+// more than once, so consumers should deduplicate events by ID.
+type Event struct {
+	ID            int64           `json:"id" db:"id"`
+	Type          string          `json:"type" db:"type"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Attempts      int             `json:"-" db:"attempts"`
+	LastError     *string         `json:"-" db:"last_error"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	NextAttemptAt time.Time       `json:"-" db:"next_attempt_at"`
+	PublishedAt   *time.Time      `json:"-" db:"published_at"`
+}
+
+// BalanceEvent is the payload of deposit and withdrawal events.
+type BalanceEvent struct {
+	UserID    int    `json:"user_id"`
+	Amount    Money  `json:"amount"`
+	ServiceID *int   `json:"service_id,omitempty"`
+	OrderID   *int   `json:"order_id,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+}
+
+// TransferEvent is the payload of transfer events.
+type TransferEvent struct {
+	FromUserID int    `json:"from_user_id"`
+	ToUserID   int    `json:"to_user_id"`
+	Amount     Money  `json:"amount"`
+	Comment    string `json:"comment,omitempty"`
+	ClientID   string `json:"client_id,omitempty"`
+}
+
+// ReservationEvent is the payload of reservation events.
+type ReservationEvent struct {
+	UserID    int    `json:"user_id"`
+	ServiceID int    `json:"service_id"`
+	OrderID   int    `json:"order_id"`
+	Amount    Money  `json:"amount"`
+	ClientID  string `json:"client_id,omitempty"`
+}
+
+// Webhook is a subscription to balance events. Events of the listed types are
+// POSTed to URL and signed with Secret; when UserID is set, only events about
+// that user are sent. Only the client that created the webhook can see and
+// change it.
+type Webhook struct {
+	ID         int       `json:"id" db:"id"`
+	ClientID   string    `json:"-" db:"client_id"`
+	URL        string    `json:"url" db:"url"`
+	EventTypes []string  `json:"event_types" db:"-"`
+	UserID     *int      `json:"user_id,omitempty" db:"user_id"`
+	Secret     string    `json:"-" db:"secret"`
+	Active     bool      `json:"active" db:"active"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookDelivery is an event queued for a single webhook. Body is the exact
+// JSON sent to the webhook; URL and Secret are those of the webhook at the
+// time the delivery was claimed.
+type WebhookDelivery struct {
+	ID        int64           `json:"id" db:"id"`
+	WebhookID int             `json:"webhook_id" db:"webhook_id"`
+	EventID   int64           `json:"event_id" db:"event_id"`
+	EventType string          `json:"event_type" db:"event_type"`
+	Body      json.RawMessage `json:"body" db:"body"`
+	Status    string          `json:"status" db:"status"`
+	Attempts  int             `json:"attempts" db:"attempts"`
+	URL       string          `json:"-" db:"url"`
+	Secret    string          `json:"-" db:"secret"`
+}
+
+// WebhookAttempt is one try to deliver an event to a webhook. StatusCode is
+// nil when no response was received at all.
+type WebhookAttempt struct {
+	ID             int64     `json:"id" db:"id"`
+	DeliveryID     int64     `json:"delivery_id" db:"delivery_id"`
+	EventID        int64     `json:"event_id" db:"event_id"`
+	EventType      string    `json:"event_type" db:"event_type"`
+	Attempt        int       `json:"attempt" db:"attempt"`
+	StatusCode     *int      `json:"status_code,omitempty" db:"status_code"`
+	Error          *string   `json:"error,omitempty" db:"error"`
+	DurationMS     int       `json:"duration_ms" db:"duration_ms"`
+	DeliveryStatus string    `json:"delivery_status" db:"delivery_status"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// Client is an authenticated caller of the API, identified either by an API
+// key or by the subject of a bearer token.
+type Client struct {
+	ID     string   `json:"id"`
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope reports whether the client was granted the scope.
+func (c *Client) HasScope(scope string) bool {
+	for _, granted := range c.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a credential issued to a client. Only the SHA-256 hash of the key
+// is stored; Prefix is kept in the clear so that keys can be told apart.
+type APIKey struct {
+	ID        int        `json:"id" db:"id"`
+	ClientID  string     `json:"client_id" db:"client_id"`
+	Prefix    string     `json:"prefix" db:"prefix"`
+	Hash      string     `json:"-" db:"key_hash"`
+	Scopes    []string   `json:"scopes" db:"-"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// RateLimit is a token bucket holding up to Burst requests and refilled with
+// Rate requests per second.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitBucket is the state of one token bucket.
+type RateLimitBucket struct {
+	Key       string    `db:"key"`
+	Tokens    float64   `db:"tokens"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// RateLimitResult describes a bucket after a request was counted against it.
+// RetryAfter is zero for allowed requests, ResetAfter is how long it takes
+// the bucket to fill up again.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// SpendingLimits cap the money leaving a user balance through withdrawals,
+// transfers and reservations: in total per UTC day and month, and per single
+// transfer. A
+// nil global limit is not enforced, a nil per user limit falls back to the
+// global one.
+type SpendingLimits struct {
+	Daily       *Money `json:"daily" db:"daily"`
+	Monthly     *Money `json:"monthly" db:"monthly"`
+	MaxTransfer *Money `json:"max_transfer" db:"max_transfer"`
+}
+
+// UserSpendingLimits are the limits set for one user and the limits that
+// apply to the user once the global ones are taken into account.
+type UserSpendingLimits struct {
+	UserID    int            `json:"user_id"`
+	Override  SpendingLimits `json:"override"`
+	Effective SpendingLimits `json:"effective"`
+}
+
+// OutgoingTotal returns the money that has left the balance of a user since
+// the given time.
+type OutgoingTotal func(since time.Time) (Money, error)
+
+// SpendingCheck decides whether money may leave the balance of a user. The
+// repository calls it with the balance row of the user locked, so the totals
+// it reads cannot change before the operation is committed.
+type SpendingCheck func(ctx context.Context, outgoingTotal OutgoingTotal) error
+
+// SpendingLimitViolation is returned in the details of
+// ErrSpendingLimitExceeded. Spent is the amount already spent in the period
+// of a daily or monthly limit.
+type SpendingLimitViolation struct {
+	Limit  string `json:"limit"`
+	Max    Money  `json:"max"`
+	Spent  *Money `json:"spent,omitempty"`
+	Amount Money  `json:"amount"`
 }
 
 type P2PInput struct {
-	FromUserID int `json:"from_user_id" validate:"required,min=0"`
-	ToUserID   int `json:"to_user_id" validate:"required,min=0,nefield=FromUserID"`
-	Amount     int `json:"amount" validate:"required,min=1"`
+	FromUserID int    `json:"from_user_id" validate:"required,min=0"`
+	ToUserID   int    `json:"to_user_id" validate:"required,min=0,nefield=FromUserID"`
+	Amount     Money  `json:"amount" validate:"required,min=1"`
+	Comment    string `json:"comment" validate:"max=255"`
+	ClientID   string `json:"-"`
+}
+
+type GetUserInput struct {
+	ID int `validate:"required,min=0"`
 }
 
 type GetBalanceInput struct {
-	ID int `json:"user_id" validate:"required,min=0"`
+	ID       int    `json:"user_id" validate:"required,min=0"`
+	Currency string `json:"-" validate:"omitempty,iso4217"`
 }
 
 type BalanceOperationInput struct {
+	UserID    int    `json:"user_id" validate:"required,min=0"`
+	Amount    Money  `json:"amount" validate:"required,min=1"`
+	Type      string `json:"type" validate:"required,oneof=add subtract"`
+	ServiceID int    `json:"service_id" validate:"omitempty,min=0"`
+	OrderID   int    `json:"order_id" validate:"omitempty,min=0"`
+	Comment   string `json:"comment" validate:"max=255"`
+	ClientID  string `json:"-"`
+}
+
+type ReservationInput struct {
+	UserID    int    `json:"user_id" validate:"required,min=0"`
+	ServiceID int    `json:"service_id" validate:"required,min=0"`
+	OrderID   int    `json:"order_id" validate:"required,min=0"`
+	Amount    Money  `json:"amount" validate:"required,min=1"`
+	ClientID  string `json:"-"`
+}
+
+type ServiceRevenue struct {
+	ServiceID int   `json:"service_id" db:"service_id"`
+	Revenue   Money `json:"revenue" db:"revenue"`
+}
+
+type RevenueReportInput struct {
+	Year  int `json:"year" query:"year" validate:"required,min=1970,max=9999"`
+	Month int `json:"month" query:"month" validate:"required,min=1,max=12"`
+}
+
+type TransactionFilter struct {
 	UserID int    `json:"user_id" validate:"required,min=0"`
-	Amount int    `json:"amount" validate:"required,min=1"`
-	Type   string `json:"type" validate:"required,oneof=add subtract"`
+	Limit  int    `json:"limit" query:"limit" validate:"min=0,max=100"`
+	Offset int    `json:"offset" query:"offset" validate:"min=0"`
+	SortBy string `json:"sort_by" query:"sort_by" validate:"omitempty,oneof=date amount"`
+	Order  string `json:"order" query:"order" validate:"omitempty,oneof=asc desc"`
+}
+
+type WebhookInput struct {
+	URL        string   `json:"url" validate:"required,url,max=2048"`
+	EventTypes []string `json:"event_types" validate:"required,min=1,dive,oneof=balance.deposit balance.withdrawal balance.transfer balance.reservation.created balance.reservation.committed balance.reservation.released"`
+	UserID     *int     `json:"user_id" validate:"omitempty,min=1"`
+	Secret     string   `json:"secret" validate:"omitempty,min=16,max=255"`
+	Active     *bool    `json:"active"`
+	ClientID   string   `json:"-"`
+}
+
+type SpendingLimitsInput struct {
+	UserID      int    `json:"-" validate:"required,min=0"`
+	Daily       *Money `json:"daily" validate:"omitempty,min=1"`
+	Monthly     *Money `json:"monthly" validate:"omitempty,min=1"`
+	MaxTransfer *Money `json:"max_transfer" validate:"omitempty,min=1"`
+}
+
+type WebhookAttemptFilter struct {
+	WebhookID int    `json:"webhook_id" validate:"required,min=0"`
+	Limit     int    `json:"limit" query:"limit" validate:"min=0,max=100"`
+	Offset    int    `json:"offset" query:"offset" validate:"min=0"`
+	ClientID  string `json:"-" query:"-"`
+}
+
+// RateProvider returns how many units of the given currency one unit of the
+// base currency is worth.
+type RateProvider interface {
+	GetRate(currency string) (float64, error)
+}
+
+// EventPublisher delivers outbox events to their consumers. An error means
+// the event was not delivered and will be published again later.
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Authenticator identifies the client behind an API key or a bearer token.
+// Unknown, revoked, expired or malformed credentials give ErrUnauthorized.
+type Authenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*Client, error)
+	AuthenticateToken(ctx context.Context, token string) (*Client, error)
+}
+
+// RateLimiter counts a request of the given class (RateLimitRead or
+// RateLimitWrite) against the limits of an API client or a user.
+type RateLimiter interface {
+	AllowClient(ctx context.Context, clientID, class string) (*RateLimitResult, error)
+	AllowUser(ctx context.Context, userID int, class string) (*RateLimitResult, error)
 }
 
 type Repository interface {
-	GetUser(userID int) (*User, error)
-	CreateUser(user *User) error
-	UpdateUser(userID int, user *User) error
-	MakeP2PTransfer(p2pInput P2PInput) error
+	GetUser(ctx context.Context, userID int) (*User, error)
+	CreateUser(ctx context.Context, user *User) error
+	UpdateUser(ctx context.Context, userID int, user *User) error
+	MakeBalanceOperation(ctx context.Context, input BalanceOperationInput, check SpendingCheck) error
+	MakeP2PTransfer(ctx context.Context, p2pInput P2PInput, check SpendingCheck) error
+	CreateTransaction(ctx context.Context, transaction *Transaction) error
+	GetTransactionsByUserID(ctx context.Context, userID int) ([]Transaction, error)
+	ListTransactions(ctx context.Context, filter TransactionFilter) ([]Transaction, error)
+	GetReservationByOrderID(ctx context.Context, orderID int) (*Reservation, error)
+	ReserveFunds(ctx context.Context, input ReservationInput, check SpendingCheck) error
+	CommitReservation(ctx context.Context, input ReservationInput) error
+	ReleaseReservation(ctx context.Context, input ReservationInput) error
+	GetRevenueByService(ctx context.Context, from, to time.Time) ([]ServiceRevenue, error)
+	GetIdempotencyKey(ctx context.Context, key string) (*IdempotencyKey, error)
+	CreateIdempotencyKey(ctx context.Context, idempotencyKey *IdempotencyKey) (bool, error)
+	UpdateIdempotencyKey(ctx context.Context, idempotencyKey *IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, key string) error
+	ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]Event, error)
+	MarkEventPublished(ctx context.Context, eventID int64) error
+	MarkEventFailed(ctx context.Context, eventID int64, nextAttemptAt time.Time, lastError string) error
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	GetWebhook(ctx context.Context, clientID string, webhookID int) (*Webhook, error)
+	ListWebhooks(ctx context.Context, clientID string) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *Webhook) error
+	DeleteWebhook(ctx context.Context, clientID string, webhookID int) error
+	CreateWebhookDeliveries(ctx context.Context, event Event, userIDs []int) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, attempt *WebhookAttempt, status string, nextAttemptAt time.Time) error
+	ListWebhookAttempts(ctx context.Context, filter WebhookAttemptFilter) ([]WebhookAttempt, error)
+	CreateAPIKey(ctx context.Context, apiKey *APIKey) error
+	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, apiKeyID int) error
+	UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *RateLimitBucket, now time.Time)) error
+	DeleteRateLimitBuckets(ctx context.Context, updatedBefore time.Time) error
+	GetSpendingLimits(ctx context.Context, userID int) (*SpendingLimits, error)
+	SetSpendingLimits(ctx context.Context, userID int, limits SpendingLimits) error
+	DeleteSpendingLimits(ctx context.Context, userID int) error
 }
 
 type Service interface {
-	GetUser(userID int) (*User, error)
-	CreateUser(user *User) error
-	UpdateUser(userID int, user *User) error
-	MakeP2PTransfer(p2pInput P2PInput) error
+	GetUser(ctx context.Context, userID int) (*User, error)
+	CreateUser(ctx context.Context, user *User) error
+	UpdateUser(ctx context.Context, userID int, user *User) error
+	GetBalance(ctx context.Context, input GetBalanceInput) (Money, error)
+	Deposit(ctx context.Context, input BalanceOperationInput) error
+	Withdraw(ctx context.Context, input BalanceOperationInput) error
+	Transfer(ctx context.Context, input P2PInput) error
+	CreateTransaction(ctx context.Context, transaction *Transaction) error
+	GetTransactionsByUserID(ctx context.Context, userID int) ([]Transaction, error)
+	ListTransactions(ctx context.Context, filter TransactionFilter) ([]Transaction, error)
+	GetReservationByOrderID(ctx context.Context, orderID int) (*Reservation, error)
+	ReserveFunds(ctx context.Context, input ReservationInput) error
+	CommitReservation(ctx context.Context, input ReservationInput) error
+	ReleaseReservation(ctx context.Context, input ReservationInput) error
+	GetRevenueReport(ctx context.Context, input RevenueReportInput) ([]ServiceRevenue, error)
+	GetIdempotencyKey(ctx context.Context, key string) (*IdempotencyKey, error)
+	CreateIdempotencyKey(ctx context.Context, idempotencyKey *IdempotencyKey) (bool, error)
+	UpdateIdempotencyKey(ctx context.Context, idempotencyKey *IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, key string) error
+	CreateWebhook(ctx context.Context, input WebhookInput) (*Webhook, error)
+	GetWebhook(ctx context.Context, clientID string, webhookID int) (*Webhook, error)
+	ListWebhooks(ctx context.Context, clientID string) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, webhookID int, input WebhookInput) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, clientID string, webhookID int) error
+	ListWebhookAttempts(ctx context.Context, filter WebhookAttemptFilter) ([]WebhookAttempt, error)
+	GetSpendingLimits(ctx context.Context, userID int) (*UserSpendingLimits, error)
+	SetSpendingLimits(ctx context.Context, input SpendingLimitsInput) (*UserSpendingLimits, error)
+	DeleteSpendingLimits(ctx context.Context, userID int) error
 }
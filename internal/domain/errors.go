@@ -0,0 +1,94 @@
+package domain
+
+import "errors"
+
+const (
+	ErrorCodeInvalidInput        = "invalid_input"
+	ErrorCodeUnauthorized        = "unauthorized"
+	ErrorCodeForbidden           = "forbidden"
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeUserNotFound        = "user_not_found"
+	ErrorCodeReservationNotFound = "reservation_not_found"
+	ErrorCodeWebhookNotFound     = "webhook_not_found"
+	ErrorCodeInsufficientFunds   = "insufficient_funds"
+	ErrorCodeUnknownCurrency     = "unknown_currency"
+	ErrorCodeDuplicate           = "duplicate"
+	ErrorCodeConflict            = "conflict"
+	ErrorCodeReservationMismatch = "reservation_mismatch"
+	ErrorCodeRateLimited         = "rate_limited"
+	ErrorCodeSpendingLimit       = "spending_limit_exceeded"
+	ErrorCodeInternal            = "internal_error"
+)
+
+var (
+	ErrInvalidInput        = NewError(ErrorCodeInvalidInput, "invalid input")
+	ErrUnauthorized        = NewError(ErrorCodeUnauthorized, "missing or invalid credentials")
+	ErrForbidden           = NewError(ErrorCodeForbidden, "the client is not allowed to do that")
+	ErrNotFound            = NewError(ErrorCodeNotFound, "not found")
+	ErrUserNotFound        = NewError(ErrorCodeUserNotFound, "user not found")
+	ErrReservationNotFound = NewError(ErrorCodeReservationNotFound, "reservation not found")
+	ErrWebhookNotFound     = NewError(ErrorCodeWebhookNotFound, "webhook not found")
+	ErrInsufficientFunds   = NewError(ErrorCodeInsufficientFunds, "not enough balance")
+	ErrUnknownCurrency     = NewError(ErrorCodeUnknownCurrency, "there is no exchange rate for that currency")
+	ErrDuplicate           = NewError(ErrorCodeDuplicate, "already exists")
+	ErrConflict            = NewError(ErrorCodeConflict, "conflict")
+	ErrReservationMismatch = NewError(ErrorCodeReservationMismatch, "reservation does not match request")
+	ErrRateLimited         = NewError(ErrorCodeRateLimited, "too many requests")
+	ErrSpendingLimit       = NewError(ErrorCodeSpendingLimit, "spending limit exceeded")
+	ErrInternal            = NewError(ErrorCodeInternal, "internal server error")
+)
+
+// Error is an error safe to show to API clients. Code is a stable machine
+// readable identifier, Message and Details are meant for humans. Errors with
+// the same code match each other in errors.Is, so a sentinel refined with
+// WithMessage or WithDetails still matches the sentinel.
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+
+	cause error
+}
+
+func NewError(code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	var t *Error
+	return errors.As(target, &t) && t.Code == e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+func (e *Error) WithMessage(message string) *Error {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+func (e *Error) WithDetails(details interface{}) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// Wrap attaches the underlying cause. The cause is kept for errors.Is and
+// logs but is never sent to clients.
+func (e *Error) Wrap(cause error) *Error {
+	copied := *e
+	copied.cause = cause
+	return &copied
+}
@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	cause := errors.New("sql: no rows in result set")
+	err := fmt.Errorf("getting user: %w", ErrUserNotFound.WithMessage(`there is no user with that "user_id"`).Wrap(cause))
+
+	assert.True(t, errors.Is(err, ErrUserNotFound))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrReservationNotFound))
+	assert.EqualError(t, err, `getting user: there is no user with that "user_id": sql: no rows in result set`)
+}
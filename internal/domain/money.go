@@ -0,0 +1,229 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultCurrency is the currency balances are kept in.
+	DefaultCurrency = "RUB"
+
+	defaultCurrencyDigits = 2
+)
+
+var (
+	ErrMoneyOverflow      = errors.New("money amount overflows")
+	ErrCurrencyMismatch   = errors.New("money currencies do not match")
+	ErrInvalidMoneyAmount = errors.New("invalid money amount")
+)
+
+// currencyDigits lists currencies whose minor unit is not a hundredth.
+var currencyDigits = map[string]int{
+	"BHD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// Money is an amount in the minor units of its currency, e.g. kopecks for
+// RUB and cents for USD. It is marshalled to JSON as a decimal string such as
+// "10.50" and stored in the database as a BIGINT of minor units in the
+// default currency.
+type Money struct {
+	MinorUnits int64
+	Currency   string
+}
+
+func NewMoney(minorUnits int64, currency string) Money {
+	return Money{
+		MinorUnits: minorUnits,
+		Currency:   currency,
+	}
+}
+
+// ParseMoney parses a decimal string like "10", "10.5" or "-0.01". It rejects
+// more fractional digits than the currency has.
+func ParseMoney(s, currency string) (Money, error) {
+	digits := CurrencyDigits(currency)
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+		if fraction == "" {
+			return Money{}, ErrInvalidMoneyAmount
+		}
+	}
+	if whole == "" || len(fraction) > digits || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, ErrInvalidMoneyAmount
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+
+	minorUnits, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Money{}, ErrMoneyOverflow
+		}
+		return Money{}, ErrInvalidMoneyAmount
+	}
+	if negative {
+		minorUnits = -minorUnits
+	}
+
+	return NewMoney(minorUnits, currency), nil
+}
+
+// CurrencyDigits returns the number of minor unit digits of the currency.
+func CurrencyDigits(currency string) int {
+	if digits, ok := currencyDigits[strings.ToUpper(currency)]; ok {
+		return digits
+	}
+	return defaultCurrencyDigits
+}
+
+func (m Money) IsZero() bool {
+	return m.MinorUnits == 0
+}
+
+// LessThan compares two amounts of the same currency.
+func (m Money) LessThan(o Money) bool {
+	return m.MinorUnits < o.MinorUnits
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	if (o.MinorUnits > 0 && m.MinorUnits > math.MaxInt64-o.MinorUnits) ||
+		(o.MinorUnits < 0 && m.MinorUnits < math.MinInt64-o.MinorUnits) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return NewMoney(m.MinorUnits+o.MinorUnits, m.Currency), nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if o.MinorUnits == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(NewMoney(-o.MinorUnits, o.Currency))
+}
+
+// Convert converts the amount into another currency using the number of
+// target currency units one unit of m's currency is worth. The result is
+// rounded half away from zero to the target currency minor units.
+func (m Money) Convert(rate float64, currency string) (Money, error) {
+	if math.IsNaN(rate) || math.IsInf(rate, 0) || rate < 0 {
+		return Money{}, fmt.Errorf("invalid exchange rate %v", rate)
+	}
+
+	value := new(big.Rat).SetInt64(m.MinorUnits)
+	value.Mul(value, new(big.Rat).SetFloat64(rate))
+	value.Mul(value, new(big.Rat).SetFrac(pow10(CurrencyDigits(currency)), pow10(CurrencyDigits(m.Currency))))
+
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(value.Num().Sign())))
+	}
+	if !quotient.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return NewMoney(quotient.Int64(), currency), nil
+}
+
+// String formats the amount as a decimal string without the currency code.
+func (m Money) String() string {
+	digits := CurrencyDigits(m.Currency)
+
+	abs := new(big.Int).Abs(big.NewInt(m.MinorUnits)).String()
+	if len(abs) <= digits {
+		abs = strings.Repeat("0", digits-len(abs)+1) + abs
+	}
+
+	sign := ""
+	if m.MinorUnits < 0 {
+		sign = "-"
+	}
+	if digits == 0 {
+		return sign + abs
+	}
+	return sign + abs[:len(abs)-digits] + "." + abs[len(abs)-digits:]
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts both decimal strings and plain JSON numbers, so older
+// clients sending "amount":10 keep working. The value is read in the default
+// currency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	money, err := ParseMoney(s, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.MinorUnits, nil
+}
+
+// Scan reads minor units of the default currency. Aggregates like SUM come
+// back as NUMERIC, so textual values are accepted as well.
+func (m *Money) Scan(src interface{}) error {
+	var minorUnits int64
+	switch v := src.(type) {
+	case int64:
+		minorUnits = v
+	case []byte:
+		parsed, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return err
+		}
+		minorUnits = parsed
+	case string:
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		minorUnits = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+
+	*m = NewMoney(minorUnits, DefaultCurrency)
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
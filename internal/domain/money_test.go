@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		currency    string
+		expected    Money
+		expectedErr error
+	}{
+		{name: "Whole", input: "10", currency: "RUB", expected: NewMoney(1000, "RUB")},
+		{name: "Fraction", input: "10.5", currency: "RUB", expected: NewMoney(1050, "RUB")},
+		{name: "Negative", input: "-0.01", currency: "RUB", expected: NewMoney(-1, "RUB")},
+		{name: "No minor units", input: "150", currency: "JPY", expected: NewMoney(150, "JPY")},
+		{name: "Too many digits", input: "10.001", currency: "RUB", expectedErr: ErrInvalidMoneyAmount},
+		{name: "Exponent", input: "1e3", currency: "RUB", expectedErr: ErrInvalidMoneyAmount},
+		{name: "Empty fraction", input: "10.", currency: "RUB", expectedErr: ErrInvalidMoneyAmount},
+		{name: "Overflow", input: "92233720368547758.08", currency: "RUB", expectedErr: ErrMoneyOverflow},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			money, err := ParseMoney(test.input, test.currency)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, money)
+		})
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "10.50", NewMoney(1050, "RUB").String())
+	assert.Equal(t, "0.05", NewMoney(5, "RUB").String())
+	assert.Equal(t, "-0.05", NewMoney(-5, "RUB").String())
+	assert.Equal(t, "150", NewMoney(150, "JPY").String())
+	assert.Equal(t, "-92233720368547758.08", NewMoney(math.MinInt64, "RUB").String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Amount Money `json:"amount"`
+	}{Amount: NewMoney(1050, "RUB")})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"amount":"10.50"}`, string(data))
+
+	var input struct {
+		Amount Money `json:"amount"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":"10.50"}`), &input))
+	assert.Equal(t, NewMoney(1050, DefaultCurrency), input.Amount)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":10}`), &input))
+	assert.Equal(t, NewMoney(1000, DefaultCurrency), input.Amount)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"ten"}`), &input))
+}
+
+func TestMoney_Add(t *testing.T) {
+	sum, err := NewMoney(100, "RUB").Add(NewMoney(50, "RUB"))
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(150, "RUB"), sum)
+
+	_, err = NewMoney(math.MaxInt64, "RUB").Add(NewMoney(1, "RUB"))
+	assert.Equal(t, ErrMoneyOverflow, err)
+
+	_, err = NewMoney(math.MinInt64, "RUB").Sub(NewMoney(1, "RUB"))
+	assert.Equal(t, ErrMoneyOverflow, err)
+
+	_, err = NewMoney(100, "RUB").Add(NewMoney(1, "USD"))
+	assert.Equal(t, ErrCurrencyMismatch, err)
+}
+
+func TestMoney_Convert(t *testing.T) {
+	converted, err := NewMoney(100000, "RUB").Convert(0.0125, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(1250, "USD"), converted)
+
+	converted, err = NewMoney(100, "RUB").Convert(1.605, "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(2, "JPY"), converted)
+
+	converted, err = NewMoney(-1, "RUB").Convert(0.5, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, NewMoney(-1, "USD"), converted)
+
+	_, err = NewMoney(math.MaxInt64, "RUB").Convert(1000, "USD")
+	assert.Equal(t, ErrMoneyOverflow, err)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"strings"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// Authenticate identifies the client by an "X-API-Key" header or an
+// "Authorization: Bearer <token>" header and stores it in c.Locals("client").
+// Requests without valid credentials are rejected with 401. Records logged
+// for the request afterwards carry the client id.
+func (h *Handler) Authenticate(c *fiber.Ctx) error {
+	client, err := h.authenticate(c)
+	if err != nil {
+		if errors.Is(err, domain.ErrUnauthorized) {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="api"`)
+		}
+		return err
+	}
+
+	c.Locals("client", client)
+
+	ctx := c.UserContext()
+	c.SetUserContext(logging.NewContext(ctx, logging.FromContext(ctx).With("client_id", client.ID)))
+	return c.Next()
+}
+
+func (h *Handler) authenticate(c *fiber.Ctx) (*domain.Client, error) {
+	if key := c.Get(HeaderAPIKey); key != "" {
+		return h.authenticator.AuthenticateAPIKey(c.UserContext(), key)
+	}
+
+	authorization := c.Get(fiber.HeaderAuthorization)
+	if authorization == "" {
+		return nil, domain.ErrUnauthorized.WithMessage(`"X-API-Key" or "Authorization" header is required`)
+	}
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+		return nil, domain.ErrUnauthorized.WithMessage(`"Authorization" header must be "Bearer <token>"`)
+	}
+	return h.authenticator.AuthenticateToken(c.UserContext(), parts[1])
+}
+
+// RequireScope rejects clients that were not granted the scope with 403. It
+// must run after Authenticate.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		client, ok := c.Locals("client").(*domain.Client)
+		if !ok || !client.HasScope(scope) {
+			return domain.ErrForbidden.WithMessage(`the "` + scope + `" scope is required`)
+		}
+		return c.Next()
+	}
+}
+
+// clientID returns the id of the authenticated client, or "" for requests
+// that did not go through Authenticate.
+func clientID(c *fiber.Ctx) string {
+	if client, ok := c.Locals("client").(*domain.Client); ok {
+		return client.ID
+	}
+	return ""
+}
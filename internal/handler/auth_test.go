@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_Authenticate(t *testing.T) {
+	type mockBehavior func(a *mock_domain.MockAuthenticator)
+
+	tests := []struct {
+		name                    string
+		headers                 map[string]string
+		mockBehavior            mockBehavior
+		expectedStatusCode      int
+		expectedResponseBody    string
+		expectedWWWAuthenticate string
+	}{
+		{
+			name:    "OK with API key",
+			headers: map[string]string{HeaderAPIKey: "ak_test"},
+			mockBehavior: func(a *mock_domain.MockAuthenticator) {
+				a.EXPECT().AuthenticateAPIKey(gomock.Any(), "ak_test").Return(&domain.Client{ID: "shop", Scopes: []string{domain.ScopeTransfer}}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"client":"shop"}`,
+		},
+		{
+			name:    "OK with bearer token",
+			headers: map[string]string{fiber.HeaderAuthorization: "Bearer token"},
+			mockBehavior: func(a *mock_domain.MockAuthenticator) {
+				a.EXPECT().AuthenticateToken(gomock.Any(), "token").Return(&domain.Client{ID: "shop", Scopes: []string{domain.ScopeTransfer}}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"client":"shop"}`,
+		},
+		{
+			name:                    "No credentials",
+			mockBehavior:            func(a *mock_domain.MockAuthenticator) {},
+			expectedStatusCode:      fiber.StatusUnauthorized,
+			expectedResponseBody:    `{"code":"unauthorized","message":"\"X-API-Key\" or \"Authorization\" header is required"}`,
+			expectedWWWAuthenticate: `Bearer realm="api"`,
+		},
+		{
+			name:                    "Not a bearer token",
+			headers:                 map[string]string{fiber.HeaderAuthorization: "Basic dXNlcjpwYXNz"},
+			mockBehavior:            func(a *mock_domain.MockAuthenticator) {},
+			expectedStatusCode:      fiber.StatusUnauthorized,
+			expectedResponseBody:    `{"code":"unauthorized","message":"\"Authorization\" header must be \"Bearer \u003ctoken\u003e\""}`,
+			expectedWWWAuthenticate: `Bearer realm="api"`,
+		},
+		{
+			name:    "Unknown API key",
+			headers: map[string]string{HeaderAPIKey: "ak_unknown"},
+			mockBehavior: func(a *mock_domain.MockAuthenticator) {
+				a.EXPECT().AuthenticateAPIKey(gomock.Any(), "ak_unknown").Return(nil, domain.ErrUnauthorized)
+			},
+			expectedStatusCode:      fiber.StatusUnauthorized,
+			expectedResponseBody:    `{"code":"unauthorized","message":"missing or invalid credentials"}`,
+			expectedWWWAuthenticate: `Bearer realm="api"`,
+		},
+		{
+			name:    "Missing scope",
+			headers: map[string]string{HeaderAPIKey: "ak_test"},
+			mockBehavior: func(a *mock_domain.MockAuthenticator) {
+				a.EXPECT().AuthenticateAPIKey(gomock.Any(), "ak_test").Return(&domain.Client{ID: "shop", Scopes: []string{domain.ScopeBalanceRead}}, nil)
+			},
+			expectedStatusCode:   fiber.StatusForbidden,
+			expectedResponseBody: `{"code":"forbidden","message":"the \"transfer\" scope is required"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			authenticator := mock_domain.NewMockAuthenticator(c)
+			test.mockBehavior(authenticator)
+
+			handler := NewHandler(nil, authenticator, nil)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Post("/p2p", handler.Authenticate, RequireScope(domain.ScopeTransfer), func(c *fiber.Ctx) error {
+				return c.JSON(fiber.Map{"client": clientID(c)})
+			})
+
+			request := httptest.NewRequest("POST", "/p2p", nil)
+			for key, value := range test.headers {
+				request.Header.Add(key, value)
+			}
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+			assert.Equal(t, response.Header.Get(fiber.HeaderWWWAuthenticate), test.expectedWWWAuthenticate)
+		})
+	}
+}
+
+func TestHandler_RecordsClient(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	authenticator := mock_domain.NewMockAuthenticator(c)
+	authenticator.EXPECT().AuthenticateAPIKey(gomock.Any(), "ak_test").Return(&domain.Client{ID: "shop", Scopes: domain.Scopes}, nil)
+
+	service := mock_domain.NewMockService(c)
+	service.EXPECT().Transfer(gomock.Any(), domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(10), ClientID: "shop"}).Return(nil)
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	Router(app.Group("/api"), NewHandler(service, authenticator, nil))
+
+	request := httptest.NewRequest("POST", "/api/v1/p2p", strings.NewReader(`{"from_user_id":1,"to_user_id":2,"amount":"10"}`))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add(HeaderAPIKey, "ak_test")
+
+	response, err := app.Test(request)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, response.StatusCode, fiber.StatusOK)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	_ "embed"
+	"github.com/gofiber/fiber/v2"
+)
+
+// openAPISpec describes every route registered in Router. TestOpenAPISpec
+// fails when a route or an input field is missing from it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// swaggerUIPage renders the spec with Swagger UI loaded from a CDN.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Avito Test Go API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@4/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+func (h *Handler) GetOpenAPISpec(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(fiber.StatusOK).Send(openAPISpec)
+}
+
+func (h *Handler) GetDocs(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(fiber.StatusOK).SendString(swaggerUIPage)
+}
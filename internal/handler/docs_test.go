@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+var (
+	pathParam     = regexp.MustCompile(`:(\w+)`)
+	versionPrefix = regexp.MustCompile(`^/v\d+/`)
+)
+
+func TestOpenAPISpec_Routes(t *testing.T) {
+	spec := openAPIDocument{}
+	require.NoError(t, json.Unmarshal(openAPISpec, &spec))
+
+	app := fiber.New()
+	Router(app.Group("/api"), NewHandler(nil, nil, nil))
+
+	for _, routes := range app.Stack() {
+		for _, route := range routes {
+			// Fiber registers HEAD for every GET route by itself.
+			if route.Method == fiber.MethodHead || !strings.HasPrefix(route.Path, "/api/") {
+				continue
+			}
+			// The spec describes the latest version, unversioned routes are its
+			// aliases.
+			path := versionPrefix.ReplaceAllString(strings.TrimPrefix(route.Path, "/api"), "/")
+			path = pathParam.ReplaceAllString(path, "{$1}")
+			_, ok := spec.Paths[path][strings.ToLower(route.Method)]
+			assert.True(t, ok, "%s %s is missing from openapi.json", route.Method, path)
+		}
+	}
+}
+
+func TestOpenAPISpec_Schemas(t *testing.T) {
+	spec := openAPIDocument{}
+	require.NoError(t, json.Unmarshal(openAPISpec, &spec))
+
+	schemas := map[string]interface{}{
+		"User":                  domain.User{},
+		"GetBalanceInput":       domain.GetBalanceInput{},
+		"BalanceOperationInput": domain.BalanceOperationInput{},
+		"P2PInput":              domain.P2PInput{},
+		"ReservationInput":      domain.ReservationInput{},
+		"Transaction":           domain.Transaction{},
+		"Webhook":               domain.Webhook{},
+		"WebhookInput":          domain.WebhookInput{},
+		"WebhookAttempt":        domain.WebhookAttempt{},
+		"SpendingLimits":        domain.SpendingLimits{},
+		"SpendingLimitsInput":   domain.SpendingLimitsInput{},
+		"UserSpendingLimits":    domain.UserSpendingLimits{},
+		"Error":                 domain.Error{},
+	}
+
+	for name, value := range schemas {
+		var properties []string
+		for property := range spec.Components.Schemas[name].Properties {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+
+		assert.Equal(t, jsonFields(reflect.TypeOf(value)), properties, "schema %s", name)
+	}
+}
+
+func TestHandler_GetOpenAPISpec(t *testing.T) {
+	app := fiber.New()
+	Router(app.Group("/api"), NewHandler(nil, nil, nil))
+
+	response, err := app.Test(httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	assert.Equal(t, err, nil)
+
+	body, err := ioutil.ReadAll(response.Body)
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, response.StatusCode, fiber.StatusOK)
+	assert.Equal(t, response.Header.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON)
+	assert.True(t, json.Valid(body))
+}
+
+// jsonFields lists the names a struct is (un)marshalled with.
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"strings"
+)
+
+var errorStatusCodes = map[string]int{
+	domain.ErrorCodeInvalidInput:        fiber.StatusBadRequest,
+	domain.ErrorCodeUnauthorized:        fiber.StatusUnauthorized,
+	domain.ErrorCodeForbidden:           fiber.StatusForbidden,
+	domain.ErrorCodeUnknownCurrency:     fiber.StatusBadRequest,
+	domain.ErrorCodeNotFound:            fiber.StatusNotFound,
+	domain.ErrorCodeUserNotFound:        fiber.StatusNotFound,
+	domain.ErrorCodeReservationNotFound: fiber.StatusNotFound,
+	domain.ErrorCodeWebhookNotFound:     fiber.StatusNotFound,
+	domain.ErrorCodeDuplicate:           fiber.StatusConflict,
+	domain.ErrorCodeConflict:            fiber.StatusConflict,
+	domain.ErrorCodeReservationMismatch: fiber.StatusConflict,
+	domain.ErrorCodeInsufficientFunds:   fiber.StatusUnprocessableEntity,
+	domain.ErrorCodeSpendingLimit:       fiber.StatusUnprocessableEntity,
+	domain.ErrorCodeRateLimited:         fiber.StatusTooManyRequests,
+	domain.ErrorCodeInternal:            fiber.StatusInternalServerError,
+}
+
+// ErrorHandler renders errors returned by handlers and middlewares as
+// {"code", "message", "details"} JSON bodies. Domain errors keep their code
+// and message, errors raised by Fiber itself keep their status, and anything
+// else is logged and reported as a generic internal error, so database or
+// driver messages never reach clients.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var domainErr *domain.Error
+	var fiberErr *fiber.Error
+
+	switch {
+	case errors.As(err, &domainErr):
+	case errors.As(err, &fiberErr):
+		return c.Status(fiberErr.Code).JSON(domain.NewError(fiberErrorCode(fiberErr.Code), fiberErr.Message))
+	default:
+		domainErr = domain.ErrInternal
+	}
+
+	status, ok := errorStatusCodes[domainErr.Code]
+	if !ok {
+		status = fiber.StatusInternalServerError
+	}
+	if status >= fiber.StatusInternalServerError {
+		logging.FromContext(c.UserContext()).Error("request failed", "method", c.Method(), "path", c.Path(), "error", err)
+	}
+
+	return c.Status(status).JSON(domainErr)
+}
+
+// RenderErrors renders the error returned by the rest of the chain with the
+// error handler of the app. It is registered after the middlewares that log,
+// trace and measure requests, so that they see the status the client got.
+func RenderErrors(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		if err := c.App().Config().ErrorHandler(c, err); err != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+	return nil
+}
+
+// fiberErrorCode turns an HTTP status into an error code, e.g. 404 into
+// "not_found".
+func fiberErrorCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(utils.StatusMessage(status)), " ", "_")
+}
+
+func invalidRequestBody(err error) error {
+	return domain.ErrInvalidInput.WithMessage("parsing data from request body failed").WithDetails(err.Error())
+}
+
+func invalidQueryString(err error) error {
+	return domain.ErrInvalidInput.WithMessage("parsing data from query string failed").WithDetails(err.Error())
+}
+
+func invalidPathParam(name string, err error) error {
+	return domain.ErrInvalidInput.WithMessage(fmt.Sprintf("parsing %q from path failed", name)).WithDetails(err.Error())
+}
+
+func validationFailed(message string, errs []*ErrorResponse) error {
+	return domain.ErrInvalidInput.WithMessage(message).WithDetails(errs)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name                 string
+		err                  error
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "Domain error",
+			err:                  domain.ErrInsufficientFunds,
+			expectedStatusCode:   fiber.StatusUnprocessableEntity,
+			expectedResponseBody: `{"code":"insufficient_funds","message":"not enough balance"}`,
+		},
+		{
+			name:                 "Wrapped domain error",
+			err:                  fmt.Errorf("making transfer: %w", domain.ErrUserNotFound.Wrap(errors.New("sql: no rows in result set"))),
+			expectedStatusCode:   fiber.StatusNotFound,
+			expectedResponseBody: `{"code":"user_not_found","message":"user not found"}`,
+		},
+		{
+			name:                 "Domain error with details",
+			err:                  domain.ErrInvalidInput.WithMessage("invalid request body").WithDetails([]string{"amount"}),
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":["amount"]}`,
+		},
+		{
+			name:                 "Fiber error",
+			err:                  fiber.ErrMethodNotAllowed,
+			expectedStatusCode:   fiber.StatusMethodNotAllowed,
+			expectedResponseBody: `{"code":"method_not_allowed","message":"Method Not Allowed"}`,
+		},
+		{
+			name:                 "Unknown error",
+			err:                  errors.New(`pq: relation "users" does not exist`),
+			expectedStatusCode:   fiber.StatusInternalServerError,
+			expectedResponseBody: `{"code":"internal_error","message":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/", func(c *fiber.Ctx) error {
+				return test.err
+			})
+
+			request := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+		})
+	}
+}
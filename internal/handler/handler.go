@@ -1,28 +1,35 @@
 package handler
 
 import (
+	"encoding/csv"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"strconv"
 )
 
 type Handler struct {
-	service domain.Service
+	service       domain.Service
+	authenticator domain.Authenticator
+	limiter       domain.RateLimiter
 }
 
-func NewHandler(service domain.Service) *Handler {
+// NewHandler creates handlers for the REST API. A nil limiter turns rate
+// limiting off.
+func NewHandler(service domain.Service, authenticator domain.Authenticator, limiter domain.RateLimiter) *Handler {
 	return &Handler{
-		service: service,
+		service:       service,
+		authenticator: authenticator,
+		limiter:       limiter,
 	}
 }
 
 func (h *Handler) MakeP2PTransfer(c *fiber.Ctx) error {
 	p2pInput := c.Locals("p2pInput").(domain.P2PInput)
+	p2pInput.ClientID = clientID(c)
 
-	err := h.service.MakeP2PTransfer(p2pInput)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
-			"message": "making transfer failed with error: " + err.Error(),
-		})
+	if err := h.service.Transfer(c.UserContext(), p2pInput); err != nil {
+		return err
 	}
 
 	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
@@ -31,34 +38,241 @@ func (h *Handler) MakeP2PTransfer(c *fiber.Ctx) error {
 }
 
 func (h *Handler) GetBalanceByUserID(c *fiber.Ctx) error {
-	user := c.Locals("user").(*domain.User)
+	getBalanceInput := c.Locals("getBalanceInput").(domain.GetBalanceInput)
+
+	balance, err := h.service.GetBalance(c.UserContext(), getBalanceInput)
+	if err != nil {
+		return err
+	}
 
 	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
-		"balance": user.Balance,
+		"balance":  balance,
+		"currency": balance.Currency,
 	})
 }
 
-func (h *Handler) MakeBalanceOperationByUserID(c *fiber.Ctx) error {
-	user := c.Locals("user").(*domain.User)
-	amount := c.Locals("operationAmount").(int)
-	operationType := c.Locals("operationType").(string)
+func (h *Handler) GetUserByID(c *fiber.Ctx) error {
+	getUserInput := c.Locals("getUserInput").(domain.GetUserInput)
 
-	switch operationType {
-	case "add":
-		user.Balance += amount
-		break
-	case "subtract":
-		user.Balance -= amount
-		break
+	user, err := h.service.GetUser(c.UserContext(), getUserInput.ID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return domain.ErrUserNotFound.WithMessage(`there is no user with that "id"`)
 	}
 
-	if err := h.service.UpdateUser(user.ID, user); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
-			"message": "making operation failed with error: " + err.Error(),
-		})
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"user": user,
+	})
+}
+
+func (h *Handler) MakeBalanceOperationByUserID(c *fiber.Ctx) error {
+	balanceOperationInput := c.Locals("balanceOperationInput").(domain.BalanceOperationInput)
+	balanceOperationInput.ClientID = clientID(c)
+
+	operation := h.service.Deposit
+	if balanceOperationInput.Type == domain.TransactionTypeSubtract {
+		operation = h.service.Withdraw
+	}
+	if err := operation(c.UserContext(), balanceOperationInput); err != nil {
+		return err
 	}
 
 	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"message": "operation completed",
 	})
 }
+
+func (h *Handler) ListTransactionsByUserID(c *fiber.Ctx) error {
+	transactionFilter := c.Locals("transactionFilter").(domain.TransactionFilter)
+
+	transactions, err := h.service.ListTransactions(c.UserContext(), transactionFilter)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"transactions": transactions,
+	})
+}
+
+func (h *Handler) ReserveFunds(c *fiber.Ctx) error {
+	reservationInput := c.Locals("reservationInput").(domain.ReservationInput)
+	reservationInput.ClientID = clientID(c)
+
+	if err := h.service.ReserveFunds(c.UserContext(), reservationInput); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"message": "funds reserved",
+	})
+}
+
+func (h *Handler) CommitReservation(c *fiber.Ctx) error {
+	reservationInput := c.Locals("reservationInput").(domain.ReservationInput)
+	reservationInput.ClientID = clientID(c)
+
+	if err := h.service.CommitReservation(c.UserContext(), reservationInput); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"message": "reservation committed",
+	})
+}
+
+func (h *Handler) ReleaseReservation(c *fiber.Ctx) error {
+	reservationInput := c.Locals("reservationInput").(domain.ReservationInput)
+	reservationInput.ClientID = clientID(c)
+
+	if err := h.service.ReleaseReservation(c.UserContext(), reservationInput); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"message": "reservation released",
+	})
+}
+
+// CreateWebhook responds with the webhook and its secret. The secret is not
+// shown again, so clients must keep it to verify signatures.
+func (h *Handler) CreateWebhook(c *fiber.Ctx) error {
+	webhookInput := c.Locals("webhookInput").(domain.WebhookInput)
+	webhookInput.ClientID = clientID(c)
+
+	webhook, err := h.service.CreateWebhook(c.UserContext(), webhookInput)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&fiber.Map{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+func (h *Handler) ListWebhooks(c *fiber.Ctx) error {
+	webhooks, err := h.service.ListWebhooks(c.UserContext(), clientID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"webhooks": webhooks,
+	})
+}
+
+func (h *Handler) GetWebhook(c *fiber.Ctx) error {
+	webhookID := c.Locals("webhookID").(int)
+
+	webhook, err := h.service.GetWebhook(c.UserContext(), clientID(c), webhookID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"webhook": webhook,
+	})
+}
+
+func (h *Handler) UpdateWebhook(c *fiber.Ctx) error {
+	webhookID := c.Locals("webhookID").(int)
+	webhookInput := c.Locals("webhookInput").(domain.WebhookInput)
+	webhookInput.ClientID = clientID(c)
+
+	webhook, err := h.service.UpdateWebhook(c.UserContext(), webhookID, webhookInput)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"webhook": webhook,
+	})
+}
+
+func (h *Handler) DeleteWebhook(c *fiber.Ctx) error {
+	webhookID := c.Locals("webhookID").(int)
+
+	if err := h.service.DeleteWebhook(c.UserContext(), clientID(c), webhookID); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"message": "webhook deleted",
+	})
+}
+
+func (h *Handler) ListWebhookAttempts(c *fiber.Ctx) error {
+	webhookAttemptFilter := c.Locals("webhookAttemptFilter").(domain.WebhookAttemptFilter)
+	webhookAttemptFilter.ClientID = clientID(c)
+
+	attempts, err := h.service.ListWebhookAttempts(c.UserContext(), webhookAttemptFilter)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"attempts": attempts,
+	})
+}
+
+func (h *Handler) GetRevenueReport(c *fiber.Ctx) error {
+	revenueReportInput := c.Locals("revenueReportInput").(domain.RevenueReportInput)
+
+	revenues, err := h.service.GetRevenueReport(c.UserContext(), revenueReportInput)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Attachment(fmt.Sprintf("revenue-%04d-%02d.csv", revenueReportInput.Year, revenueReportInput.Month))
+
+	w := csv.NewWriter(c)
+	_ = w.Write([]string{"service_id", "revenue"})
+	for _, revenue := range revenues {
+		_ = w.Write([]string{strconv.Itoa(revenue.ServiceID), revenue.Revenue.String()})
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+func (h *Handler) GetSpendingLimits(c *fiber.Ctx) error {
+	userID := c.Locals("spendingLimitsUserID").(int)
+
+	limits, err := h.service.GetSpendingLimits(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"limits": limits,
+	})
+}
+
+func (h *Handler) SetSpendingLimits(c *fiber.Ctx) error {
+	spendingLimitsInput := c.Locals("spendingLimitsInput").(domain.SpendingLimitsInput)
+
+	limits, err := h.service.SetSpendingLimits(c.UserContext(), spendingLimitsInput)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"limits": limits,
+	})
+}
+
+func (h *Handler) DeleteSpendingLimits(c *fiber.Ctx) error {
+	userID := c.Locals("spendingLimitsUserID").(int)
+
+	if err := h.service.DeleteSpendingLimits(c.UserContext(), userID); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(&fiber.Map{
+		"message": "spending limits deleted",
+	})
+}
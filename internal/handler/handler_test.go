@@ -9,9 +9,15 @@ import (
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
+func rubles(amount int64) domain.Money {
+	return domain.NewMoney(amount*100, domain.DefaultCurrency)
+}
+
 func TestHandler_makeP2PTransfer(t *testing.T) {
 
 	type mockBehavior func(s *mock_domain.MockService, input domain.P2PInput)
@@ -28,26 +34,39 @@ func TestHandler_makeP2PTransfer(t *testing.T) {
 			inputObject: domain.P2PInput{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     10,
+				Amount:     rubles(10),
 			},
 			mockBehavior: func(s *mock_domain.MockService, input domain.P2PInput) {
-				s.EXPECT().MakeP2PTransfer(input).Return(nil)
+				s.EXPECT().Transfer(gomock.Any(), input).Return(nil)
 			},
 			expectedStatusCode:   fiber.StatusOK,
 			expectedResponseBody: `{"message":"transfer completed"}`,
 		},
+		{
+			name: "Too much amount",
+			inputObject: domain.P2PInput{
+				FromUserID: 1,
+				ToUserID:   2,
+				Amount:     rubles(100),
+			},
+			mockBehavior: func(s *mock_domain.MockService, input domain.P2PInput) {
+				s.EXPECT().Transfer(gomock.Any(), input).Return(domain.ErrInsufficientFunds)
+			},
+			expectedStatusCode:   fiber.StatusUnprocessableEntity,
+			expectedResponseBody: `{"code":"insufficient_funds","message":"not enough balance"}`,
+		},
 		{
 			name: "InternalServerError",
 			inputObject: domain.P2PInput{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     10,
+				Amount:     rubles(10),
 			},
 			mockBehavior: func(s *mock_domain.MockService, input domain.P2PInput) {
-				s.EXPECT().MakeP2PTransfer(input).Return(errors.New("service returning error"))
+				s.EXPECT().Transfer(gomock.Any(), input).Return(errors.New("service returning error"))
 			},
 			expectedStatusCode:   fiber.StatusInternalServerError,
-			expectedResponseBody: `{"message":"making transfer failed with error: service returning error"}`,
+			expectedResponseBody: `{"code":"internal_error","message":"internal server error"}`,
 		},
 	}
 
@@ -59,9 +78,9 @@ func TestHandler_makeP2PTransfer(t *testing.T) {
 			service := mock_domain.NewMockService(c)
 			test.mockBehavior(service, test.inputObject)
 
-			handler := NewHandler(service)
+			handler := NewHandler(service, nil, nil)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Post("", func(ctx *fiber.Ctx) error {
 				ctx.Locals("p2pInput", test.inputObject)
 				return ctx.Next()
@@ -83,17 +102,50 @@ func TestHandler_makeP2PTransfer(t *testing.T) {
 
 func TestHandler_GetBalanceByUserID(t *testing.T) {
 
+	type mockBehavior func(s *mock_domain.MockService, input domain.GetBalanceInput)
+
 	tests := []struct {
 		name                 string
-		inputObject          domain.User
+		inputObject          domain.GetBalanceInput
+		mockBehavior         mockBehavior
 		expectedStatusCode   int
 		expectedResponseBody string
 	}{
 		{
-			name:                 "OK",
-			inputObject:          domain.User{ID: 1, Balance: 10},
+			name:        "OK",
+			inputObject: domain.GetBalanceInput{ID: 1},
+			mockBehavior: func(s *mock_domain.MockService, input domain.GetBalanceInput) {
+				s.EXPECT().GetBalance(gomock.Any(), input).Return(rubles(10), nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: `{"balance":"10.00","currency":"RUB"}`,
+		},
+		{
+			name:        "OK with currency",
+			inputObject: domain.GetBalanceInput{ID: 1, Currency: "USD"},
+			mockBehavior: func(s *mock_domain.MockService, input domain.GetBalanceInput) {
+				s.EXPECT().GetBalance(gomock.Any(), input).Return(domain.NewMoney(1250, "USD"), nil)
+			},
 			expectedStatusCode:   200,
-			expectedResponseBody: `{"balance":10}`,
+			expectedResponseBody: `{"balance":"12.50","currency":"USD"}`,
+		},
+		{
+			name:        "Unknown currency",
+			inputObject: domain.GetBalanceInput{ID: 1, Currency: "XAU"},
+			mockBehavior: func(s *mock_domain.MockService, input domain.GetBalanceInput) {
+				s.EXPECT().GetBalance(gomock.Any(), input).Return(domain.Money{}, domain.ErrUnknownCurrency)
+			},
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"unknown_currency","message":"there is no exchange rate for that currency"}`,
+		},
+		{
+			name:        "User not found",
+			inputObject: domain.GetBalanceInput{ID: 1},
+			mockBehavior: func(s *mock_domain.MockService, input domain.GetBalanceInput) {
+				s.EXPECT().GetBalance(gomock.Any(), input).Return(domain.Money{}, domain.ErrUserNotFound)
+			},
+			expectedStatusCode:   fiber.StatusNotFound,
+			expectedResponseBody: `{"code":"user_not_found","message":"user not found"}`,
 		},
 	}
 
@@ -103,12 +155,13 @@ func TestHandler_GetBalanceByUserID(t *testing.T) {
 			defer c.Finish()
 
 			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service, test.inputObject)
 
-			handler := NewHandler(service)
+			handler := NewHandler(service, nil, nil)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Get("", func(ctx *fiber.Ctx) error {
-				ctx.Locals("user", &test.inputObject)
+				ctx.Locals("getBalanceInput", test.inputObject)
 				return ctx.Next()
 			}, handler.GetBalanceByUserID)
 
@@ -128,12 +181,11 @@ func TestHandler_GetBalanceByUserID(t *testing.T) {
 
 func TestHandler_MakeBalanceOperationByUserID(t *testing.T) {
 
-	type mockBehavior func(s *mock_domain.MockService, userID int, user *domain.User)
+	type mockBehavior func(s *mock_domain.MockService, input domain.BalanceOperationInput)
 
 	tests := []struct {
 		name                 string
 		inputObject          domain.BalanceOperationInput
-		user                 domain.User
 		mockBehavior         mockBehavior
 		expectedStatusCode   int
 		expectedResponseBody string
@@ -142,31 +194,40 @@ func TestHandler_MakeBalanceOperationByUserID(t *testing.T) {
 			name: "OK",
 			inputObject: domain.BalanceOperationInput{
 				UserID: 1,
-				Amount: 10,
+				Amount: rubles(10),
 				Type:   "add",
 			},
-			user: domain.User{
-				ID:      1,
-				Balance: 10,
-			},
-			mockBehavior: func(s *mock_domain.MockService, userID int, user *domain.User) {
-				s.EXPECT().UpdateUser(userID, user).Return(nil)
+			mockBehavior: func(s *mock_domain.MockService, input domain.BalanceOperationInput) {
+				s.EXPECT().Deposit(gomock.Any(), input).Return(nil)
 			},
 			expectedStatusCode:   fiber.StatusOK,
 			expectedResponseBody: `{"message":"operation completed"}`,
 		},
+		{
+			name: "Too much amount",
+			inputObject: domain.BalanceOperationInput{
+				UserID: 1,
+				Amount: rubles(10),
+				Type:   "subtract",
+			},
+			mockBehavior: func(s *mock_domain.MockService, input domain.BalanceOperationInput) {
+				s.EXPECT().Withdraw(gomock.Any(), input).Return(domain.ErrInsufficientFunds)
+			},
+			expectedStatusCode:   fiber.StatusUnprocessableEntity,
+			expectedResponseBody: `{"code":"insufficient_funds","message":"not enough balance"}`,
+		},
 		{
 			name: "InternalServerError",
 			inputObject: domain.BalanceOperationInput{
 				UserID: 1,
-				Amount: 10,
+				Amount: rubles(10),
 				Type:   "add",
 			},
-			mockBehavior: func(s *mock_domain.MockService, userID int, user *domain.User) {
-				s.EXPECT().UpdateUser(userID, user).Return(errors.New("service returning error"))
+			mockBehavior: func(s *mock_domain.MockService, input domain.BalanceOperationInput) {
+				s.EXPECT().Deposit(gomock.Any(), input).Return(errors.New("service returning error"))
 			},
 			expectedStatusCode:   fiber.StatusInternalServerError,
-			expectedResponseBody: `{"message":"making operation failed with error: service returning error"}`,
+			expectedResponseBody: `{"code":"internal_error","message":"internal server error"}`,
 		},
 	}
 
@@ -176,15 +237,13 @@ func TestHandler_MakeBalanceOperationByUserID(t *testing.T) {
 			defer c.Finish()
 
 			service := mock_domain.NewMockService(c)
-			test.mockBehavior(service, test.user.ID, &test.user)
+			test.mockBehavior(service, test.inputObject)
 
-			handler := NewHandler(service)
+			handler := NewHandler(service, nil, nil)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Post("", func(ctx *fiber.Ctx) error {
-				ctx.Locals("user", &test.user)
-				ctx.Locals("operationAmount", test.inputObject.Amount)
-				ctx.Locals("operationType", test.inputObject.Type)
+				ctx.Locals("balanceOperationInput", test.inputObject)
 				return ctx.Next()
 			}, handler.MakeBalanceOperationByUserID)
 
@@ -201,3 +260,357 @@ func TestHandler_MakeBalanceOperationByUserID(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_ListTransactionsByUserID(t *testing.T) {
+
+	type mockBehavior func(s *mock_domain.MockService, filter domain.TransactionFilter)
+
+	createdAt := time.Date(2022, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                 string
+		inputObject          domain.TransactionFilter
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "OK",
+			inputObject: domain.TransactionFilter{UserID: 1},
+			mockBehavior: func(s *mock_domain.MockService, filter domain.TransactionFilter) {
+				s.EXPECT().ListTransactions(gomock.Any(), filter).Return([]domain.Transaction{
+					{ID: 1, UserID: 1, Type: "add", Amount: rubles(10), Comment: "top up", CreatedAt: createdAt},
+				}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"transactions":[{"id":1,"user_id":1,"type":"add","amount":"10.00","comment":"top up","created_at":"2022-04-10T12:00:00Z"}]}`,
+		},
+		{
+			name:        "InternalServerError",
+			inputObject: domain.TransactionFilter{UserID: 1},
+			mockBehavior: func(s *mock_domain.MockService, filter domain.TransactionFilter) {
+				s.EXPECT().ListTransactions(gomock.Any(), filter).Return(nil, errors.New("service returning error"))
+			},
+			expectedStatusCode:   fiber.StatusInternalServerError,
+			expectedResponseBody: `{"code":"internal_error","message":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service, test.inputObject)
+
+			handler := NewHandler(service, nil, nil)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("", func(ctx *fiber.Ctx) error {
+				ctx.Locals("transactionFilter", test.inputObject)
+				return ctx.Next()
+			}, handler.ListTransactionsByUserID)
+
+			request := httptest.NewRequest("GET", "/", nil)
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+		})
+	}
+}
+
+func TestHandler_GetRevenueReport(t *testing.T) {
+
+	type mockBehavior func(s *mock_domain.MockService, input domain.RevenueReportInput)
+
+	tests := []struct {
+		name                 string
+		inputObject          domain.RevenueReportInput
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "OK",
+			inputObject: domain.RevenueReportInput{Year: 2022, Month: 4},
+			mockBehavior: func(s *mock_domain.MockService, input domain.RevenueReportInput) {
+				s.EXPECT().GetRevenueReport(gomock.Any(), input).Return([]domain.ServiceRevenue{
+					{ServiceID: 1, Revenue: rubles(100)},
+					{ServiceID: 2, Revenue: rubles(50)},
+				}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: "service_id,revenue\n1,100.00\n2,50.00\n",
+		},
+		{
+			name:        "InternalServerError",
+			inputObject: domain.RevenueReportInput{Year: 2022, Month: 4},
+			mockBehavior: func(s *mock_domain.MockService, input domain.RevenueReportInput) {
+				s.EXPECT().GetRevenueReport(gomock.Any(), input).Return(nil, errors.New("service returning error"))
+			},
+			expectedStatusCode:   fiber.StatusInternalServerError,
+			expectedResponseBody: `{"code":"internal_error","message":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service, test.inputObject)
+
+			handler := NewHandler(service, nil, nil)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("", func(ctx *fiber.Ctx) error {
+				ctx.Locals("revenueReportInput", test.inputObject)
+				return ctx.Next()
+			}, handler.GetRevenueReport)
+
+			request := httptest.NewRequest("GET", "/", nil)
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+		})
+	}
+}
+
+func TestHandler_CreateWebhook(t *testing.T) {
+	createdAt := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+
+	type mockBehavior func(s *mock_domain.MockService)
+
+	tests := []struct {
+		name                 string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"url":"https://merchant.example/hooks","event_types":["balance.deposit"],"user_id":1}`,
+			mockBehavior: func(s *mock_domain.MockService) {
+				userID := 1
+				s.EXPECT().CreateWebhook(gomock.Any(), domain.WebhookInput{
+					URL:        "https://merchant.example/hooks",
+					EventTypes: []string{domain.EventTypeDeposit},
+					UserID:     &userID,
+					ClientID:   "shop",
+				}).Return(&domain.Webhook{
+					ID:         1,
+					URL:        "https://merchant.example/hooks",
+					EventTypes: []string{domain.EventTypeDeposit},
+					UserID:     &userID,
+					Secret:     "secret",
+					Active:     true,
+					CreatedAt:  createdAt,
+					UpdatedAt:  createdAt,
+				}, nil)
+			},
+			expectedStatusCode:   fiber.StatusCreated,
+			expectedResponseBody: `{"secret":"secret","webhook":{"id":1,"url":"https://merchant.example/hooks","event_types":["balance.deposit"],"user_id":1,"active":true,"created_at":"2022-04-11T12:00:00Z","updated_at":"2022-04-11T12:00:00Z"}}`,
+		},
+		{
+			name:                 "Unknown event type",
+			inputBody:            `{"url":"https://merchant.example/hooks","event_types":["balance.unknown"]}`,
+			mockBehavior:         func(s *mock_domain.MockService) {},
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"WebhookInput.EventTypes[0]","Tag":"oneof","Value":"balance.deposit balance.withdrawal balance.transfer balance.reservation.created balance.reservation.committed balance.reservation.released"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service)
+
+			handler := NewHandler(service, nil, nil)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Post("/webhooks", func(c *fiber.Ctx) error {
+				c.Locals("client", &domain.Client{ID: "shop"})
+				return c.Next()
+			}, handler.CheckWebhookInput, handler.CreateWebhook)
+
+			request := httptest.NewRequest("POST", "/webhooks", strings.NewReader(test.inputBody))
+			request.Header.Set("Content-Type", "application/json")
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+		})
+	}
+}
+
+func TestHandler_ListWebhookAttempts(t *testing.T) {
+	createdAt := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+
+	type mockBehavior func(s *mock_domain.MockService)
+
+	tests := []struct {
+		name                 string
+		url                  string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "OK",
+			url:  "/webhooks/1/attempts?limit=10",
+			mockBehavior: func(s *mock_domain.MockService) {
+				statusCode := 500
+				message := "webhook responded with status 500"
+				s.EXPECT().ListWebhookAttempts(gomock.Any(), domain.WebhookAttemptFilter{WebhookID: 1, Limit: 10, ClientID: "shop"}).Return([]domain.WebhookAttempt{
+					{
+						ID:             3,
+						DeliveryID:     2,
+						EventID:        1,
+						EventType:      domain.EventTypeDeposit,
+						Attempt:        1,
+						StatusCode:     &statusCode,
+						Error:          &message,
+						DurationMS:     12,
+						DeliveryStatus: domain.WebhookDeliveryStatusPending,
+						CreatedAt:      createdAt,
+					},
+				}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"attempts":[{"id":3,"delivery_id":2,"event_id":1,"event_type":"balance.deposit","attempt":1,"status_code":500,"error":"webhook responded with status 500","duration_ms":12,"delivery_status":"pending","created_at":"2022-04-11T12:00:00Z"}]}`,
+		},
+		{
+			name: "Webhook not found",
+			url:  "/webhooks/2/attempts",
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().ListWebhookAttempts(gomock.Any(), domain.WebhookAttemptFilter{WebhookID: 2, ClientID: "shop"}).Return(nil, domain.ErrWebhookNotFound)
+			},
+			expectedStatusCode:   fiber.StatusNotFound,
+			expectedResponseBody: `{"code":"webhook_not_found","message":"webhook not found"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service)
+
+			handler := NewHandler(service, nil, nil)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/webhooks/:id/attempts", func(c *fiber.Ctx) error {
+				c.Locals("client", &domain.Client{ID: "shop"})
+				return c.Next()
+			}, handler.CheckWebhookAttemptFilter, handler.ListWebhookAttempts)
+
+			response, err := app.Test(httptest.NewRequest("GET", test.url, nil))
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+		})
+	}
+}
+
+func TestHandler_SetSpendingLimits(t *testing.T) {
+	type mockBehavior func(s *mock_domain.MockService)
+
+	daily := rubles(1000)
+	monthly := rubles(10000)
+
+	tests := []struct {
+		name                 string
+		url                  string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "OK",
+			url:       "/users/1/limits",
+			inputBody: `{"daily":"1000"}`,
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().SetSpendingLimits(gomock.Any(), domain.SpendingLimitsInput{UserID: 1, Daily: &daily}).Return(&domain.UserSpendingLimits{
+					UserID:    1,
+					Override:  domain.SpendingLimits{Daily: &daily},
+					Effective: domain.SpendingLimits{Daily: &daily, Monthly: &monthly},
+				}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"limits":{"user_id":1,"override":{"daily":"1000.00","monthly":null,"max_transfer":null},"effective":{"daily":"1000.00","monthly":"10000.00","max_transfer":null}}}`,
+		},
+		{
+			name:                 "Zero limit",
+			url:                  "/users/1/limits",
+			inputBody:            `{"max_transfer":"0"}`,
+			mockBehavior:         func(s *mock_domain.MockService) {},
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"SpendingLimitsInput.MaxTransfer","Tag":"min","Value":"1"}]}`,
+		},
+		{
+			name:                 "Invalid user id",
+			url:                  "/users/0/limits",
+			inputBody:            `{}`,
+			mockBehavior:         func(s *mock_domain.MockService) {},
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"SpendingLimitsInput.UserID","Tag":"required","Value":""}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service)
+
+			handler := NewHandler(service, nil, nil)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Put("/users/:id/limits", handler.CheckSpendingLimitsInput, handler.SetSpendingLimits)
+
+			request := httptest.NewRequest("PUT", test.url, strings.NewReader(test.inputBody))
+			request.Header.Set("Content-Type", "application/json")
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+		})
+	}
+}
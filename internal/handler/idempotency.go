@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength  = 255
+	idempotencyKeyInProgress = 0
+)
+
+// CheckIdempotencyKey makes money-moving requests safe to retry. The first
+// request with a given "Idempotency-Key" header is executed and its response
+// is stored, retries with the same key and body get the stored response back,
+// and reusing the key for a different request is rejected with 409.
+func (h *Handler) CheckIdempotencyKey(c *fiber.Ctx) error {
+	key := c.Get(HeaderIdempotencyKey)
+	if key == "" {
+		return c.Next()
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return domain.ErrInvalidInput.WithMessage(`"Idempotency-Key" header is too long`)
+	}
+
+	// Keys are chosen by clients, so each client gets its own key space.
+	if id := clientID(c); id != "" {
+		key = id + ":" + key
+	}
+
+	idempotencyKey := &domain.IdempotencyKey{
+		Key:         key,
+		RequestHash: hashRequest(c),
+	}
+
+	created, err := h.service.CreateIdempotencyKey(c.UserContext(), idempotencyKey)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return h.replayIdempotentResponse(c, idempotencyKey)
+	}
+
+	// Errors are rendered here rather than by the application error handler,
+	// so that the response can be stored like any other.
+	if err := c.Next(); err != nil {
+		if err := ErrorHandler(c, err); err != nil {
+			_ = h.service.DeleteIdempotencyKey(c.UserContext(), key)
+			return err
+		}
+	}
+
+	// Server errors and rate limited requests are not stored, so the client
+	// can retry the request once the problem is gone.
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusInternalServerError || status == fiber.StatusTooManyRequests {
+		_ = h.service.DeleteIdempotencyKey(c.UserContext(), key)
+		return nil
+	}
+
+	idempotencyKey.ResponseStatus = status
+	idempotencyKey.ResponseBody = append([]byte(nil), c.Response().Body()...)
+
+	// If the response cannot be stored the key stays "in progress" until it
+	// expires: rejecting retries is safer than executing the operation twice.
+	_ = h.service.UpdateIdempotencyKey(c.UserContext(), idempotencyKey)
+
+	return nil
+}
+
+func (h *Handler) replayIdempotentResponse(c *fiber.Ctx, idempotencyKey *domain.IdempotencyKey) error {
+	stored, err := h.service.GetIdempotencyKey(c.UserContext(), idempotencyKey.Key)
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.ResponseStatus == idempotencyKeyInProgress {
+		return domain.ErrConflict.WithMessage("request with that idempotency key is still in progress")
+	}
+	if stored.RequestHash != idempotencyKey.RequestHash {
+		return domain.ErrConflict.WithMessage("idempotency key was already used for another request")
+	}
+
+	c.Set(HeaderIdempotentReplayed, "true")
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(stored.ResponseStatus).Send(stored.ResponseBody)
+}
+
+// hashRequest identifies a request by its method, path and body.
+func hashRequest(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(c.Path()))
+	hash.Write([]byte{0})
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_CheckIdempotencyKey(t *testing.T) {
+	const inputBody = `{"user_id":1,"amount":10,"type":"add"}`
+
+	// hash of "POST", "/" and inputBody as computed by hashRequest
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	var requestHash string
+	app.Post("", func(ctx *fiber.Ctx) error {
+		requestHash = hashRequest(ctx)
+		return nil
+	})
+	_, err := app.Test(httptest.NewRequest("POST", "/", strings.NewReader(inputBody)))
+	assert.NoError(t, err)
+
+	type mockBehavior func(s *mock_domain.MockService)
+
+	tests := []struct {
+		name                 string
+		idempotencyKey       string
+		inputBody            string
+		mockBehavior         mockBehavior
+		handlerStatusCode    int
+		expectedHandlerCalls int
+		expectedStatusCode   int
+		expectedResponseBody string
+		expectedReplayed     string
+	}{
+		{
+			name:                 "Without key",
+			inputBody:            inputBody,
+			mockBehavior:         func(s *mock_domain.MockService) {},
+			handlerStatusCode:    fiber.StatusOK,
+			expectedHandlerCalls: 1,
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"operation completed"}`,
+		},
+		{
+			name:           "First request",
+			idempotencyKey: "key-1",
+			inputBody:      inputBody,
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().CreateIdempotencyKey(gomock.Any(), &domain.IdempotencyKey{Key: "key-1", RequestHash: requestHash}).Return(true, nil)
+				s.EXPECT().UpdateIdempotencyKey(gomock.Any(), &domain.IdempotencyKey{
+					Key:            "key-1",
+					RequestHash:    requestHash,
+					ResponseStatus: fiber.StatusOK,
+					ResponseBody:   []byte(`{"message":"operation completed"}`),
+				}).Return(nil)
+			},
+			handlerStatusCode:    fiber.StatusOK,
+			expectedHandlerCalls: 1,
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"operation completed"}`,
+		},
+		{
+			name:           "Server error is not stored",
+			idempotencyKey: "key-1",
+			inputBody:      inputBody,
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, nil)
+				s.EXPECT().DeleteIdempotencyKey(gomock.Any(), "key-1").Return(nil)
+			},
+			handlerStatusCode:    fiber.StatusInternalServerError,
+			expectedHandlerCalls: 1,
+			expectedStatusCode:   fiber.StatusInternalServerError,
+			expectedResponseBody: `{"message":"operation completed"}`,
+		},
+		{
+			name:           "Rate limited request is not stored",
+			idempotencyKey: "key-1",
+			inputBody:      inputBody,
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any()).Return(true, nil)
+				s.EXPECT().DeleteIdempotencyKey(gomock.Any(), "key-1").Return(nil)
+			},
+			handlerStatusCode:    fiber.StatusTooManyRequests,
+			expectedHandlerCalls: 1,
+			expectedStatusCode:   fiber.StatusTooManyRequests,
+			expectedResponseBody: `{"message":"operation completed"}`,
+		},
+		{
+			name:           "Replay",
+			idempotencyKey: "key-1",
+			inputBody:      inputBody,
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any()).Return(false, nil)
+				s.EXPECT().GetIdempotencyKey(gomock.Any(), "key-1").Return(&domain.IdempotencyKey{
+					Key:            "key-1",
+					RequestHash:    requestHash,
+					ResponseStatus: fiber.StatusOK,
+					ResponseBody:   []byte(`{"message":"operation completed"}`),
+				}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"operation completed"}`,
+			expectedReplayed:     "true",
+		},
+		{
+			name:           "Key reused with another body",
+			idempotencyKey: "key-1",
+			inputBody:      `{"user_id":1,"amount":20,"type":"add"}`,
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any()).Return(false, nil)
+				s.EXPECT().GetIdempotencyKey(gomock.Any(), "key-1").Return(&domain.IdempotencyKey{
+					Key:            "key-1",
+					RequestHash:    requestHash,
+					ResponseStatus: fiber.StatusOK,
+					ResponseBody:   []byte(`{"message":"operation completed"}`),
+				}, nil)
+			},
+			expectedStatusCode:   fiber.StatusConflict,
+			expectedResponseBody: `{"code":"conflict","message":"idempotency key was already used for another request"}`,
+		},
+		{
+			name:           "First request still in progress",
+			idempotencyKey: "key-1",
+			inputBody:      inputBody,
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any()).Return(false, nil)
+				s.EXPECT().GetIdempotencyKey(gomock.Any(), "key-1").Return(&domain.IdempotencyKey{
+					Key:         "key-1",
+					RequestHash: requestHash,
+				}, nil)
+			},
+			expectedStatusCode:   fiber.StatusConflict,
+			expectedResponseBody: `{"code":"conflict","message":"request with that idempotency key is still in progress"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service)
+
+			handler := NewHandler(service, nil, nil)
+
+			handlerCalls := 0
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Post("", handler.CheckIdempotencyKey, func(ctx *fiber.Ctx) error {
+				handlerCalls++
+				return ctx.Status(test.handlerStatusCode).JSON(&fiber.Map{
+					"message": "operation completed",
+				})
+			})
+
+			request := httptest.NewRequest("POST", "/", strings.NewReader(test.inputBody))
+			request.Header.Add("Content-Type", "application/json")
+			if test.idempotencyKey != "" {
+				request.Header.Add(HeaderIdempotencyKey, test.idempotencyKey)
+			}
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+			assert.Equal(t, response.Header.Get(HeaderIdempotentReplayed), test.expectedReplayed)
+			assert.Equal(t, handlerCalls, test.expectedHandlerCalls)
+		})
+	}
+}
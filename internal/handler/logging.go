@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"time"
+)
+
+const HeaderRequestID = fiber.HeaderXRequestID
+
+// RequestID takes the request id from the "X-Request-ID" header or generates
+// one, echoes it in the response and puts a logger carrying it into the user
+// context, so that every record logged for the request has its id.
+func RequestID(c *fiber.Ctx) error {
+	requestID := c.Get(HeaderRequestID)
+	if !logging.ValidRequestID(requestID) {
+		generated, err := logging.NewRequestID()
+		if err != nil {
+			return err
+		}
+		requestID = generated
+	}
+
+	c.Set(HeaderRequestID, requestID)
+
+	ctx := c.UserContext()
+	c.SetUserContext(logging.NewContext(ctx, logging.FromContext(ctx).With("request_id", requestID)))
+	return c.Next()
+}
+
+// AccessLog logs every request after it is served. The record carries the
+// request id and, for authenticated requests, the client id.
+func AccessLog(c *fiber.Ctx) error {
+	started := time.Now()
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	level := logging.LevelInfo
+	switch {
+	case status >= fiber.StatusInternalServerError:
+		level = logging.LevelError
+	case status >= fiber.StatusBadRequest:
+		level = logging.LevelWarn
+	}
+
+	logging.FromContext(c.UserContext()).Log(level, "request served",
+		"method", c.Method(),
+		"path", c.Path(),
+		"route", c.Route().Path,
+		"status", status,
+		"duration_ms", float64(time.Since(started).Microseconds())/1000,
+	)
+	return err
+}
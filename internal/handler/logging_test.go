@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_RequestID(t *testing.T) {
+	tests := []struct {
+		name              string
+		requestID         string
+		expectedRequestID string
+	}{
+		{
+			name:              "Taken from request",
+			requestID:         "req-42",
+			expectedRequestID: "req-42",
+		},
+		{
+			name: "Generated when missing",
+		},
+		{
+			name:      "Generated when invalid",
+			requestID: "two words",
+		},
+		{
+			name:      "Generated when too long",
+			requestID: strings.Repeat("a", logging.MaxRequestIDLength+1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			app := newLoggingTestApp(logging.New(buf, logging.LevelInfo))
+
+			req := httptest.NewRequest("GET", "/users/1", nil)
+			if test.requestID != "" {
+				req.Header.Set(HeaderRequestID, test.requestID)
+			}
+
+			resp, err := app.Test(req, -1)
+			assert.NoError(t, err)
+
+			requestID := resp.Header.Get(HeaderRequestID)
+			if test.expectedRequestID != "" {
+				assert.Equal(t, requestID, test.expectedRequestID)
+			} else {
+				assert.Len(t, requestID, 32)
+			}
+
+			records := decodeRecords(t, buf)
+			if assert.Len(t, records, 2) {
+				assert.Equal(t, records[0]["msg"], "loaded user")
+				assert.Equal(t, records[0]["request_id"], requestID)
+				assert.Equal(t, records[1]["request_id"], requestID)
+			}
+		})
+	}
+}
+
+func TestHandler_AccessLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	app := newLoggingTestApp(logging.New(buf, logging.LevelInfo))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/users/2", nil), -1)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.StatusCode, fiber.StatusNotFound)
+
+	records := decodeRecords(t, buf)
+	if assert.Len(t, records, 1) {
+		record := records[0]
+		assert.Equal(t, record["level"], "WARN")
+		assert.Equal(t, record["msg"], "request served")
+		assert.Equal(t, record["method"], "GET")
+		assert.Equal(t, record["path"], "/users/2")
+		assert.Equal(t, record["route"], "/users/:id")
+		assert.Equal(t, record["status"], float64(fiber.StatusNotFound))
+		assert.Equal(t, record["client_id"], "shop")
+		assert.Contains(t, record, "duration_ms")
+	}
+}
+
+// newLoggingTestApp serves GET /users/:id, logging one record for user 1 and
+// failing with domain.ErrUserNotFound for any other. The client "shop" is
+// added to the logger the way Authenticate does.
+func newLoggingTestApp(logger *logging.Logger) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(logging.NewContext(context.Background(), logger))
+		return c.Next()
+	})
+	app.Use(RequestID, AccessLog, RenderErrors)
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		c.SetUserContext(logging.NewContext(ctx, logging.FromContext(ctx).With("client_id", "shop")))
+
+		if c.Params("id") != "1" {
+			return domain.ErrUserNotFound
+		}
+		logging.FromContext(c.UserContext()).Info("loaded user")
+		return c.SendStatus(fiber.StatusOK)
+	})
+	return app
+}
+
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
@@ -3,37 +3,60 @@ package handler
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"strings"
 )
 
 func (h *Handler) CheckGetBalanceInput(c *fiber.Ctx) error {
 	getBalanceInput := domain.GetBalanceInput{}
 
 	if err := c.BodyParser(&getBalanceInput); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": "parsing data from request body failed with error: " + err.Error(),
-		})
+		return invalidRequestBody(err)
 	}
 
-	if err := ValidateGetBalanceInput(getBalanceInput); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": "invalid request body",
-			"errors":  err,
-		})
+	getBalanceInput.Currency = strings.ToUpper(c.Query("currency"))
+
+	if err := traceValidation(c, "ValidateGetBalanceInput", func() []*ErrorResponse { return ValidateGetBalanceInput(getBalanceInput) }); err != nil {
+		return validationFailed("invalid request body", err)
 	}
 
-	user, err := h.service.GetUser(getBalanceInput.ID)
+	c.Locals("getBalanceInput", getBalanceInput)
+	return c.Next()
+}
+
+func (h *Handler) CheckGetUserBalanceInput(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
-			"message": `getting user with that "user_id" from db failed with error: ` + err.Error(),
-		})
+		return invalidPathParam("id", err)
 	}
-	if user == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": `there is no user with that "user_id"`,
-		})
+
+	getBalanceInput := domain.GetBalanceInput{
+		ID:       userID,
+		Currency: strings.ToUpper(c.Query("currency")),
 	}
 
-	c.Locals("user", user)
+	if err := traceValidation(c, "ValidateGetBalanceInput", func() []*ErrorResponse { return ValidateGetBalanceInput(getBalanceInput) }); err != nil {
+		return validationFailed("invalid request parameters", err)
+	}
+
+	c.Locals("getBalanceInput", getBalanceInput)
+	return c.Next()
+}
+
+func (h *Handler) CheckGetUserInput(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil {
+		return invalidPathParam("id", err)
+	}
+
+	getUserInput := domain.GetUserInput{
+		ID: userID,
+	}
+
+	if err := traceValidation(c, "ValidateGetUserInput", func() []*ErrorResponse { return ValidateGetUserInput(getUserInput) }); err != nil {
+		return validationFailed("invalid request parameters", err)
+	}
+
+	c.Locals("getUserInput", getUserInput)
 	return c.Next()
 }
 
@@ -41,101 +64,188 @@ func (h *Handler) CheckP2PInput(c *fiber.Ctx) error {
 	p2pInput := domain.P2PInput{}
 
 	if err := c.BodyParser(&p2pInput); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": "parsing data from request body failed with error: " + err.Error(),
-		})
+		return invalidRequestBody(err)
+	}
+
+	if err := traceValidation(c, "ValidateP2PInput", func() []*ErrorResponse { return ValidateP2PInput(p2pInput) }); err != nil {
+		return validationFailed("invalid request body", err)
+	}
+
+	c.Locals("p2pInput", p2pInput)
+	return c.Next()
+}
+
+func (h *Handler) CheckBalanceOperationInput(c *fiber.Ctx) error {
+	balanceOperationInput := domain.BalanceOperationInput{}
+
+	if err := c.BodyParser(&balanceOperationInput); err != nil {
+		return invalidRequestBody(err)
 	}
 
-	if err := ValidateP2PInput(p2pInput); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": "invalid request body",
-			"errors":  err,
-		})
+	if err := traceValidation(c, "ValidateBalanceOperationInput", func() []*ErrorResponse { return ValidateBalanceOperationInput(balanceOperationInput) }); err != nil {
+		return validationFailed("invalid request body", err)
 	}
 
-	fromUser, err := h.service.GetUser(p2pInput.FromUserID)
+	c.Locals("balanceOperationInput", balanceOperationInput)
+	return c.Next()
+}
+
+func (h *Handler) CheckListTransactionsInput(c *fiber.Ctx) error {
+	transactionFilter := domain.TransactionFilter{}
+
+	if err := c.QueryParser(&transactionFilter); err != nil {
+		return invalidQueryString(err)
+	}
+
+	userID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
-			"message": `getting user with that "from_user_id" from db failed with error: ` + err.Error(),
-		})
+		return invalidPathParam("id", err)
 	}
-	if fromUser == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": `there is no user with that "from_user_id"`,
-		})
+	transactionFilter.UserID = userID
+
+	if err := traceValidation(c, "ValidateTransactionFilter", func() []*ErrorResponse { return ValidateTransactionFilter(transactionFilter) }); err != nil {
+		return validationFailed("invalid request parameters", err)
 	}
 
-	if fromUser.Balance < p2pInput.Amount {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": "not enough balance to make transfer",
-		})
+	user, err := h.service.GetUser(c.UserContext(), transactionFilter.UserID)
+	if err != nil {
+		return err
 	}
+	if user == nil {
+		return domain.ErrUserNotFound.WithMessage(`there is no user with that "id"`)
+	}
+
+	c.Locals("transactionFilter", transactionFilter)
+	return c.Next()
+}
+
+func (h *Handler) CheckReserveInput(c *fiber.Ctx) error {
+	reservationInput := domain.ReservationInput{}
 
-	toUser, err := h.service.GetUser(p2pInput.ToUserID)
+	if err := c.BodyParser(&reservationInput); err != nil {
+		return invalidRequestBody(err)
+	}
+
+	if err := traceValidation(c, "ValidateReservationInput", func() []*ErrorResponse { return ValidateReservationInput(reservationInput) }); err != nil {
+		return validationFailed("invalid request body", err)
+	}
+
+	c.Locals("reservationInput", reservationInput)
+	return c.Next()
+}
+
+func (h *Handler) CheckReservationInput(c *fiber.Ctx) error {
+	reservationInput := domain.ReservationInput{}
+
+	if err := c.BodyParser(&reservationInput); err != nil {
+		return invalidRequestBody(err)
+	}
+
+	if err := traceValidation(c, "ValidateReservationInput", func() []*ErrorResponse { return ValidateReservationInput(reservationInput) }); err != nil {
+		return validationFailed("invalid request body", err)
+	}
+
+	c.Locals("reservationInput", reservationInput)
+	return c.Next()
+}
+
+func (h *Handler) CheckRevenueReportInput(c *fiber.Ctx) error {
+	revenueReportInput := domain.RevenueReportInput{}
+
+	if err := c.QueryParser(&revenueReportInput); err != nil {
+		return invalidQueryString(err)
+	}
+
+	if err := traceValidation(c, "ValidateRevenueReportInput", func() []*ErrorResponse { return ValidateRevenueReportInput(revenueReportInput) }); err != nil {
+		return validationFailed("invalid request parameters", err)
+	}
+
+	c.Locals("revenueReportInput", revenueReportInput)
+	return c.Next()
+}
+
+func (h *Handler) CheckWebhookID(c *fiber.Ctx) error {
+	webhookID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
-			"message": `getting user with that "to_user_id" from db failed with error: ` + err.Error(),
-		})
+		return invalidPathParam("id", err)
 	}
-	if toUser == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": `there is no user with that "to_user_id"`,
-		})
+	if webhookID <= 0 {
+		return domain.ErrInvalidInput.WithMessage(`"id" must be positive`)
 	}
 
-	c.Locals("p2pInput", p2pInput)
+	c.Locals("webhookID", webhookID)
 	return c.Next()
 }
 
-func (h *Handler) CheckBalanceOperationInput(c *fiber.Ctx) error {
-	balanceOperationInput := domain.BalanceOperationInput{}
+func (h *Handler) CheckWebhookInput(c *fiber.Ctx) error {
+	webhookInput := domain.WebhookInput{}
 
-	if err := c.BodyParser(&balanceOperationInput); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": "parsing data from request body failed with error: " + err.Error(),
-		})
+	if err := c.BodyParser(&webhookInput); err != nil {
+		return invalidRequestBody(err)
 	}
 
-	if err := ValidateBalanceOperationInput(balanceOperationInput); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-			"message": "invalid request body",
-			"errors":  err,
-		})
+	if err := traceValidation(c, "ValidateWebhookInput", func() []*ErrorResponse { return ValidateWebhookInput(webhookInput) }); err != nil {
+		return validationFailed("invalid request body", err)
 	}
 
-	user, err := h.service.GetUser(balanceOperationInput.UserID)
+	c.Locals("webhookInput", webhookInput)
+	return c.Next()
+}
+
+func (h *Handler) CheckWebhookAttemptFilter(c *fiber.Ctx) error {
+	webhookAttemptFilter := domain.WebhookAttemptFilter{}
+
+	if err := c.QueryParser(&webhookAttemptFilter); err != nil {
+		return invalidQueryString(err)
+	}
+
+	webhookID, err := c.ParamsInt("id")
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
-			"message": `getting user with that "user_id" from db failed with error: ` + err.Error(),
-		})
+		return invalidPathParam("id", err)
 	}
-	if user == nil {
-		if balanceOperationInput.Type == "subtract" {
-			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-				"message": `there is no user with that "user_id"`,
-			})
-		}
-		user = &domain.User{
-			ID: balanceOperationInput.UserID,
-		}
-		err := h.service.CreateUser(user)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
-				"massage": `creating user with that "user_id" in db failed with error: ` + err.Error(),
-			})
-		}
-	}
-
-	if balanceOperationInput.Type == "subtract" {
-		if user.Balance < balanceOperationInput.Amount {
-			return c.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
-				"message": "not enough balance to make operation",
-			})
-		}
-	}
-
-	c.Locals("user", user)
-	c.Locals("operationAmount", balanceOperationInput.Amount)
-	c.Locals("operationType", balanceOperationInput.Type)
+	webhookAttemptFilter.WebhookID = webhookID
+
+	if err := traceValidation(c, "ValidateWebhookAttemptFilter", func() []*ErrorResponse { return ValidateWebhookAttemptFilter(webhookAttemptFilter) }); err != nil {
+		return validationFailed("invalid request parameters", err)
+	}
+
+	c.Locals("webhookAttemptFilter", webhookAttemptFilter)
+	return c.Next()
+}
+
+// CheckSpendingLimitsUserID reads the user whose limits an administrator
+// manages. It is kept apart from "getUserInput", so that managing the limits
+// does not count against the rate limits of the user.
+func (h *Handler) CheckSpendingLimitsUserID(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil {
+		return invalidPathParam("id", err)
+	}
+	if userID <= 0 {
+		return domain.ErrInvalidInput.WithMessage(`"id" must be positive`)
+	}
+
+	c.Locals("spendingLimitsUserID", userID)
+	return c.Next()
+}
+
+func (h *Handler) CheckSpendingLimitsInput(c *fiber.Ctx) error {
+	spendingLimitsInput := domain.SpendingLimitsInput{}
+
+	if err := c.BodyParser(&spendingLimitsInput); err != nil {
+		return invalidRequestBody(err)
+	}
+
+	userID, err := c.ParamsInt("id")
+	if err != nil {
+		return invalidPathParam("id", err)
+	}
+	spendingLimitsInput.UserID = userID
+
+	if err := traceValidation(c, "ValidateSpendingLimitsInput", func() []*ErrorResponse { return ValidateSpendingLimitsInput(spendingLimitsInput) }); err != nil {
+		return validationFailed("invalid request body", err)
+	}
+
+	c.Locals("spendingLimitsInput", spendingLimitsInput)
 	return c.Next()
 }
@@ -13,28 +13,26 @@ import (
 )
 
 func TestHandler_CheckGetBalanceInput(t *testing.T) {
-	type mockBehavior func(s *mock_domain.MockService, userID int, user *domain.User)
-
 	tests := []struct {
 		name                 string
 		inputBody            string
+		inputQuery           string
 		inputObject          domain.GetBalanceInput
-		user                 domain.User
-		mockBehavior         mockBehavior
 		expectedStatusCode   int
 		expectedResponseBody string
 	}{
 		{
-			name:        "OK",
-			inputBody:   `{"user_id":1}`,
-			inputObject: domain.GetBalanceInput{ID: 1},
-			user: domain.User{
-				ID:      1,
-				Balance: 0,
-			},
-			mockBehavior: func(s *mock_domain.MockService, userID int, user *domain.User) {
-				s.EXPECT().GetUser(userID).Return(user, nil)
-			},
+			name:                 "OK",
+			inputBody:            `{"user_id":1}`,
+			inputObject:          domain.GetBalanceInput{ID: 1},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"ok"}`,
+		},
+		{
+			name:                 "OK with currency",
+			inputBody:            `{"user_id":1}`,
+			inputQuery:           "?currency=usd",
+			inputObject:          domain.GetBalanceInput{ID: 1, Currency: "USD"},
 			expectedStatusCode:   fiber.StatusOK,
 			expectedResponseBody: `{"message":"ok"}`,
 		},
@@ -42,19 +40,15 @@ func TestHandler_CheckGetBalanceInput(t *testing.T) {
 			name:                 "Invalid user_id",
 			inputBody:            `{"user_id":-1}`,
 			inputObject:          domain.GetBalanceInput{},
-			user:                 domain.User{},
-			mockBehavior:         func(s *mock_domain.MockService, userID int, user *domain.User) {},
 			expectedStatusCode:   fiber.StatusBadRequest,
-			expectedResponseBody: `{"errors":[{"FailedField":"GetBalanceInput.ID","Tag":"min","Value":"0"}],"message":"invalid request body"}`,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"GetBalanceInput.ID","Tag":"min","Value":"0"}]}`,
 		},
 		{
 			name:                 "Required user_id",
 			inputBody:            `{}`,
 			inputObject:          domain.GetBalanceInput{},
-			user:                 domain.User{},
-			mockBehavior:         func(s *mock_domain.MockService, userID int, user *domain.User) {},
 			expectedStatusCode:   fiber.StatusBadRequest,
-			expectedResponseBody: `{"errors":[{"FailedField":"GetBalanceInput.ID","Tag":"required","Value":""}],"message":"invalid request body"}`,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"GetBalanceInput.ID","Tag":"required","Value":""}]}`,
 		},
 	}
 
@@ -64,19 +58,18 @@ func TestHandler_CheckGetBalanceInput(t *testing.T) {
 			defer c.Finish()
 
 			service := mock_domain.NewMockService(c)
-			test.mockBehavior(service, test.inputObject.ID, &test.user)
 
-			handler := NewHandler(service)
+			handler := NewHandler(service, nil, nil)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Get("", handler.CheckGetBalanceInput, func(ctx *fiber.Ctx) error {
-				assert.Equal(t, ctx.Locals("user").(*domain.User), &test.user)
+				assert.Equal(t, ctx.Locals("getBalanceInput").(domain.GetBalanceInput), test.inputObject)
 				return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 					"message": "ok",
 				})
 			})
 
-			request := httptest.NewRequest("GET", "/", strings.NewReader(test.inputBody))
+			request := httptest.NewRequest("GET", "/"+test.inputQuery, strings.NewReader(test.inputBody))
 			request.Header.Add("Content-Type", "application/json")
 
 			response, err := app.Test(request)
@@ -92,15 +85,10 @@ func TestHandler_CheckGetBalanceInput(t *testing.T) {
 }
 
 func TestHandler_CheckP2PInput(t *testing.T) {
-	type mockBehavior func(s *mock_domain.MockService, input domain.P2PInput, fromUser, toUser *domain.User)
-
 	tests := []struct {
 		name                 string
 		inputBody            string
 		inputObject          domain.P2PInput
-		fromUser             domain.User
-		toUser               domain.User
-		mockBehavior         mockBehavior
 		expectedStatusCode   int
 		expectedResponseBody string
 	}{
@@ -110,64 +98,24 @@ func TestHandler_CheckP2PInput(t *testing.T) {
 			inputObject: domain.P2PInput{
 				FromUserID: 1,
 				ToUserID:   2,
-				Amount:     10,
-			},
-			fromUser: domain.User{
-				ID:      1,
-				Balance: 10,
-			},
-			toUser: domain.User{
-				ID:      2,
-				Balance: 0,
-			},
-			mockBehavior: func(s *mock_domain.MockService, input domain.P2PInput, fromUser, toUser *domain.User) {
-				s.EXPECT().GetUser(input.FromUserID).Return(fromUser, nil)
-				s.EXPECT().GetUser(input.ToUserID).Return(toUser, nil)
+				Amount:     rubles(10),
 			},
 			expectedStatusCode:   fiber.StatusOK,
 			expectedResponseBody: `{"message":"ok"}`,
 		},
-		{
-			name:      "Too much amount",
-			inputBody: `{"from_user_id":1,"to_user_id":2,"amount":100}`,
-			inputObject: domain.P2PInput{
-				FromUserID: 1,
-				ToUserID:   2,
-				Amount:     100,
-			},
-			fromUser: domain.User{
-				ID:      1,
-				Balance: 10,
-			},
-			toUser: domain.User{
-				ID:      2,
-				Balance: 0,
-			},
-			mockBehavior: func(s *mock_domain.MockService, input domain.P2PInput, fromUser, toUser *domain.User) {
-				s.EXPECT().GetUser(input.FromUserID).Return(fromUser, nil)
-			},
-			expectedStatusCode:   fiber.StatusBadRequest,
-			expectedResponseBody: `{"message":"not enough balance to make transfer"}`,
-		},
 		{
 			name:                 "Invalid input 1",
 			inputBody:            `{"from_user_id":1,"to_user_id":1,"amount":10}`,
 			inputObject:          domain.P2PInput{},
-			fromUser:             domain.User{},
-			toUser:               domain.User{},
-			mockBehavior:         func(s *mock_domain.MockService, input domain.P2PInput, fromUser, toUser *domain.User) {},
 			expectedStatusCode:   fiber.StatusBadRequest,
-			expectedResponseBody: `{"errors":[{"FailedField":"ToUserID","Tag":"nefield","Value":"FromUserID"}],"message":"invalid request body"}`,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"ToUserID","Tag":"nefield","Value":"FromUserID"}]}`,
 		},
 		{
 			name:                 "Invalid input 2",
 			inputBody:            `{"from_user_id":-1,"to_user_id":1,"amount":10}`,
 			inputObject:          domain.P2PInput{},
-			fromUser:             domain.User{},
-			toUser:               domain.User{},
-			mockBehavior:         func(s *mock_domain.MockService, input domain.P2PInput, fromUser, toUser *domain.User) {},
 			expectedStatusCode:   fiber.StatusBadRequest,
-			expectedResponseBody: `{"errors":[{"FailedField":"FromUserID","Tag":"min","Value":"0"}],"message":"invalid request body"}`,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"FromUserID","Tag":"min","Value":"0"}]}`,
 		},
 	}
 
@@ -177,11 +125,10 @@ func TestHandler_CheckP2PInput(t *testing.T) {
 			defer c.Finish()
 
 			service := mock_domain.NewMockService(c)
-			test.mockBehavior(service, test.inputObject, &test.fromUser, &test.toUser)
 
-			handler := NewHandler(service)
+			handler := NewHandler(service, nil, nil)
 
-			app := fiber.New()
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
 			app.Post("", handler.CheckP2PInput, func(ctx *fiber.Ctx) error {
 				assert.Equal(t, ctx.Locals("p2pInput").(domain.P2PInput), test.inputObject)
 				return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
@@ -205,14 +152,10 @@ func TestHandler_CheckP2PInput(t *testing.T) {
 }
 
 func TestHandler_CheckBalanceOperationInput(t *testing.T) {
-	type mockBehavior func(s *mock_domain.MockService, userID int, user *domain.User)
-
 	tests := []struct {
 		name                 string
 		inputBody            string
 		inputObject          domain.BalanceOperationInput
-		user                 domain.User
-		mockBehavior         mockBehavior
 		expectedStatusCode   int
 		expectedResponseBody string
 	}{
@@ -221,16 +164,9 @@ func TestHandler_CheckBalanceOperationInput(t *testing.T) {
 			inputBody: `{"user_id":1,"amount":10,"type":"add"}`,
 			inputObject: domain.BalanceOperationInput{
 				UserID: 1,
-				Amount: 10,
+				Amount: rubles(10),
 				Type:   "add",
 			},
-			user: domain.User{
-				ID:      1,
-				Balance: 0,
-			},
-			mockBehavior: func(s *mock_domain.MockService, userID int, user *domain.User) {
-				s.EXPECT().GetUser(userID).Return(user, nil)
-			},
 			expectedStatusCode:   fiber.StatusOK,
 			expectedResponseBody: `{"message":"ok"}`,
 		},
@@ -238,43 +174,100 @@ func TestHandler_CheckBalanceOperationInput(t *testing.T) {
 			name:                 "Invalid type in input",
 			inputBody:            `{"user_id":1,"amount":10,"type":"addd"}`,
 			inputObject:          domain.BalanceOperationInput{},
-			user:                 domain.User{},
-			mockBehavior:         func(s *mock_domain.MockService, userID int, user *domain.User) {},
 			expectedStatusCode:   fiber.StatusBadRequest,
-			expectedResponseBody: `{"errors":[{"FailedField":"BalanceOperationInput.Type","Tag":"oneof","Value":"add subtract"}],"message":"invalid request body"}`,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"BalanceOperationInput.Type","Tag":"oneof","Value":"add subtract"}]}`,
 		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+
+			handler := NewHandler(service, nil, nil)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Post("", handler.CheckBalanceOperationInput, func(ctx *fiber.Ctx) error {
+				assert.Equal(t, ctx.Locals("balanceOperationInput").(domain.BalanceOperationInput), test.inputObject)
+				return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+					"message": "ok",
+				})
+			})
+
+			request := httptest.NewRequest("POST", "/", strings.NewReader(test.inputBody))
+			request.Header.Add("Content-Type", "application/json")
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+		})
+	}
+}
+
+func TestHandler_CheckListTransactionsInput(t *testing.T) {
+	type mockBehavior func(s *mock_domain.MockService, userID int, user *domain.User)
+
+	tests := []struct {
+		name                 string
+		inputPath            string
+		inputObject          domain.TransactionFilter
+		user                 domain.User
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
 		{
-			name:      "User not found",
-			inputBody: `{"user_id":1,"amount":10,"type":"subtract"}`,
-			inputObject: domain.BalanceOperationInput{
+			name:      "OK",
+			inputPath: "/users/1/transactions?limit=10&offset=20&sort_by=amount&order=asc",
+			inputObject: domain.TransactionFilter{
 				UserID: 1,
-				Amount: 10,
-				Type:   "subtract",
+				Limit:  10,
+				Offset: 20,
+				SortBy: "amount",
+				Order:  "asc",
+			},
+			user: domain.User{
+				ID:      1,
+				Balance: rubles(0),
 			},
-			user: domain.User{},
 			mockBehavior: func(s *mock_domain.MockService, userID int, user *domain.User) {
-				s.EXPECT().GetUser(userID).Return(nil, nil)
+				s.EXPECT().GetUser(gomock.Any(), userID).Return(user, nil)
 			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"ok"}`,
+		},
+		{
+			name:                 "Invalid sort_by",
+			inputPath:            "/users/1/transactions?sort_by=name",
+			inputObject:          domain.TransactionFilter{},
+			mockBehavior:         func(s *mock_domain.MockService, userID int, user *domain.User) {},
 			expectedStatusCode:   fiber.StatusBadRequest,
-			expectedResponseBody: `{"message":"there is no user with that \"user_id\""}`,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request parameters","details":[{"FailedField":"TransactionFilter.SortBy","Tag":"oneof","Value":"date amount"}]}`,
 		},
 		{
-			name:      "Too much amount",
-			inputBody: `{"user_id":1,"amount":10,"type":"subtract"}`,
-			inputObject: domain.BalanceOperationInput{
-				UserID: 1,
-				Amount: 10,
-				Type:   "subtract",
-			},
-			user: domain.User{
-				ID:      1,
-				Balance: 0,
-			},
+			name:                 "Too big limit",
+			inputPath:            "/users/1/transactions?limit=1000",
+			inputObject:          domain.TransactionFilter{},
+			mockBehavior:         func(s *mock_domain.MockService, userID int, user *domain.User) {},
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request parameters","details":[{"FailedField":"TransactionFilter.Limit","Tag":"max","Value":"100"}]}`,
+		},
+		{
+			name:        "User not found",
+			inputPath:   "/users/1/transactions",
+			inputObject: domain.TransactionFilter{UserID: 1},
 			mockBehavior: func(s *mock_domain.MockService, userID int, user *domain.User) {
-				s.EXPECT().GetUser(userID).Return(user, nil)
+				s.EXPECT().GetUser(gomock.Any(), userID).Return(nil, nil)
 			},
-			expectedStatusCode:   fiber.StatusBadRequest,
-			expectedResponseBody: `{"message":"not enough balance to make operation"}`,
+			expectedStatusCode:   fiber.StatusNotFound,
+			expectedResponseBody: `{"code":"user_not_found","message":"there is no user with that \"id\""}`,
 		},
 	}
 
@@ -286,13 +279,62 @@ func TestHandler_CheckBalanceOperationInput(t *testing.T) {
 			service := mock_domain.NewMockService(c)
 			test.mockBehavior(service, test.inputObject.UserID, &test.user)
 
-			handler := NewHandler(service)
+			handler := NewHandler(service, nil, nil)
 
-			app := fiber.New()
-			app.Post("", handler.CheckBalanceOperationInput, func(ctx *fiber.Ctx) error {
-				assert.Equal(t, ctx.Locals("user").(*domain.User), &test.user)
-				assert.Equal(t, ctx.Locals("operationAmount").(int), test.inputObject.Amount)
-				assert.Equal(t, ctx.Locals("operationType").(string), test.inputObject.Type)
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/users/:id/transactions", handler.CheckListTransactionsInput, func(ctx *fiber.Ctx) error {
+				assert.Equal(t, ctx.Locals("transactionFilter").(domain.TransactionFilter), test.inputObject)
+				return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
+					"message": "ok",
+				})
+			})
+
+			request := httptest.NewRequest("GET", test.inputPath, nil)
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+		})
+	}
+}
+
+func TestHandler_CheckReservationInput(t *testing.T) {
+	tests := []struct {
+		name                 string
+		inputBody            string
+		inputObject          domain.ReservationInput
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "OK",
+			inputBody:            `{"user_id":1,"service_id":2,"order_id":3,"amount":10}`,
+			inputObject:          domain.ReservationInput{UserID: 1, ServiceID: 2, OrderID: 3, Amount: rubles(10)},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"ok"}`,
+		},
+		{
+			name:                 "Required service_id",
+			inputBody:            `{"user_id":1,"order_id":3,"amount":10}`,
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request body","details":[{"FailedField":"ReservationInput.ServiceID","Tag":"required","Value":""}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Whether the reservation exists and matches is checked by the
+			// repository, so the service is not called here.
+			handler := NewHandler(nil, nil, nil)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Post("", handler.CheckReservationInput, func(ctx *fiber.Ctx) error {
+				assert.Equal(t, ctx.Locals("reservationInput").(domain.ReservationInput), test.inputObject)
 				return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 					"message": "ok",
 				})
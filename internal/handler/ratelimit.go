@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// RateLimitClient counts the request against the limits of the authenticated
+// client and rejects it with 429 and a "Retry-After" header once they are
+// exhausted. It runs right after authentication, so that a throttled client
+// is turned away before its request is parsed or its idempotency key claimed.
+func (h *Handler) RateLimitClient(class string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := clientID(c)
+		if h.limiter == nil || id == "" {
+			return c.Next()
+		}
+
+		result, err := h.limiter.AllowClient(c.UserContext(), id, class)
+		if err != nil {
+			return err
+		}
+		c.Locals("clientRateLimit", result)
+		return enforceRateLimit(c, result, nil)
+	}
+}
+
+// RateLimitUser counts the request against the limits of the user it is
+// about. It must run after the input is parsed, so that the user is known.
+// The "X-RateLimit-*" headers describe the tighter of the client and the user
+// limits.
+func (h *Handler) RateLimitUser(class string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := userID(c)
+		if h.limiter == nil || !ok {
+			return c.Next()
+		}
+
+		result, err := h.limiter.AllowUser(c.UserContext(), id, class)
+		if err != nil {
+			return err
+		}
+		client, _ := c.Locals("clientRateLimit").(*domain.RateLimitResult)
+		return enforceRateLimit(c, result, client)
+	}
+}
+
+// enforceRateLimit rejects the request if the limit is exhausted, and
+// otherwise describes the limit in the headers unless the other limit already
+// described there is tighter. A limit of zero is not checked.
+func enforceRateLimit(c *fiber.Ctx, result, other *domain.RateLimitResult) error {
+	if result.Limit == 0 {
+		return c.Next()
+	}
+	if !result.Allowed {
+		setRateLimitHeaders(c, result)
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		return domain.ErrRateLimited.WithMessage("rate limit exceeded, retry in " + strconv.Itoa(ceilSeconds(result.RetryAfter)) + "s")
+	}
+	if other == nil || other.Limit == 0 || result.Remaining < other.Remaining {
+		setRateLimitHeaders(c, result)
+	}
+	return c.Next()
+}
+
+func setRateLimitHeaders(c *fiber.Ctx, result *domain.RateLimitResult) {
+	c.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+	c.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+	c.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.ResetAfter)))
+}
+
+// userID returns the user whose balance the parsed input reads or changes.
+func userID(c *fiber.Ctx) (int, bool) {
+	if input, ok := c.Locals("getBalanceInput").(domain.GetBalanceInput); ok {
+		return input.ID, true
+	}
+	if input, ok := c.Locals("getUserInput").(domain.GetUserInput); ok {
+		return input.ID, true
+	}
+	if filter, ok := c.Locals("transactionFilter").(domain.TransactionFilter); ok {
+		return filter.UserID, true
+	}
+	if input, ok := c.Locals("p2pInput").(domain.P2PInput); ok {
+		return input.FromUserID, true
+	}
+	if input, ok := c.Locals("balanceOperationInput").(domain.BalanceOperationInput); ok {
+		return input.UserID, true
+	}
+	if input, ok := c.Locals("reservationInput").(domain.ReservationInput); ok {
+		return input.UserID, true
+	}
+	return 0, false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_RateLimit(t *testing.T) {
+	type mockBehavior func(l *mock_domain.MockRateLimiter)
+
+	tests := []struct {
+		name                 string
+		client               *domain.Client
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+		expectedHeaders      map[string]string
+	}{
+		{
+			name:   "OK",
+			client: &domain.Client{ID: "shop"},
+			mockBehavior: func(l *mock_domain.MockRateLimiter) {
+				l.EXPECT().AllowClient(gomock.Any(), "shop", domain.RateLimitWrite).Return(&domain.RateLimitResult{Allowed: true, Limit: 20, Remaining: 12, ResetAfter: 8 * time.Second}, nil)
+				l.EXPECT().AllowUser(gomock.Any(), 1, domain.RateLimitWrite).Return(&domain.RateLimitResult{Allowed: true, Limit: 5, Remaining: 3, ResetAfter: 1500 * time.Millisecond}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"ok"}`,
+			expectedHeaders: map[string]string{
+				HeaderRateLimitLimit:     "5",
+				HeaderRateLimitRemaining: "3",
+				HeaderRateLimitReset:     "2",
+				fiber.HeaderRetryAfter:   "",
+			},
+		},
+		{
+			name:   "Client limit exceeded",
+			client: &domain.Client{ID: "shop"},
+			mockBehavior: func(l *mock_domain.MockRateLimiter) {
+				l.EXPECT().AllowClient(gomock.Any(), "shop", domain.RateLimitWrite).Return(&domain.RateLimitResult{Limit: 20, RetryAfter: 300 * time.Millisecond, ResetAfter: 20 * time.Second}, nil)
+			},
+			expectedStatusCode:   fiber.StatusTooManyRequests,
+			expectedResponseBody: `{"code":"rate_limited","message":"rate limit exceeded, retry in 1s"}`,
+			expectedHeaders: map[string]string{
+				HeaderRateLimitLimit:     "20",
+				HeaderRateLimitRemaining: "0",
+				HeaderRateLimitReset:     "20",
+				fiber.HeaderRetryAfter:   "1",
+			},
+		},
+		{
+			name:   "User limit exceeded",
+			client: &domain.Client{ID: "shop"},
+			mockBehavior: func(l *mock_domain.MockRateLimiter) {
+				l.EXPECT().AllowClient(gomock.Any(), "shop", domain.RateLimitWrite).Return(&domain.RateLimitResult{Allowed: true, Limit: 20, Remaining: 12}, nil)
+				l.EXPECT().AllowUser(gomock.Any(), 1, domain.RateLimitWrite).Return(&domain.RateLimitResult{Limit: 5, RetryAfter: 2500 * time.Millisecond, ResetAfter: 5 * time.Second}, nil)
+			},
+			expectedStatusCode:   fiber.StatusTooManyRequests,
+			expectedResponseBody: `{"code":"rate_limited","message":"rate limit exceeded, retry in 3s"}`,
+			expectedHeaders: map[string]string{
+				HeaderRateLimitLimit:     "5",
+				HeaderRateLimitRemaining: "0",
+				HeaderRateLimitReset:     "5",
+				fiber.HeaderRetryAfter:   "3",
+			},
+		},
+		{
+			name:   "Client limit is tighter",
+			client: &domain.Client{ID: "shop"},
+			mockBehavior: func(l *mock_domain.MockRateLimiter) {
+				l.EXPECT().AllowClient(gomock.Any(), "shop", domain.RateLimitWrite).Return(&domain.RateLimitResult{Allowed: true, Limit: 20, Remaining: 1, ResetAfter: 19 * time.Second}, nil)
+				l.EXPECT().AllowUser(gomock.Any(), 1, domain.RateLimitWrite).Return(&domain.RateLimitResult{Allowed: true, Limit: 5, Remaining: 3, ResetAfter: 2 * time.Second}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"ok"}`,
+			expectedHeaders: map[string]string{
+				HeaderRateLimitLimit:     "20",
+				HeaderRateLimitRemaining: "1",
+				HeaderRateLimitReset:     "19",
+			},
+		},
+		{
+			name: "Without client",
+			mockBehavior: func(l *mock_domain.MockRateLimiter) {
+				l.EXPECT().AllowUser(gomock.Any(), 1, domain.RateLimitWrite).Return(&domain.RateLimitResult{Allowed: true}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"message":"ok"}`,
+			expectedHeaders: map[string]string{
+				HeaderRateLimitLimit: "",
+			},
+		},
+		{
+			name:   "Store error",
+			client: &domain.Client{ID: "shop"},
+			mockBehavior: func(l *mock_domain.MockRateLimiter) {
+				l.EXPECT().AllowClient(gomock.Any(), "shop", domain.RateLimitWrite).Return(nil, domain.ErrInternal)
+			},
+			expectedStatusCode:   fiber.StatusInternalServerError,
+			expectedResponseBody: `{"code":"internal_error","message":"internal server error"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			limiter := mock_domain.NewMockRateLimiter(c)
+			test.mockBehavior(limiter)
+
+			handler := NewHandler(nil, nil, limiter)
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Post("/p2p", func(c *fiber.Ctx) error {
+				if test.client != nil {
+					c.Locals("client", test.client)
+				}
+				c.Locals("p2pInput", domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(10)})
+				return c.Next()
+			}, handler.RateLimitClient(domain.RateLimitWrite), handler.RateLimitUser(domain.RateLimitWrite), func(c *fiber.Ctx) error {
+				return c.JSON(fiber.Map{"message": "ok"})
+			})
+
+			response, err := app.Test(httptest.NewRequest("POST", "/p2p", nil))
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+			for header, value := range test.expectedHeaders {
+				assert.Equal(t, response.Header.Get(header), value, header)
+			}
+		})
+	}
+}
+
+func TestRouter_RateLimitsClientBeforeParsing(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	authenticator := mock_domain.NewMockAuthenticator(c)
+	authenticator.EXPECT().AuthenticateAPIKey(gomock.Any(), "ak_test").Return(&domain.Client{ID: "shop", Scopes: domain.Scopes}, nil)
+	limiter := mock_domain.NewMockRateLimiter(c)
+	limiter.EXPECT().AllowClient(gomock.Any(), "shop", domain.RateLimitWrite).Return(&domain.RateLimitResult{Limit: 20, RetryAfter: time.Second}, nil)
+
+	// Neither the idempotency key nor the invalid body is looked at.
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	Router(app.Group("/api"), NewHandler(nil, authenticator, limiter))
+
+	request := httptest.NewRequest("POST", "/api/v1/p2p", strings.NewReader(`{`))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add(HeaderAPIKey, "ak_test")
+	request.Header.Add(HeaderIdempotencyKey, "key")
+
+	response, err := app.Test(request)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, response.StatusCode, fiber.StatusTooManyRequests)
+}
@@ -2,10 +2,131 @@ package handler
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
 )
 
+const HeaderDeprecation = "Deprecation"
+
+// apiVersions lists the versions of the API, each mounted under its own
+// prefix, e.g. /api/v1. A new version gets its own routes function and an
+// entry here; older versions keep working until they are removed from the
+// list.
+var apiVersions = []struct {
+	prefix string
+	routes func(router fiber.Router, handler *Handler)
+}{
+	{prefix: "/v1", routes: routesV1},
+}
+
 func Router(api fiber.Router, handler *Handler) {
-	api.Get("/balance", handler.CheckGetBalanceInput, handler.GetBalanceByUserID)
-	api.Post("/balance", handler.CheckBalanceOperationInput, handler.MakeBalanceOperationByUserID)
-	api.Post("/p2p", handler.CheckP2PInput, handler.MakeP2PTransfer)
+	for _, version := range apiVersions {
+		version.routes(api.Group(version.prefix), handler)
+	}
+
+	// Routes registered before the API was versioned are kept unversioned for
+	// existing clients. They are built from the v1 table, so that both stay
+	// the same.
+	for _, route := range routesV1Table(handler) {
+		if legacyRoutes[route.method+" "+route.path] {
+			route.register(api, handler)
+		}
+	}
+	legacyBalance := route{
+		method:    fiber.MethodGet,
+		path:      "/balance",
+		class:     domain.RateLimitRead,
+		scope:     domain.ScopeBalanceRead,
+		userLimit: true,
+		steps:     []fiber.Handler{handler.CheckGetBalanceInput, handler.GetBalanceByUserID},
+	}
+	legacyBalance.register(api, handler, Deprecated("/api/v1/users/{id}/balance"))
+}
+
+// legacyRoutes lists the v1 routes that are also served without a version.
+var legacyRoutes = map[string]bool{
+	"POST /balance":               true,
+	"POST /p2p":                   true,
+	"POST /reserve":               true,
+	"POST /reserve/commit":        true,
+	"POST /reserve/release":       true,
+	"GET /users/:id/transactions": true,
+	"GET /reports/revenue":        true,
+}
+
+// route is an authenticated endpoint. A request is counted against the client
+// limit of its class right after authentication, then the scope is checked
+// and the steps run in order. With userLimit the request is also counted
+// against the limit of its user before the last step, once the input is
+// parsed.
+type route struct {
+	method    string
+	path      string
+	class     string
+	scope     string
+	userLimit bool
+	steps     []fiber.Handler
+}
+
+func (r route) register(router fiber.Router, handler *Handler, before ...fiber.Handler) {
+	handlers := append(before, handler.Authenticate, handler.RateLimitClient(r.class), RequireScope(r.scope))
+	last := len(r.steps) - 1
+	handlers = append(handlers, r.steps[:last]...)
+	if r.userLimit {
+		handlers = append(handlers, handler.RateLimitUser(r.class))
+	}
+	handlers = append(handlers, r.steps[last])
+
+	router.Add(r.method, r.path, handlers...)
+}
+
+func routesV1(api fiber.Router, handler *Handler) {
+	// The API description is public, everything else needs credentials.
+	api.Get("/openapi.json", handler.GetOpenAPISpec)
+	api.Get("/docs", handler.GetDocs)
+
+	for _, route := range routesV1Table(handler) {
+		route.register(api, handler)
+	}
+}
+
+func routesV1Table(handler *Handler) []route {
+	const (
+		get   = fiber.MethodGet
+		post  = fiber.MethodPost
+		put   = fiber.MethodPut
+		del   = fiber.MethodDelete
+		read  = domain.RateLimitRead
+		write = domain.RateLimitWrite
+	)
+
+	return []route{
+		{get, "/users/:id", read, domain.ScopeBalanceRead, true, []fiber.Handler{handler.CheckGetUserInput, handler.GetUserByID}},
+		{get, "/users/:id/balance", read, domain.ScopeBalanceRead, true, []fiber.Handler{handler.CheckGetUserBalanceInput, handler.GetBalanceByUserID}},
+		{get, "/users/:id/transactions", read, domain.ScopeBalanceRead, true, []fiber.Handler{handler.CheckListTransactionsInput, handler.ListTransactionsByUserID}},
+		{post, "/balance", write, domain.ScopeBalanceWrite, true, []fiber.Handler{handler.CheckIdempotencyKey, handler.CheckBalanceOperationInput, handler.MakeBalanceOperationByUserID}},
+		{post, "/p2p", write, domain.ScopeTransfer, true, []fiber.Handler{handler.CheckIdempotencyKey, handler.CheckP2PInput, handler.MakeP2PTransfer}},
+		{post, "/reserve", write, domain.ScopeBalanceWrite, true, []fiber.Handler{handler.CheckIdempotencyKey, handler.CheckReserveInput, handler.ReserveFunds}},
+		{post, "/reserve/commit", write, domain.ScopeBalanceWrite, true, []fiber.Handler{handler.CheckIdempotencyKey, handler.CheckReservationInput, handler.CommitReservation}},
+		{post, "/reserve/release", write, domain.ScopeBalanceWrite, true, []fiber.Handler{handler.CheckIdempotencyKey, handler.CheckReservationInput, handler.ReleaseReservation}},
+		{get, "/reports/revenue", read, domain.ScopeBalanceRead, true, []fiber.Handler{handler.CheckRevenueReportInput, handler.GetRevenueReport}},
+		{post, "/webhooks", write, domain.ScopeWebhooksManage, false, []fiber.Handler{handler.CheckWebhookInput, handler.CreateWebhook}},
+		{get, "/webhooks", read, domain.ScopeWebhooksManage, false, []fiber.Handler{handler.ListWebhooks}},
+		{get, "/webhooks/:id", read, domain.ScopeWebhooksManage, false, []fiber.Handler{handler.CheckWebhookID, handler.GetWebhook}},
+		{put, "/webhooks/:id", write, domain.ScopeWebhooksManage, false, []fiber.Handler{handler.CheckWebhookID, handler.CheckWebhookInput, handler.UpdateWebhook}},
+		{del, "/webhooks/:id", write, domain.ScopeWebhooksManage, false, []fiber.Handler{handler.CheckWebhookID, handler.DeleteWebhook}},
+		{get, "/webhooks/:id/attempts", read, domain.ScopeWebhooksManage, false, []fiber.Handler{handler.CheckWebhookAttemptFilter, handler.ListWebhookAttempts}},
+		{get, "/users/:id/limits", read, domain.ScopeLimitsManage, false, []fiber.Handler{handler.CheckSpendingLimitsUserID, handler.GetSpendingLimits}},
+		{put, "/users/:id/limits", write, domain.ScopeLimitsManage, false, []fiber.Handler{handler.CheckSpendingLimitsInput, handler.SetSpendingLimits}},
+		{del, "/users/:id/limits", write, domain.ScopeLimitsManage, false, []fiber.Handler{handler.CheckSpendingLimitsUserID, handler.DeleteSpendingLimits}},
+	}
+}
+
+// Deprecated marks responses of a route that is going to be removed, pointing
+// clients to the route that replaces it.
+func Deprecated(successor string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(HeaderDeprecation, "true")
+		c.Set(fiber.HeaderLink, "<"+successor+`>; rel="successor-version"`)
+		return c.Next()
+	}
 }
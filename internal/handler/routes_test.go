@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	type mockBehavior func(s *mock_domain.MockService)
+
+	tests := []struct {
+		name                 string
+		method               string
+		path                 string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+		expectedDeprecation  string
+	}{
+		{
+			name:   "Balance by path",
+			method: "GET",
+			path:   "/api/v1/users/1/balance?currency=usd",
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().GetBalance(gomock.Any(), domain.GetBalanceInput{ID: 1, Currency: "USD"}).Return(domain.NewMoney(1250, "USD"), nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"balance":"12.50","currency":"USD"}`,
+		},
+		{
+			name:                 "Balance by invalid path",
+			method:               "GET",
+			path:                 "/api/v1/users/abc/balance",
+			mockBehavior:         func(s *mock_domain.MockService) {},
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"invalid_input","message":"parsing \"id\" from path failed","details":"strconv.Atoi: parsing \"abc\": invalid syntax"}`,
+		},
+		{
+			name:      "Legacy balance",
+			method:    "GET",
+			path:      "/api/balance",
+			inputBody: `{"user_id":1}`,
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().GetBalance(gomock.Any(), domain.GetBalanceInput{ID: 1}).Return(rubles(10), nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"balance":"10.00","currency":"RUB"}`,
+			expectedDeprecation:  "true",
+		},
+		{
+			name:   "User",
+			method: "GET",
+			path:   "/api/v1/users/1",
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().GetUser(gomock.Any(), 1).Return(&domain.User{ID: 1, Balance: rubles(10), ReservedBalance: rubles(5)}, nil)
+			},
+			expectedStatusCode:   fiber.StatusOK,
+			expectedResponseBody: `{"user":{"id":1,"balance":"10.00","reserved_balance":"5.00"}}`,
+		},
+		{
+			name:   "User not found",
+			method: "GET",
+			path:   "/api/v1/users/1",
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().GetUser(gomock.Any(), 1).Return(nil, nil)
+			},
+			expectedStatusCode:   fiber.StatusNotFound,
+			expectedResponseBody: `{"code":"user_not_found","message":"there is no user with that \"id\""}`,
+		},
+		{
+			name:                 "Invalid user id",
+			method:               "GET",
+			path:                 "/api/v1/users/-1",
+			mockBehavior:         func(s *mock_domain.MockService) {},
+			expectedStatusCode:   fiber.StatusBadRequest,
+			expectedResponseBody: `{"code":"invalid_input","message":"invalid request parameters","details":[{"FailedField":"GetUserInput.ID","Tag":"min","Value":"0"}]}`,
+		},
+		{
+			name:                 "Legacy balance is not versioned",
+			method:               "GET",
+			path:                 "/api/v1/balance",
+			mockBehavior:         func(s *mock_domain.MockService) {},
+			expectedStatusCode:   fiber.StatusMethodNotAllowed,
+			expectedResponseBody: `{"code":"method_not_allowed","message":"Method Not Allowed"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service)
+
+			authenticator := mock_domain.NewMockAuthenticator(c)
+			authenticator.EXPECT().AuthenticateAPIKey(gomock.Any(), "ak_test").Return(&domain.Client{ID: "shop", Scopes: domain.Scopes}, nil).AnyTimes()
+
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			Router(app.Group("/api"), NewHandler(service, authenticator, nil))
+
+			request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.inputBody))
+			request.Header.Add("Content-Type", "application/json")
+			request.Header.Add(HeaderAPIKey, "ak_test")
+
+			response, err := app.Test(request)
+			assert.Equal(t, err, nil)
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.Equal(t, err, nil)
+
+			assert.Equal(t, string(body), test.expectedResponseBody)
+			assert.Equal(t, response.StatusCode, test.expectedStatusCode)
+			assert.Equal(t, response.Header.Get(HeaderDeprecation), test.expectedDeprecation)
+		})
+	}
+}
+
+func TestRouter_LegacyRoutes(t *testing.T) {
+	v1 := map[string]bool{}
+	for _, route := range routesV1Table(NewHandler(nil, nil, nil)) {
+		v1[route.method+" "+route.path] = true
+	}
+	for legacy := range legacyRoutes {
+		assert.True(t, v1[legacy], "%s is not a v1 route", legacy)
+	}
+}
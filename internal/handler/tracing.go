@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/lov3allmy/avito-test-go/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier lets the propagator read the trace context of a request from
+// its headers.
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+// Trace runs every request in a server span named after the route it matched,
+// e.g. "POST /api/v1/p2p". A request carrying a W3C "traceparent" header
+// continues the trace of the caller. The span is put into the user context
+// for the service and repository spans below it, and the id of the trace is
+// added to the records logged for the request.
+func Trace(c *fiber.Ctx) error {
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c: c})
+	ctx, span := tracing.Tracer().Start(ctx, c.Method()+" "+c.Path(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPMethodKey.String(c.Method()), semconv.HTTPTargetKey.String(c.OriginalURL())),
+	)
+	defer span.End()
+
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("trace_id", traceID))
+	}
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	span.SetName(c.Method() + " " + c.Route().Path)
+	span.SetAttributes(semconv.HTTPRouteKey.String(c.Route().Path), semconv.HTTPStatusCodeKey.Int(status))
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, fiber.ErrInternalServerError.Message)
+	}
+	return err
+}
+
+// traceValidation runs the validation of the request input in a span of its
+// own, so that traces tell validating a request from serving it.
+func traceValidation(c *fiber.Ctx, name string, validate func() []*ErrorResponse) []*ErrorResponse {
+	_, span := tracing.Tracer().Start(c.UserContext(), name)
+	defer span.End()
+
+	errors := validate()
+	if errors != nil {
+		span.SetStatus(codes.Error, "validation failed")
+	}
+	return errors
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler_Trace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	buf := &bytes.Buffer{}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(logging.NewContext(context.Background(), logging.New(buf, logging.LevelInfo)))
+		return c.Next()
+	})
+	app.Use(Trace, RequestID, AccessLog, RenderErrors)
+	app.Post("/p2p", func(c *fiber.Ctx) error {
+		if err := traceValidation(c, "ValidateP2PInput", func() []*ErrorResponse { return nil }); err != nil {
+			return validationFailed("invalid request body", err)
+		}
+		return domain.ErrInsufficientFunds
+	})
+
+	req := httptest.NewRequest("POST", "/p2p", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929e0e4736ce-00f067aa0ba902b7-01")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, resp.StatusCode, fiber.StatusUnprocessableEntity)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	validation, server := spans[0], spans[1]
+	assert.Equal(t, validation.Name(), "ValidateP2PInput")
+	assert.Equal(t, validation.Parent().SpanID(), server.SpanContext().SpanID())
+
+	assert.Equal(t, server.Name(), "POST /p2p")
+	assert.Equal(t, server.SpanKind(), trace.SpanKindServer)
+	assert.Equal(t, server.SpanContext().TraceID().String(), "4bf92f3577b34da6a3ce929e0e4736ce")
+	assert.Equal(t, server.Parent().SpanID().String(), "00f067aa0ba902b7")
+	assert.Contains(t, server.Attributes(), semconv.HTTPRouteKey.String("/p2p"))
+	assert.Contains(t, server.Attributes(), semconv.HTTPStatusCodeKey.Int(fiber.StatusUnprocessableEntity))
+
+	records := decodeRecords(t, buf)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, records[0]["trace_id"], "4bf92f3577b34da6a3ce929e0e4736ce")
+	}
+}
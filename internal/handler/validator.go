@@ -3,6 +3,7 @@ package handler
 import (
 	"github.com/go-playground/validator/v10"
 	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"reflect"
 )
 
 type ErrorResponse struct {
@@ -11,8 +12,18 @@ type ErrorResponse struct {
 	Value       string
 }
 
-func ValidateP2PInput(input domain.P2PInput) []*ErrorResponse {
+// newValidator returns a validator that checks domain.Money fields by their
+// minor units, so tags like "min=1" mean "at least one kopeck".
+func newValidator() *validator.Validate {
 	validate := validator.New()
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(domain.Money).MinorUnits
+	}, domain.Money{})
+	return validate
+}
+
+func ValidateP2PInput(input domain.P2PInput) []*ErrorResponse {
+	validate := newValidator()
 	var errors []*ErrorResponse
 	err := validate.Struct(input)
 	if err != nil {
@@ -27,8 +38,24 @@ func ValidateP2PInput(input domain.P2PInput) []*ErrorResponse {
 	return errors
 }
 
+func ValidateGetUserInput(input domain.GetUserInput) []*ErrorResponse {
+	validate := newValidator()
+	var errors []*ErrorResponse
+	err := validate.Struct(input)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			var element ErrorResponse
+			element.FailedField = err.StructNamespace()
+			element.Tag = err.Tag()
+			element.Value = err.Param()
+			errors = append(errors, &element)
+		}
+	}
+	return errors
+}
+
 func ValidateGetBalanceInput(input domain.GetBalanceInput) []*ErrorResponse {
-	validate := validator.New()
+	validate := newValidator()
 	var errors []*ErrorResponse
 	err := validate.Struct(input)
 	if err != nil {
@@ -44,7 +71,103 @@ func ValidateGetBalanceInput(input domain.GetBalanceInput) []*ErrorResponse {
 }
 
 func ValidateBalanceOperationInput(input domain.BalanceOperationInput) []*ErrorResponse {
-	validate := validator.New()
+	validate := newValidator()
+	var errors []*ErrorResponse
+	err := validate.Struct(input)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			var element ErrorResponse
+			element.FailedField = err.StructNamespace()
+			element.Tag = err.Tag()
+			element.Value = err.Param()
+			errors = append(errors, &element)
+		}
+	}
+	return errors
+}
+
+func ValidateTransactionFilter(input domain.TransactionFilter) []*ErrorResponse {
+	validate := newValidator()
+	var errors []*ErrorResponse
+	err := validate.Struct(input)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			var element ErrorResponse
+			element.FailedField = err.StructNamespace()
+			element.Tag = err.Tag()
+			element.Value = err.Param()
+			errors = append(errors, &element)
+		}
+	}
+	return errors
+}
+
+func ValidateReservationInput(input domain.ReservationInput) []*ErrorResponse {
+	validate := newValidator()
+	var errors []*ErrorResponse
+	err := validate.Struct(input)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			var element ErrorResponse
+			element.FailedField = err.StructNamespace()
+			element.Tag = err.Tag()
+			element.Value = err.Param()
+			errors = append(errors, &element)
+		}
+	}
+	return errors
+}
+
+func ValidateRevenueReportInput(input domain.RevenueReportInput) []*ErrorResponse {
+	validate := newValidator()
+	var errors []*ErrorResponse
+	err := validate.Struct(input)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			var element ErrorResponse
+			element.FailedField = err.StructNamespace()
+			element.Tag = err.Tag()
+			element.Value = err.Param()
+			errors = append(errors, &element)
+		}
+	}
+	return errors
+}
+
+func ValidateWebhookInput(input domain.WebhookInput) []*ErrorResponse {
+	validate := newValidator()
+	var errors []*ErrorResponse
+	err := validate.Struct(input)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			var element ErrorResponse
+			element.FailedField = err.StructNamespace()
+			element.Tag = err.Tag()
+			element.Value = err.Param()
+			errors = append(errors, &element)
+		}
+	}
+	return errors
+}
+
+func ValidateWebhookAttemptFilter(input domain.WebhookAttemptFilter) []*ErrorResponse {
+	validate := newValidator()
+	var errors []*ErrorResponse
+	err := validate.Struct(input)
+	if err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			var element ErrorResponse
+			element.FailedField = err.StructNamespace()
+			element.Tag = err.Tag()
+			element.Value = err.Param()
+			errors = append(errors, &element)
+		}
+	}
+	return errors
+}
+
+func ValidateSpendingLimitsInput(input domain.SpendingLimitsInput) []*ErrorResponse {
+	validate := newValidator()
 	var errors []*ErrorResponse
 	err := validate.Struct(input)
 	if err != nil {
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DefaultTimeout bounds a check when Health is created without a timeout.
+const DefaultTimeout = 2 * time.Second
+
+// Checker tells whether a dependency of the service is usable. An error
+// means it is not.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets a plain function be used as a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks. The service is up only when every
+// dependency is.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Health runs the checks deciding whether the service is ready to serve
+// requests.
+type Health struct {
+	timeout  time.Duration
+	names    []string
+	checkers map[string]Checker
+}
+
+func New(timeout time.Duration) *Health {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Health{
+		timeout:  timeout,
+		checkers: make(map[string]Checker),
+	}
+}
+
+// Register adds a check under the name it is reported with. A check
+// registered under a taken name replaces the old one.
+func (h *Health) Register(name string, checker Checker) {
+	if _, ok := h.checkers[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checkers[name] = checker
+}
+
+// Check runs all checks at once, failing the ones that take longer than the
+// timeout.
+func (h *Health) Check(ctx context.Context) Report {
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(h.names)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range h.names {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+			result := h.run(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(name, h.checkers[name])
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (h *Health) run(ctx context.Context, checker Checker) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := checker.Check(ctx)
+	result := CheckResult{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(started).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Live answers liveness probes: a process able to respond is alive, whatever
+// the state of its dependencies.
+func Live(c *fiber.Ctx) error {
+	return c.JSON(Report{Status: StatusUp})
+}
+
+// Ready answers readiness probes with the report of all checks, responding
+// with 503 Service Unavailable unless every dependency is up.
+func (h *Health) Ready(c *fiber.Ctx) error {
+	report := h.Check(c.UserContext())
+	if report.Status != StatusUp {
+		logging.FromContext(c.UserContext()).Warn("not ready", "checks", report.Checks)
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.JSON(report)
+}
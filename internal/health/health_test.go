@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func up(context.Context) error {
+	return nil
+}
+
+func TestHealth_Check(t *testing.T) {
+	tests := []struct {
+		name           string
+		checkers       map[string]CheckerFunc
+		expectedStatus string
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "No checks",
+			expectedStatus: StatusUp,
+		},
+		{
+			name:           "All up",
+			checkers:       map[string]CheckerFunc{"postgres": up, "migrations": up},
+			expectedStatus: StatusUp,
+		},
+		{
+			name: "One down",
+			checkers: map[string]CheckerFunc{
+				"postgres": up,
+				"migrations": func(context.Context) error {
+					return errors.New("1 migrations pending, the first is 0002_second")
+				},
+			},
+			expectedStatus: StatusDown,
+			expectedErrors: map[string]string{"migrations": "1 migrations pending, the first is 0002_second"},
+		},
+		{
+			name: "Timed out",
+			checkers: map[string]CheckerFunc{
+				"postgres": func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			expectedStatus: StatusDown,
+			expectedErrors: map[string]string{"postgres": context.DeadlineExceeded.Error()},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := New(10 * time.Millisecond)
+			for name, checker := range test.checkers {
+				h.Register(name, checker)
+			}
+
+			report := h.Check(context.Background())
+			assert.Equal(t, report.Status, test.expectedStatus)
+			assert.Len(t, report.Checks, len(test.checkers))
+			for name, result := range report.Checks {
+				assert.Equal(t, result.Error, test.expectedErrors[name])
+				if result.Error == "" {
+					assert.Equal(t, result.Status, StatusUp)
+				} else {
+					assert.Equal(t, result.Status, StatusDown)
+				}
+			}
+		})
+	}
+}
+
+func TestHealth_Ready(t *testing.T) {
+	h := New(time.Second)
+	app := fiber.New()
+	app.Get("/healthz", Live)
+	app.Get("/readyz", h.Ready)
+
+	get := func(path string) (int, Report) {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		require.NoError(t, err)
+		var report Report
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		return resp.StatusCode, report
+	}
+
+	h.Register("postgres", CheckerFunc(up))
+	status, report := get("/readyz")
+	assert.Equal(t, status, fiber.StatusOK)
+	assert.Equal(t, report.Status, StatusUp)
+	assert.Equal(t, report.Checks["postgres"].Status, StatusUp)
+
+	h.Register("postgres", CheckerFunc(func(context.Context) error {
+		return errors.New("connection refused")
+	}))
+	status, report = get("/readyz")
+	assert.Equal(t, status, fiber.StatusServiceUnavailable)
+	assert.Equal(t, report.Status, StatusDown)
+	assert.Equal(t, report.Checks["postgres"].Error, "connection refused")
+
+	// Liveness does not depend on the database.
+	status, report = get("/healthz")
+	assert.Equal(t, status, fiber.StatusOK)
+	assert.Equal(t, report, Report{Status: StatusUp})
+}
+
+func TestWait(t *testing.T) {
+	config := RetryConfig{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	attempts := 0
+	err := Wait(context.Background(), "postgres", CheckerFunc(func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}), config)
+	assert.NoError(t, err)
+	assert.Equal(t, attempts, 3)
+
+	attempts = 0
+	err = Wait(context.Background(), "postgres", CheckerFunc(func(context.Context) error {
+		attempts++
+		return errors.New("connection refused")
+	}), config)
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, attempts, 3)
+}
+
+func TestPool(t *testing.T) {
+	stats := sql.DBStats{MaxOpenConnections: 10, InUse: 3, WaitCount: 5}
+	checker := newPoolChecker(func() sql.DBStats {
+		return stats
+	})
+
+	assert.NoError(t, checker.Check(context.Background()))
+
+	// Every connection is in use, but nobody has waited since the last check.
+	stats.InUse = 10
+	assert.NoError(t, checker.Check(context.Background()))
+
+	stats.WaitCount = 7
+	assert.EqualError(t, checker.Check(context.Background()), "all 10 connections are in use, 2 requests waited for one")
+
+	stats.InUse = 4
+	stats.WaitCount = 8
+	assert.NoError(t, checker.Check(context.Background()))
+}
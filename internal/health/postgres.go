@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/migrations"
+	"sync"
+)
+
+// Ping checks that the database answers.
+func Ping(db *sql.DB) Checker {
+	return CheckerFunc(db.PingContext)
+}
+
+// Migrations checks that every migration embedded into the binary has been
+// applied, so that the code does not run against an older schema.
+func Migrations(migrator *migrations.Migrator) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d migrations pending, the first is %s", len(pending), pending[0].Name)
+		}
+		return nil
+	})
+}
+
+type poolChecker struct {
+	stats func() sql.DBStats
+
+	mu        sync.Mutex
+	waitCount int64
+}
+
+// Pool checks that the connection pool is not exhausted: it fails when every
+// connection is in use and requests have had to wait for one since the
+// previous check. A pool without a limit is never exhausted.
+func Pool(db *sql.DB) Checker {
+	return newPoolChecker(db.Stats)
+}
+
+func newPoolChecker(stats func() sql.DBStats) *poolChecker {
+	return &poolChecker{
+		stats:     stats,
+		waitCount: stats().WaitCount,
+	}
+}
+
+func (p *poolChecker) Check(context.Context) error {
+	stats := p.stats()
+
+	p.mu.Lock()
+	waited := stats.WaitCount - p.waitCount
+	p.waitCount = stats.WaitCount
+	p.mu.Unlock()
+
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections && waited > 0 {
+		return fmt.Errorf("all %d connections are in use, %d requests waited for one", stats.MaxOpenConnections, waited)
+	}
+	return nil
+}
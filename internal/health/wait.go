@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/lov3allmy/avito-test-go/internal/retry"
+	"time"
+)
+
+// RetryConfig controls how long Wait keeps trying. Attempts, backoffs and the
+// timeout of a single check that are not positive fall back to the defaults.
+type RetryConfig struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Timeout    time.Duration
+}
+
+const (
+	DefaultAttempts   = 5
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// Wait runs the check until it succeeds, doubling the delay between attempts
+// up to MaxBackoff. It returns the last error once all attempts failed, or
+// the error of ctx if it is cancelled first.
+func Wait(ctx context.Context, name string, checker Checker, config RetryConfig) error {
+	if config.Attempts <= 0 {
+		config.Attempts = DefaultAttempts
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	for attempt := 1; ; attempt++ {
+		checkCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+		err := checker.Check(checkCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= config.Attempts {
+			return err
+		}
+		delay := retry.Delay(config.Backoff, config.MaxBackoff, attempt-1)
+
+		logging.FromContext(ctx).Warn("dependency not ready, retrying",
+			"dependency", name,
+			"attempt", attempt,
+			"error", err,
+			"retry_in", delay.String(),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
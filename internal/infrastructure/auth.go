@@ -0,0 +1,28 @@
+package infrastructure
+
+import (
+	"github.com/lov3allmy/avito-test-go/internal/auth"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+)
+
+// newAuthenticator accepts API keys stored in the database and bearer tokens
+// signed with "auth.jwt_hmac_secret" (HS256) or the private half of
+// "auth.jwt_rsa_public_key_file" (RS256).
+func newAuthenticator(cfg config.Auth, repository domain.Repository) (domain.Authenticator, error) {
+	config := auth.Config{
+		HMACSecret: []byte(cfg.JWTHMACSecret),
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+	}
+
+	if file := cfg.JWTRSAPublicKeyFile; file != "" {
+		publicKey, err := auth.LoadRSAPublicKey(file)
+		if err != nil {
+			return nil, err
+		}
+		config.RSAPublicKey = publicKey
+	}
+
+	return auth.NewAuthenticator(repository, config), nil
+}
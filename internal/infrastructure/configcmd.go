@@ -0,0 +1,25 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"log"
+)
+
+const configUsage = "usage: avito-test-go [flags] config print"
+
+// PrintConfig runs the "config" subcommand: "print" shows the effective
+// config, after the file, the environment and the flags are applied, with
+// secrets masked.
+func PrintConfig(cfg *config.Config, args []string) {
+	if len(args) != 1 || args[0] != "print" {
+		log.Fatal(configUsage)
+	}
+
+	out, err := json.MarshalIndent(cfg.Redacted(), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+}
@@ -1,44 +1,121 @@
 package infrastructure
 
 import (
+	"context"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	_ "github.com/lib/pq"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
 	handler2 "github.com/lov3allmy/avito-test-go/internal/handler"
+	"github.com/lov3allmy/avito-test-go/internal/health"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/lov3allmy/avito-test-go/internal/metrics"
+	"github.com/lov3allmy/avito-test-go/internal/rates"
 	"github.com/lov3allmy/avito-test-go/internal/repository"
 	"github.com/lov3allmy/avito-test-go/internal/service"
-	"github.com/spf13/viper"
-	"log"
+	"github.com/lov3allmy/avito-test-go/internal/tracing"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-func Run() {
-	if err := initConfig(); err != nil {
-		log.Fatal("initializing viper config failed with error" + err.Error())
+// Run serves the REST and gRPC APIs until SIGINT or SIGTERM. On either it
+// stops taking new requests, lets the ones in flight finish within
+// "shutdown_timeout", stops the background workers and closes the database.
+func Run(cfg *config.Config) {
+	logger, err := initLogger(cfg.Log)
+	if err != nil {
+		fatal(logging.Default(), "initializing logger failed", err)
 	}
+	logger.Info("starting", "config", cfg.Redacted())
+
+	ctx := logging.NewContext(context.Background(), logger)
 
-	cfg := postgresConfig{
-		Host:     viper.GetString("db.host"),
-		Port:     viper.GetString("db.port"),
-		User:     viper.GetString("db.user"),
-		Password: viper.GetString("db.password"),
-		DBName:   "avito_test_go",
-		SSLMode:  "disable",
+	tracerProvider, err := initTracing(cfg.Tracing)
+	if err != nil {
+		fatal(logger, "initializing tracing failed", err)
 	}
 
-	postgres, err := ConnectToPostgres(cfg)
+	postgres, err := ConnectToPostgres(cfg.DB)
 	if err != nil {
-		log.Fatal("Connecting to db failed with error: " + err.Error())
+		fatal(logger, "connecting to db failed", err)
+	}
+	if err := waitForPostgres(ctx, cfg, postgres); err != nil {
+		fatal(logger, "connecting to db failed", err)
+	}
+
+	if cfg.DB.AutoMigrate {
+		if err := applyMigrations(logger, postgres); err != nil {
+			fatal(logger, "applying migrations failed", err)
+		}
 	}
 
 	app := fiber.New(fiber.Config{
-		AppName: "Avito Test Go",
+		AppName:      "Avito Test Go",
+		ErrorHandler: handler2.ErrorHandler,
 	})
 
-	repos := repository.NewRepository(postgres)
+	repos := tracing.NewRepository(repository.NewRepository(postgres))
+
+	rateProvider, err := newRateProvider(cfg.Rates)
+	if err != nil {
+		fatal(logger, "initializing rate provider failed", err)
+	}
+
+	spendingLimits, err := cfg.SpendingLimits.Parse()
+	if err != nil {
+		fatal(logger, "reading spending limits failed", err)
+	}
+
+	m, err := newMetrics(cfg, postgres)
+	if err != nil {
+		fatal(logger, "initializing metrics failed", err)
+	}
 
-	services := service.NewService(repos)
+	checks, err := newHealth(cfg.Health, postgres)
+	if err != nil {
+		fatal(logger, "initializing health checks failed", err)
+	}
 
-	handlers := handler2.NewHandler(services)
+	services := service.NewService(repos, rateProvider, spendingLimits)
+	if m != nil {
+		services = metrics.NewService(services, m)
+	}
+	services = tracing.NewService(services)
+
+	workers := newBackground(ctx)
+	if _, err := startOutboxRelay(cfg.Outbox, workers, repos); err != nil {
+		fatal(logger, "launching outbox relay failed", err)
+	}
+	startWebhookDispatcher(cfg.Webhooks, workers, repos)
+
+	authenticator, err := newAuthenticator(cfg.Auth, repos)
+	if err != nil {
+		fatal(logger, "initializing authenticator failed", err)
+	}
+
+	limiter, err := startRateLimiter(cfg.RateLimit, workers, repos)
+	if err != nil {
+		fatal(logger, "initializing rate limiter failed", err)
+	}
+
+	grpcServer, err := startGRPCServer(logger, cfg.GRPCPort, services, authenticator, limiter)
+	if err != nil {
+		fatal(logger, "launching gRPC server failed", err)
+	}
+
+	handlers := handler2.NewHandler(services, authenticator, limiter)
+
+	if m != nil {
+		app.Use(m.Middleware)
+		app.Get(cfg.Metrics.Path, m.Handler())
+	}
+	app.Get("/healthz", health.Live)
+	app.Get("/readyz", checks.Ready)
+	app.Use(handler2.Trace, handler2.RequestID, handler2.AccessLog, handler2.RenderErrors)
 
 	api := app.Group("/api")
 
@@ -47,18 +124,48 @@ func Run() {
 	app.All("*", func(c *fiber.Ctx) error {
 		errorMessage := fmt.Sprintf("Route '%s' does not exist in this API!", c.OriginalURL())
 
-		return c.Status(fiber.StatusNotFound).JSON(&fiber.Map{
-			"message": errorMessage,
-		})
+		return fiber.NewError(fiber.StatusNotFound, errorMessage)
 	})
 
-	if err := app.Listen(":" + viper.GetString("port")); err != nil {
-		log.Fatal("Launching server failed with error" + err.Error())
+	go func() {
+		if err := app.Listen(":" + cfg.Port); err != nil {
+			fatal(logger, "launching server failed", err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	logger.Info("shutting down", "signal", (<-signals).String())
+
+	steps := []shutdownStep{
+		{name: "http server", stop: stopHTTPServer(app)},
+		{name: "grpc server", stop: stopGRPCServer(grpcServer)},
+		{name: "background workers", stop: workers.Stop},
 	}
+	if tracerProvider != nil {
+		steps = append(steps, shutdownStep{name: "tracer provider", stop: tracerProvider.Shutdown})
+	}
+	steps = append(steps, shutdownStep{name: "database", stop: func(context.Context) error {
+		return postgres.Close()
+	}})
+	shutdown(ctx, cfg.ShutdownTimeout, steps)
 }
 
-func initConfig() error {
-	viper.AddConfigPath("config")
-	viper.SetConfigName("main")
-	return viper.ReadInConfig()
+// newRateProvider uses static rates from "rates.file" when it is set and the
+// HTTP API at "rates.url" otherwise. Either way rates are cached for "rates.ttl".
+func newRateProvider(cfg config.Rates) (domain.RateProvider, error) {
+	var provider domain.RateProvider
+	if file := cfg.File; file != "" {
+		fileProvider, err := rates.NewFileProvider(file)
+		if err != nil {
+			return nil, err
+		}
+		provider = fileProvider
+	} else {
+		provider = rates.NewHTTPProvider(cfg.URL, cfg.Base, &http.Client{
+			Timeout: 5 * time.Second,
+		})
+	}
+
+	return rates.NewCachedProvider(provider, cfg.TTL), nil
 }
@@ -0,0 +1,28 @@
+package infrastructure
+
+import (
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/lov3allmy/avito-test-go/internal/rpc"
+	"google.golang.org/grpc"
+	"net"
+)
+
+// startGRPCServer serves the gRPC API on the given port in the background.
+// Errors after the listener is open are fatal, the same as for the HTTP
+// server.
+func startGRPCServer(logger *logging.Logger, port string, service domain.Service, authenticator domain.Authenticator, limiter domain.RateLimiter) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, err
+	}
+
+	server := rpc.NewGRPCServer(service, authenticator, limiter)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			fatal(logger, "serving gRPC failed", err)
+		}
+	}()
+
+	return server, nil
+}
@@ -0,0 +1,37 @@
+package infrastructure
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/health"
+	"github.com/lov3allmy/avito-test-go/internal/migrations"
+)
+
+// waitForPostgres pings the database until it answers, so that a wrong
+// config or an unreachable database stops the service at startup instead of
+// failing its first requests. The delay between attempts grows from
+// "db.connect_backoff" to "db.connect_max_backoff".
+func waitForPostgres(ctx context.Context, cfg *config.Config, postgres *sqlx.DB) error {
+	return health.Wait(ctx, "postgres", health.Ping(postgres.DB), health.RetryConfig{
+		Attempts:   cfg.DB.ConnectAttempts,
+		Backoff:    cfg.DB.ConnectBackoff,
+		MaxBackoff: cfg.DB.ConnectMaxBackoff,
+		Timeout:    cfg.Health.Timeout,
+	})
+}
+
+// newHealth creates the readiness checks: the database answers, every
+// migration is applied and the connection pool is not exhausted.
+func newHealth(cfg config.Health, postgres *sqlx.DB) (*health.Health, error) {
+	migrator, err := migrations.NewMigrator(postgres)
+	if err != nil {
+		return nil, err
+	}
+
+	h := health.New(cfg.Timeout)
+	h.Register("postgres", health.Ping(postgres.DB))
+	h.Register("migrations", health.Migrations(migrator))
+	h.Register("postgres_pool", health.Pool(postgres.DB))
+	return h, nil
+}
@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/auth"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/repository"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const keysUsage = "usage: avito-test-go keys issue -client <id> -scopes <scope,...> | revoke <id> | list"
+
+// Keys runs the "keys" subcommand managing API keys: "issue" creates a key
+// and prints it once, "revoke" disables a key by its id and "list" shows all
+// keys without the secret part.
+func Keys(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(keysUsage)
+	}
+
+	postgres, err := ConnectToPostgres(cfg.DB)
+	if err != nil {
+		log.Fatal("Connecting to db failed with error: " + err.Error())
+	}
+	defer postgres.Close()
+
+	repos := repository.NewRepository(postgres)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "issue":
+		flags := flag.NewFlagSet("issue", flag.ExitOnError)
+		clientID := flags.String("client", "", "id of the client the key is issued to")
+		scopes := flags.String("scopes", "", "comma separated scopes: "+strings.Join(domain.Scopes, ", "))
+		_ = flags.Parse(args[1:])
+
+		key, apiKey, err := auth.IssueAPIKey(ctx, repos, *clientID, strings.Split(*scopes, ","))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("issued key %d for %s with scopes %s\n", apiKey.ID, apiKey.ClientID, strings.Join(apiKey.Scopes, ","))
+		fmt.Println("the key is shown only once:")
+		fmt.Println(key)
+	case "revoke":
+		if len(args) != 2 {
+			log.Fatal(keysUsage)
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatal(keysUsage)
+		}
+		if err := repos.RevokeAPIKey(ctx, id); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("revoked key", id)
+	case "list":
+		apiKeys, err := repos.ListAPIKeys(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCLIENT\tPREFIX\tSCOPES\tSTATUS")
+		for _, apiKey := range apiKeys {
+			status := "active"
+			if apiKey.RevokedAt != nil {
+				status = "revoked at " + apiKey.RevokedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", apiKey.ID, apiKey.ClientID, apiKey.Prefix, strings.Join(apiKey.Scopes, ","), status)
+		}
+		_ = w.Flush()
+	default:
+		log.Fatal(keysUsage)
+	}
+}
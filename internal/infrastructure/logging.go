@@ -0,0 +1,27 @@
+package infrastructure
+
+import (
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"os"
+)
+
+// initLogger makes a JSON logger writing to stderr at the level named by
+// "log.level" the default one, so that code without a request context logs
+// through it too.
+func initLogger(cfg config.Log) (*logging.Logger, error) {
+	level, err := logging.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logging.New(os.Stderr, level)
+	logging.SetDefault(logger)
+	return logger, nil
+}
+
+// fatal logs the error and exits, for errors the service cannot run with.
+func fatal(logger *logging.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,22 @@
+package infrastructure
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/metrics"
+)
+
+// newMetrics creates the metrics served on "metrics.path", including the
+// stats of the database connection pool. It returns nil when metrics are
+// turned off with "metrics.enabled".
+func newMetrics(cfg *config.Config, postgres *sqlx.DB) (*metrics.Metrics, error) {
+	if !cfg.Metrics.Enabled {
+		return nil, nil
+	}
+
+	m := metrics.New()
+	if err := m.RegisterDB(postgres.DB, cfg.DB.Name); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,86 @@
+package infrastructure
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/lov3allmy/avito-test-go/internal/migrations"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: avito-test-go migrate up|down|status"
+
+// Migrate runs the "migrate" subcommand: "up" applies all pending migrations,
+// "down" reverts the last applied one and "status" lists them all.
+func Migrate(cfg *config.Config, args []string) {
+	if len(args) != 1 {
+		log.Fatal(migrateUsage)
+	}
+
+	postgres, err := ConnectToPostgres(cfg.DB)
+	if err != nil {
+		log.Fatal("Connecting to db failed with error: " + err.Error())
+	}
+	defer postgres.Close()
+
+	migrator, err := migrations.NewMigrator(postgres)
+	if err != nil {
+		log.Fatal("Loading migrations failed with error: " + err.Error())
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Println("applied", migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		reverted, err := migrator.Down()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if reverted == nil {
+			fmt.Println("no applied migrations")
+			return
+		}
+		fmt.Println("reverted", reverted.Name)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%s\t%s\n", status.Name, appliedAt)
+		}
+		_ = w.Flush()
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
+
+func applyMigrations(logger *logging.Logger, postgres *sqlx.DB) error {
+	migrator, err := migrations.NewMigrator(postgres)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up()
+	for _, migration := range applied {
+		logger.Info("migration applied", "name", migration.Name)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package infrastructure
+
+import (
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/outbox"
+	"github.com/lov3allmy/avito-test-go/internal/webhooks"
+	"net/http"
+	"os"
+	"time"
+)
+
+// startOutboxRelay publishes balance events from the outbox in the background
+// until the workers are stopped. Besides the configured publisher every event
+// is queued for the webhooks subscribed to it.
+func startOutboxRelay(cfg config.Outbox, workers *background, repository domain.Repository) (*outbox.Relay, error) {
+	publisher, err := newEventPublisher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	publisher = outbox.NewMultiPublisher(publisher, webhooks.NewEventPublisher(repository))
+
+	relay := outbox.NewRelay(repository, publisher, outbox.RelayConfig{
+		Interval:   cfg.Interval,
+		BatchSize:  cfg.BatchSize,
+		Backoff:    cfg.Backoff,
+		MaxBackoff: cfg.MaxBackoff,
+	})
+	workers.Go(relay.Run)
+
+	return relay, nil
+}
+
+// newEventPublisher picks the publisher named by "outbox.publisher": "stdout"
+// (the default), "file" writing to "outbox.file" or "http" posting to
+// "outbox.url".
+func newEventPublisher(cfg config.Outbox) (domain.EventPublisher, error) {
+	switch publisher := cfg.Publisher; publisher {
+	case "", "stdout":
+		return outbox.NewWriterPublisher(os.Stdout), nil
+	case "file":
+		return outbox.NewFilePublisher(cfg.File)
+	case "http":
+		return outbox.NewHTTPPublisher(cfg.URL, &http.Client{
+			Timeout: 5 * time.Second,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", publisher)
+	}
+}
@@ -1,29 +1,28 @@
 package infrastructure
 
 import (
-	"fmt"
+	"database/sql"
 	"github.com/jmoiron/sqlx"
-	"time"
+	"github.com/lib/pq"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/repository"
+	"github.com/lov3allmy/avito-test-go/internal/tracing"
 )
 
-type postgresConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
-}
-
-func ConnectToPostgres(cfg postgresConfig) (*sqlx.DB, error) {
-	db, err := sqlx.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode))
+// ConnectToPostgres opens the connection pool sized by the "db" section.
+// Queries run on behalf of a traced request get spans named after the
+// repository constants they come from.
+func ConnectToPostgres(cfg config.DB) (*sqlx.DB, error) {
+	connector, err := pq.NewConnector(cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
+	db := sqlx.NewDb(sql.OpenDB(tracing.WrapConnector(connector, repository.QueryName)), "postgres")
 
-	db.SetConnMaxLifetime(time.Minute * 3)
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(10)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	return db, nil
 }
@@ -0,0 +1,40 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/ratelimit"
+)
+
+// startRateLimiter builds the limiter from the "rate_limit" section and
+// starts forgetting idle buckets in the background until the workers are
+// stopped. It returns nil when rate limiting is turned off.
+func startRateLimiter(cfg config.RateLimit, workers *background, repository domain.Repository) (domain.RateLimiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var store ratelimit.Store
+	switch cfg.Store {
+	case "memory", "":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		store = ratelimit.NewPostgresStore(repository)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+
+	limiter := ratelimit.NewLimiter(store, ratelimit.Config{
+		ClientRead:  cfg.Client.Read.RateLimit(),
+		ClientWrite: cfg.Client.Write.RateLimit(),
+		UserRead:    cfg.User.Read.RateLimit(),
+		UserWrite:   cfg.User.Write.RateLimit(),
+	})
+	workers.Go(func(ctx context.Context) {
+		limiter.Run(ctx, cfg.SweepInterval)
+	})
+
+	return limiter, nil
+}
@@ -0,0 +1,113 @@
+package infrastructure
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"google.golang.org/grpc"
+	"sync"
+	"time"
+)
+
+// background runs the workers of the service, like the outbox relay, until
+// they are stopped on shutdown.
+type background struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newBackground(ctx context.Context) *background {
+	ctx, cancel := context.WithCancel(ctx)
+	return &background{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Go runs the worker in its own goroutine. The worker must return once ctx
+// is cancelled.
+func (b *background) Go(run func(ctx context.Context)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		run(b.ctx)
+	}()
+}
+
+// Stop cancels the workers and waits for them to return, giving up when ctx
+// is done first.
+func (b *background) Stop(ctx context.Context) error {
+	b.cancel()
+	return wait(ctx, b.wg.Wait)
+}
+
+// wait runs stop and waits for it to return, giving up when ctx is done
+// first.
+func wait(ctx context.Context, stop func()) error {
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// defaultShutdownTimeout is used when "shutdown_timeout" is not set.
+const defaultShutdownTimeout = 30 * time.Second
+
+// shutdownStep is a part of the service stopped on shutdown.
+type shutdownStep struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// shutdown stops the parts of the service one after another, in the order
+// given, within timeout altogether. A part failing to stop, or to stop in
+// time, is logged and the rest are stopped anyway.
+func shutdown(ctx context.Context, timeout time.Duration, steps []shutdownStep) {
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logger := logging.FromContext(ctx)
+	for _, step := range steps {
+		if err := step.stop(ctx); err != nil {
+			logger.Error("stopping failed", "part", step.name, "error", err)
+			continue
+		}
+		logger.Info("stopped", "part", step.name)
+	}
+}
+
+// stopHTTPServer stops accepting connections and waits for the requests in
+// flight to be served.
+func stopHTTPServer(app *fiber.App) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var err error
+		if waitErr := wait(ctx, func() { err = app.Shutdown() }); waitErr != nil {
+			return waitErr
+		}
+		return err
+	}
+}
+
+// stopGRPCServer stops accepting calls and waits for the calls in flight to
+// be served. Calls still running when ctx is done are cancelled.
+func stopGRPCServer(server *grpc.Server) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := wait(ctx, server.GracefulStop); err != nil {
+			server.Stop()
+			return err
+		}
+		return nil
+	}
+}
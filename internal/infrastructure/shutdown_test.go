@@ -0,0 +1,126 @@
+package infrastructure
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/handler"
+	"github.com/lov3allmy/avito-test-go/internal/rates"
+	"github.com/lov3allmy/avito-test-go/internal/repository"
+	"github.com/lov3allmy/avito-test-go/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startedService tells when a transfer has reached the service.
+type startedService struct {
+	domain.Service
+	started chan struct{}
+}
+
+func (s *startedService) Transfer(ctx context.Context, input domain.P2PInput) error {
+	close(s.started)
+	return s.Service.Transfer(ctx, input)
+}
+
+func TestShutdown_DrainsTransfer(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	postgres := sqlx.NewDb(mockDB, "sqlmock")
+
+	// The transfer is still writing when the shutdown begins.
+	mock.ExpectQuery("SELECT daily, monthly, max_transfer FROM spending_limits").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"daily", "monthly", "max_transfer"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "reserved_balance"}).AddRow(1, 100000, 0))
+	mock.ExpectQuery("SELECT \\* FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "reserved_balance"}).AddRow(2, 0, 0))
+	mock.ExpectExec("UPDATE users SET balance = \\(balance -").WillDelayFor(200 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET balance = \\(balance \\+").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO transactions").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO transactions").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectClose()
+
+	services := &startedService{
+		Service: service.NewService(repository.NewRepository(postgres), rates.NewStaticProvider(nil), domain.SpendingLimits{}),
+		started: make(chan struct{}),
+	}
+	h := handler.NewHandler(services, nil, nil)
+	app := fiber.New(fiber.Config{ErrorHandler: handler.ErrorHandler, DisableStartupMessage: true})
+	app.Post("/p2p", h.CheckP2PInput, h.MakeP2PTransfer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = app.Listener(listener)
+	}()
+
+	workers := newBackground(context.Background())
+	workerStopped := false
+	workers.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		workerStopped = true
+	})
+
+	type response struct {
+		status int
+		err    error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Post("http://"+listener.Addr().String()+"/p2p", "application/json", strings.NewReader(`{"from_user_id": 1, "to_user_id": 2, "amount": "10.00"}`))
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		_ = resp.Body.Close()
+		responses <- response{status: resp.StatusCode}
+	}()
+
+	select {
+	case <-services.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the transfer did not start")
+	}
+
+	shutdown(context.Background(), 5*time.Second, []shutdownStep{
+		{name: "http server", stop: stopHTTPServer(app)},
+		{name: "background workers", stop: workers.Stop},
+		{name: "database", stop: func(context.Context) error {
+			return postgres.Close()
+		}},
+	})
+
+	resp := <-responses
+	require.NoError(t, resp.err)
+	assert.Equal(t, fiber.StatusOK, resp.status)
+	assert.True(t, workerStopped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// No new requests are taken after the shutdown.
+	_, err = http.Post("http://"+listener.Addr().String()+"/p2p", "application/json", strings.NewReader(`{}`))
+	assert.Error(t, err)
+}
+
+func TestShutdown_Timeout(t *testing.T) {
+	stopped := []string{}
+	shutdown(context.Background(), 10*time.Millisecond, []shutdownStep{
+		{name: "stuck", stop: func(ctx context.Context) error {
+			return wait(ctx, func() { time.Sleep(time.Second) })
+		}},
+		{name: "database", stop: func(context.Context) error {
+			stopped = append(stopped, "database")
+			return nil
+		}},
+	})
+
+	// The database is closed even when a part before it did not stop in time.
+	assert.Equal(t, []string{"database"}, stopped)
+}
@@ -0,0 +1,19 @@
+package infrastructure
+
+import (
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// initTracing installs the tracer provider exporting spans the way
+// "tracing.exporter" says: "none", "stdout" or "otlp" to the collector at
+// "tracing.endpoint". The provider is nil when tracing is turned off.
+func initTracing(cfg config.Tracing) (*sdktrace.TracerProvider, error) {
+	return tracing.Setup(tracing.Config{
+		Exporter:    cfg.Exporter,
+		Endpoint:    cfg.Endpoint,
+		ServiceName: cfg.ServiceName,
+		SampleRatio: cfg.SampleRatio,
+	})
+}
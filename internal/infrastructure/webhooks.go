@@ -0,0 +1,28 @@
+package infrastructure
+
+import (
+	"github.com/lov3allmy/avito-test-go/internal/config"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/retry"
+	"github.com/lov3allmy/avito-test-go/internal/webhooks"
+	"net/http"
+)
+
+// startWebhookDispatcher delivers queued webhook events in the background
+// until the workers are stopped.
+func startWebhookDispatcher(cfg config.Webhooks, workers *background, repository domain.Repository) *webhooks.Dispatcher {
+	dispatcher := webhooks.NewDispatcher(repository, &http.Client{
+		Timeout: cfg.Timeout,
+	}, webhooks.DispatcherConfig{
+		PollConfig: retry.PollConfig{
+			Interval:   cfg.Interval,
+			BatchSize:  cfg.BatchSize,
+			Backoff:    cfg.Backoff,
+			MaxBackoff: cfg.MaxBackoff,
+		},
+		MaxAttempts: cfg.MaxAttempts,
+	})
+	workers.Go(dispatcher.Run)
+
+	return dispatcher
+}
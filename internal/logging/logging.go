@@ -0,0 +1,202 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the importance of a log record. The values match the levels of
+// log/slog, so that records can be compared with other services.
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l >= LevelError:
+		return "ERROR"
+	case l >= LevelWarn:
+		return "WARN"
+	case l >= LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error" in any case.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// Logger writes one JSON object per record with the "time", "level" and
+// "msg" fields followed by key-value pairs, the way slog.JSONHandler does:
+//
+//	logger.Info("transfer completed", "from_user_id", 1, "amount", amount)
+//
+// A value without a string key is logged under "!BADKEY". Loggers derived
+// with With share the output and its lock.
+type Logger struct {
+	output *output
+	level  Level
+	fields []byte
+}
+
+type output struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{
+		output: &output{w: w, now: time.Now},
+		level:  level,
+	}
+}
+
+// With returns a logger that adds the key-value pairs to every record.
+func (l *Logger) With(args ...interface{}) *Logger {
+	buf := bytes.NewBuffer(append([]byte(nil), l.fields...))
+	appendFields(buf, args)
+
+	return &Logger{
+		output: l.output,
+		level:  l.level,
+		fields: buf.Bytes(),
+	}
+}
+
+// Enabled tells whether records of the level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(msg string, args ...interface{}) {
+	l.Log(LevelDebug, msg, args...)
+}
+
+func (l *Logger) Info(msg string, args ...interface{}) {
+	l.Log(LevelInfo, msg, args...)
+}
+
+func (l *Logger) Warn(msg string, args ...interface{}) {
+	l.Log(LevelWarn, msg, args...)
+}
+
+func (l *Logger) Error(msg string, args ...interface{}) {
+	l.Log(LevelError, msg, args...)
+}
+
+func (l *Logger) Log(level Level, msg string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"time":`)
+	writeJSON(buf, l.output.now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(buf, msg)
+	buf.Write(l.fields)
+	appendFields(buf, args)
+	buf.WriteString("}\n")
+
+	l.output.mu.Lock()
+	defer l.output.mu.Unlock()
+	_, _ = l.output.w.Write(buf.Bytes())
+}
+
+func appendFields(buf *bytes.Buffer, args []interface{}) {
+	for len(args) > 0 {
+		key, value := "!BADKEY", args[0]
+		if k, ok := args[0].(string); ok && len(args) > 1 {
+			key, value = k, args[1]
+			args = args[1:]
+		}
+		args = args[1:]
+
+		buf.WriteByte(',')
+		writeJSON(buf, key)
+		buf.WriteByte(':')
+		writeJSON(buf, fieldValue(value))
+	}
+}
+
+// fieldValue makes values that do not marshal well readable: errors and
+// durations become strings.
+func fieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		if _, ok := value.(json.Marshaler); !ok {
+			return v.String()
+		}
+	}
+	return value
+}
+
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprintf("!ERROR: %s", err))
+	}
+	buf.Write(encoded)
+}
+
+type contextKey struct{}
+
+var defaultLogger = New(os.Stderr, LevelInfo)
+
+// Default is the logger used for contexts without one.
+func Default() *Logger {
+	return defaultLogger
+}
+
+// SetDefault replaces the logger returned by Default. It is meant to be
+// called once on startup.
+func SetDefault(logger *Logger) {
+	defaultLogger = logger
+}
+
+// NewContext returns a copy of ctx carrying the logger.
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*Logger); ok {
+			return logger
+		}
+	}
+	return Default()
+}
@@ -0,0 +1,33 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// MaxRequestIDLength bounds request ids taken from clients, so that they do
+// not bloat every record logged for the request.
+const MaxRequestIDLength = 128
+
+// ValidRequestID tells whether a request id sent by a client can be logged
+// as is: it must be non-empty printable ASCII without spaces.
+func ValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < '!' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID returns a random id for a request that came without one.
+func NewRequestID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
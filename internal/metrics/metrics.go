@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+	"math"
+	"strconv"
+	"time"
+)
+
+const namespace = "avito_test_go"
+
+// Metrics holds the collectors of the service in a registry of its own, so
+// that tests can create as many as they need.
+type Metrics struct {
+	registry            *prometheus.Registry
+	httpRequestDuration *prometheus.HistogramVec
+	operations          *prometheus.CounterVec
+	moneyMoved          *prometheus.CounterVec
+	insufficientFunds   *prometheus.CounterVec
+}
+
+// New creates the service metrics together with the Go runtime and process
+// ones.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "balance_operations_total",
+			Help:      "Money operations by operation and outcome: success, rejected or failed.",
+		}, []string{"operation", "outcome"}),
+		moneyMoved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "money_moved_total",
+			Help:      "Money deposited, withdrawn and transferred, in major units of the currency.",
+		}, []string{"operation", "currency"}),
+		insufficientFunds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "insufficient_funds_total",
+			Help:      "Money operations rejected for insufficient funds.",
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequestDuration,
+		m.operations,
+		m.moneyMoved,
+		m.insufficientFunds,
+	)
+	return m
+}
+
+// RegisterDB exports the connection pool stats of db as go_sql_* metrics
+// labelled with the name.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() fiber.Handler {
+	handler := fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	return func(c *fiber.Ctx) error {
+		handler(c.Context())
+		return nil
+	}
+}
+
+// Middleware observes the duration of every request under the route it
+// matched, e.g. "/api/v1/users/:id", so that path parameters do not make a
+// series each.
+func (m *Metrics) Middleware(c *fiber.Ctx) error {
+	started := time.Now()
+
+	err := c.Next()
+
+	m.httpRequestDuration.
+		WithLabelValues(c.Method(), c.Route().Path, strconv.Itoa(c.Response().StatusCode())).
+		Observe(time.Since(started).Seconds())
+	return err
+}
+
+// ObserveOperation counts a money operation by its outcome. The amount of
+// successful operations is added to the money moved, unless moved is false.
+func (m *Metrics) ObserveOperation(operation string, amount domain.Money, moved bool, err error) {
+	m.operations.WithLabelValues(operation, service.Outcome(err)).Inc()
+
+	if errors.Is(err, domain.ErrInsufficientFunds) {
+		m.insufficientFunds.WithLabelValues(operation).Inc()
+	}
+	if err == nil && moved {
+		m.moneyMoved.WithLabelValues(operation, amount.Currency).Add(majorUnits(amount))
+	}
+}
+
+func majorUnits(amount domain.Money) float64 {
+	return float64(amount.MinorUnits) / math.Pow10(domain.CurrencyDigits(amount.Currency))
+}
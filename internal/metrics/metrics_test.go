@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/handler"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+)
+
+func rubles(amount int64) domain.Money {
+	return domain.NewMoney(amount*100, domain.DefaultCurrency)
+}
+
+// scrape returns the metrics the way Prometheus gets them.
+func scrape(t *testing.T, m *Metrics) string {
+	app := fiber.New()
+	app.Get("/metrics", m.Handler())
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil), -1)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := New()
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			return c.SendStatus(fiber.StatusNotFound)
+		},
+	})
+	app.Use(m.Middleware, handler.RenderErrors)
+	app.Get("/users/:id", func(c *fiber.Ctx) error {
+		if c.Params("id") != "1" {
+			return domain.ErrUserNotFound
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	for _, path := range []string{"/users/1", "/users/1", "/users/2"} {
+		_, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		require.NoError(t, err)
+	}
+
+	metrics := scrape(t, m)
+	assert.Contains(t, metrics, `avito_test_go_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`)
+	assert.Contains(t, metrics, `avito_test_go_http_request_duration_seconds_count{method="GET",route="/users/:id",status="404"} 1`)
+}
+
+func TestService(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	next := mock_domain.NewMockService(c)
+	next.EXPECT().Deposit(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	next.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(domain.ErrInsufficientFunds)
+	next.EXPECT().Transfer(gomock.Any(), gomock.Any()).Return(errors.New("connection refused"))
+	next.EXPECT().ReserveFunds(gomock.Any(), gomock.Any()).Return(nil)
+	next.EXPECT().CommitReservation(gomock.Any(), gomock.Any()).Return(nil)
+	next.EXPECT().ReleaseReservation(gomock.Any(), gomock.Any()).Return(domain.ErrReservationNotFound)
+
+	m := New()
+	s := NewService(next, m)
+	ctx := context.Background()
+	_ = s.Deposit(ctx, domain.BalanceOperationInput{UserID: 1, Amount: rubles(10)})
+	_ = s.Deposit(ctx, domain.BalanceOperationInput{UserID: 1, Amount: domain.NewMoney(550, domain.DefaultCurrency)})
+	_ = s.Withdraw(ctx, domain.BalanceOperationInput{UserID: 1, Amount: rubles(100)})
+	_ = s.Transfer(ctx, domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(10)})
+	_ = s.ReserveFunds(ctx, domain.ReservationInput{UserID: 1, Amount: rubles(10)})
+	_ = s.CommitReservation(ctx, domain.ReservationInput{UserID: 1, Amount: rubles(10)})
+	_ = s.ReleaseReservation(ctx, domain.ReservationInput{UserID: 1, Amount: rubles(20)})
+
+	metrics := scrape(t, m)
+	for _, line := range []string{
+		`avito_test_go_balance_operations_total{operation="deposit",outcome="success"} 2`,
+		`avito_test_go_balance_operations_total{operation="withdrawal",outcome="rejected"} 1`,
+		`avito_test_go_balance_operations_total{operation="transfer",outcome="failed"} 1`,
+		`avito_test_go_balance_operations_total{operation="reservation",outcome="success"} 1`,
+		`avito_test_go_balance_operations_total{operation="reservation_commit",outcome="success"} 1`,
+		`avito_test_go_balance_operations_total{operation="reservation_release",outcome="rejected"} 1`,
+		`avito_test_go_money_moved_total{currency="RUB",operation="deposit"} 15.5`,
+		`avito_test_go_money_moved_total{currency="RUB",operation="reservation_commit"} 10`,
+		`avito_test_go_insufficient_funds_total{operation="withdrawal"} 1`,
+	} {
+		assert.Contains(t, metrics, line)
+	}
+	assert.NotContains(t, metrics, `avito_test_go_money_moved_total{currency="RUB",operation="reservation"}`)
+	assert.NotContains(t, metrics, `avito_test_go_money_moved_total{currency="RUB",operation="withdrawal"}`)
+	assert.NotContains(t, metrics, `avito_test_go_money_moved_total{currency="RUB",operation="reservation_release"}`)
+}
+
+func TestMetrics_RegisterDB(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	m := New()
+	require.NoError(t, m.RegisterDB(db, "avito_test_go"))
+
+	assert.Contains(t, scrape(t, m), `go_sql_max_open_connections{db_name="avito_test_go"}`)
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/service"
+)
+
+type instrumentedService struct {
+	domain.Service
+	metrics *Metrics
+}
+
+// NewService wraps the service, counting its money operations. Every other
+// method is passed through as is.
+func NewService(s domain.Service, metrics *Metrics) domain.Service {
+	return &instrumentedService{
+		Service: s,
+		metrics: metrics,
+	}
+}
+
+func (s *instrumentedService) Deposit(ctx context.Context, input domain.BalanceOperationInput) error {
+	err := s.Service.Deposit(ctx, input)
+	s.metrics.ObserveOperation(service.OperationDeposit, input.Amount, true, err)
+	return err
+}
+
+func (s *instrumentedService) Withdraw(ctx context.Context, input domain.BalanceOperationInput) error {
+	err := s.Service.Withdraw(ctx, input)
+	s.metrics.ObserveOperation(service.OperationWithdrawal, input.Amount, true, err)
+	return err
+}
+
+func (s *instrumentedService) Transfer(ctx context.Context, input domain.P2PInput) error {
+	err := s.Service.Transfer(ctx, input)
+	s.metrics.ObserveOperation(service.OperationTransfer, input.Amount, true, err)
+	return err
+}
+
+// ReserveFunds is counted, but the reserved money is not moved until the
+// reservation is committed.
+func (s *instrumentedService) ReserveFunds(ctx context.Context, input domain.ReservationInput) error {
+	err := s.Service.ReserveFunds(ctx, input)
+	s.metrics.ObserveOperation(service.OperationReservation, input.Amount, false, err)
+	return err
+}
+
+// CommitReservation moves the reserved money, whose amount the input has to
+// match.
+func (s *instrumentedService) CommitReservation(ctx context.Context, input domain.ReservationInput) error {
+	err := s.Service.CommitReservation(ctx, input)
+	s.metrics.ObserveOperation(service.OperationReservationCommit, input.Amount, true, err)
+	return err
+}
+
+// ReleaseReservation returns the reserved money to the balance of the user,
+// which moves it nowhere.
+func (s *instrumentedService) ReleaseReservation(ctx context.Context, input domain.ReservationInput) error {
+	err := s.Service.ReleaseReservation(ctx, input)
+	s.metrics.ObserveOperation(service.OperationReservationRelease, input.Amount, false, err)
+	return err
+}
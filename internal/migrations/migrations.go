@@ -0,0 +1,289 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+const (
+	QueryCreateMigrationsTable = "CREATE TABLE IF NOT EXISTS migrations (version INT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())"
+	QueryLockMigrations        = "SELECT pg_advisory_xact_lock($1)"
+	QueryGetAppliedMigrations  = "SELECT version, applied_at FROM migrations ORDER BY version"
+	QueryGetAppliedVersions    = "SELECT version FROM migrations ORDER BY version"
+	QueryIsMigrationApplied    = "SELECT EXISTS (SELECT 1 FROM migrations WHERE version = $1)"
+	QueryGetLastMigration      = "SELECT version FROM migrations ORDER BY version DESC LIMIT 1"
+	QueryInsertMigration       = "INSERT INTO migrations (version, name) VALUES ($1, $2)"
+	QueryDeleteMigration       = "DELETE FROM migrations WHERE version = $1"
+
+	// migrationsLockID is an arbitrary key for the advisory lock that keeps
+	// several instances starting at once from applying the same migration.
+	migrationsLockID = 7340124
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for the migrations embedded into the binary.
+func NewMigrator(db *sqlx.DB) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// load reads "sql/<version>_<name>.up.sql" and "sql/<version>_<name>.down.sql"
+// pairs and returns them ordered by version.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %q", fileName)
+		}
+
+		name := strings.TrimSuffix(fileName, "."+direction+".sql")
+		separator := strings.IndexByte(name, '_')
+		if separator <= 0 {
+			return nil, fmt.Errorf("migration file %q has no version prefix", fileName)
+		}
+		version, err := strconv.Atoi(name[:separator])
+		if err != nil {
+			return nil, fmt.Errorf("migration file %q has invalid version: %w", fileName, err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join("sql", fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", migration.Name, name, version)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %q must have both up and down files", migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations, each in its own transaction, and returns
+// the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	if _, err := m.db.Exec(QueryCreateMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0)
+	for _, migration := range m.migrations {
+		ok, err := m.apply(migration)
+		if err != nil {
+			return applied, fmt.Errorf("applying migration %q failed with error: %w", migration.Name, err)
+		}
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) apply(migration Migration) (bool, error) {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(QueryLockMigrations, migrationsLockID); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	var alreadyApplied bool
+	if err := tx.Get(&alreadyApplied, QueryIsMigrationApplied, migration.Version); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if alreadyApplied {
+		return false, tx.Rollback()
+	}
+
+	if _, err := tx.Exec(migration.Up); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if _, err := tx.Exec(QueryInsertMigration, migration.Version, migration.Name); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Down reverts the last applied migration. It returns nil when there is
+// nothing to revert.
+func (m *Migrator) Down() (*Migration, error) {
+	if _, err := m.db.Exec(QueryCreateMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(QueryLockMigrations, migrationsLockID); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	var version int
+	if err := tx.Get(&version, QueryGetLastMigration); err != nil {
+		_ = tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	migration := m.find(version)
+	if migration == nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("applied migration %d is unknown to this binary", version)
+	}
+
+	if _, err := tx.Exec(migration.Down); err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("reverting migration %q failed with error: %w", migration.Name, err)
+	}
+	if _, err := tx.Exec(QueryDeleteMigration, migration.Version); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return migration, tx.Commit()
+}
+
+// Status lists every known migration along with the time it was applied.
+func (m *Migrator) Status() ([]Status, error) {
+	if _, err := m.db.Exec(QueryCreateMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	rows := make([]struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}, 0)
+	if err := m.db.Select(&rows, QueryGetAppliedMigrations); err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		appliedAt[row.Version] = row.AppliedAt
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if at, ok := appliedAt[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the known migrations that are not applied yet. Unlike
+// Status it never creates the migrations table, so it is safe to call from a
+// read-only health check; without the table the query fails.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	var versions []int
+	if err := m.db.SelectContext(ctx, &versions, QueryGetAppliedVersions); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package migrations
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := load(files)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	for i, migration := range migrations {
+		assert.Equal(t, i+1, migration.Version, "migration versions must be consecutive")
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		expected    []Migration
+		expectedErr bool
+	}{
+		{
+			name: "OK",
+			files: fstest.MapFS{
+				"sql/0002_second.up.sql":   {Data: []byte("up 2")},
+				"sql/0002_second.down.sql": {Data: []byte("down 2")},
+				"sql/0001_first.up.sql":    {Data: []byte("up 1")},
+				"sql/0001_first.down.sql":  {Data: []byte("down 1")},
+			},
+			expected: []Migration{
+				{Version: 1, Name: "0001_first", Up: "up 1", Down: "down 1"},
+				{Version: 2, Name: "0002_second", Up: "up 2", Down: "down 2"},
+			},
+		},
+		{
+			name: "Missing down",
+			files: fstest.MapFS{
+				"sql/0001_first.up.sql": {Data: []byte("up 1")},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Duplicate version",
+			files: fstest.MapFS{
+				"sql/0001_first.up.sql":    {Data: []byte("up 1")},
+				"sql/0001_first.down.sql":  {Data: []byte("down 1")},
+				"sql/0001_second.up.sql":   {Data: []byte("up 2")},
+				"sql/0001_second.down.sql": {Data: []byte("down 2")},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "No version",
+			files: fstest.MapFS{
+				"sql/first.up.sql": {Data: []byte("up 1")},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migrations, err := load(test.files)
+			if test.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, migrations)
+			}
+		})
+	}
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() { _ = mockDB.Close() })
+
+	return &Migrator{
+		db: sqlx.NewDb(mockDB, "sqlmock"),
+		migrations: []Migration{
+			{Version: 1, Name: "0001_first", Up: "CREATE TABLE first (id INT)", Down: "DROP TABLE first"},
+			{Version: 2, Name: "0002_second", Up: "CREATE TABLE second (id INT)", Down: "DROP TABLE second"},
+		},
+	}, mock
+}
+
+func TestMigrator_Up(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(migrationsLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(migrationsLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE second (id INT)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO migrations").WithArgs(2, "0002_second").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	applied, err := migrator.Up()
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{migrator.migrations[1]}, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(migrationsLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version FROM migrations ORDER BY version DESC LIMIT 1").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectExec("DROP TABLE second").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM migrations").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	reverted, err := migrator.Down()
+	assert.NoError(t, err)
+	assert.Equal(t, &migrator.migrations[1], reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Pending(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+
+	mock.ExpectQuery("SELECT version FROM migrations").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+
+	pending, err := migrator.Pending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{migrator.migrations[1]}, pending)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Status(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+
+	appliedAt := time.Date(2022, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM migrations").WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+
+	statuses, err := migrator.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []Status{
+		{Migration: migrator.migrations[0], AppliedAt: &appliedAt},
+		{Migration: migrator.migrations[1]},
+	}, statuses)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,5 @@
+-- Databases created by hand before migrations existed already have this table.
+CREATE TABLE IF NOT EXISTS users (
+    id INT PRIMARY KEY,
+    balance INT NOT NULL
+);
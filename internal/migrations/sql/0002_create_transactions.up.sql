@@ -0,0 +1,11 @@
+CREATE TABLE transactions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id),
+    type VARCHAR(16) NOT NULL,
+    amount INT NOT NULL,
+    counterparty_id INT REFERENCES users (id),
+    comment VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX transactions_user_id_created_at_idx ON transactions (user_id, created_at);
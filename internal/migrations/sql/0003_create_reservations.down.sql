@@ -0,0 +1,3 @@
+DROP TABLE reservations;
+
+ALTER TABLE users DROP COLUMN reserved_balance;
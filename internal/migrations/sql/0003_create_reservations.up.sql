@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN reserved_balance INT NOT NULL DEFAULT 0;
+
+CREATE TABLE reservations (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id),
+    service_id INT NOT NULL,
+    order_id INT NOT NULL UNIQUE,
+    amount INT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
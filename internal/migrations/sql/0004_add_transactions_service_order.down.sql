@@ -0,0 +1,5 @@
+DROP INDEX transactions_created_at_service_id_idx;
+
+ALTER TABLE transactions
+    DROP COLUMN service_id,
+    DROP COLUMN order_id;
@@ -0,0 +1,5 @@
+ALTER TABLE transactions
+    ADD COLUMN service_id INT,
+    ADD COLUMN order_id INT;
+
+CREATE INDEX transactions_created_at_service_id_idx ON transactions (created_at, service_id) WHERE service_id IS NOT NULL;
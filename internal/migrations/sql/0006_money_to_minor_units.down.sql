@@ -0,0 +1,10 @@
+-- Fractions of a ruble are lost when going back to whole rubles.
+ALTER TABLE users
+    ALTER COLUMN balance TYPE INT USING balance / 100,
+    ALTER COLUMN reserved_balance TYPE INT USING reserved_balance / 100;
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE INT USING amount / 100;
+
+ALTER TABLE reservations
+    ALTER COLUMN amount TYPE INT USING amount / 100;
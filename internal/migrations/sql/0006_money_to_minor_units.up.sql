@@ -0,0 +1,10 @@
+-- Amounts used to be whole rubles, they are kopecks from now on.
+ALTER TABLE users
+    ALTER COLUMN balance TYPE BIGINT USING balance::BIGINT * 100,
+    ALTER COLUMN reserved_balance TYPE BIGINT USING reserved_balance::BIGINT * 100;
+
+ALTER TABLE transactions
+    ALTER COLUMN amount TYPE BIGINT USING amount::BIGINT * 100;
+
+ALTER TABLE reservations
+    ALTER COLUMN amount TYPE BIGINT USING amount::BIGINT * 100;
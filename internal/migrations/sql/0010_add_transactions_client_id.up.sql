@@ -0,0 +1 @@
+ALTER TABLE transactions ADD COLUMN client_id VARCHAR(255);
@@ -0,0 +1 @@
+ALTER TABLE idempotency_keys ALTER COLUMN key TYPE VARCHAR(255);
@@ -0,0 +1,3 @@
+-- Idempotency keys are stored prefixed with the id of the client, see
+-- handler.CheckIdempotencyKey.
+ALTER TABLE idempotency_keys ALTER COLUMN key TYPE VARCHAR(512);
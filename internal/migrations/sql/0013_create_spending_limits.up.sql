@@ -0,0 +1,7 @@
+CREATE TABLE spending_limits (
+    user_id INT PRIMARY KEY,
+    daily BIGINT,
+    monthly BIGINT,
+    max_transfer BIGINT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+DROP INDEX webhooks_client_id_idx;
+
+ALTER TABLE webhooks DROP COLUMN client_id;
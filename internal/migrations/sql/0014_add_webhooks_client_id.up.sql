@@ -0,0 +1,14 @@
+-- Webhooks belong to the client that created them from now on. Existing
+-- webhooks have no known owner, so they must be deleted (and created again by
+-- their clients) before this migration is applied; otherwise it fails instead
+-- of leaving webhooks that keep firing but no client can see or remove.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM webhooks) THEN
+        RAISE EXCEPTION 'webhooks without an owner exist: delete them and have their clients create them again';
+    END IF;
+END $$;
+
+ALTER TABLE webhooks ADD COLUMN client_id VARCHAR(255) NOT NULL;
+
+CREATE INDEX webhooks_client_id_idx ON webhooks (client_id);
@@ -5,90 +5,763 @@
 package mock_domain
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	domain "github.com/lov3allmy/avito-test-go/internal/domain"
 )
 
+// MockRateProvider is a mock of RateProvider interface.
+type MockRateProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateProviderMockRecorder
+}
+
+// MockRateProviderMockRecorder is the mock recorder for MockRateProvider.
+type MockRateProviderMockRecorder struct {
+	mock *MockRateProvider
+}
+
+// NewMockRateProvider creates a new mock instance.
+func NewMockRateProvider(ctrl *gomock.Controller) *MockRateProvider {
+	mock := &MockRateProvider{ctrl: ctrl}
+	mock.recorder = &MockRateProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateProvider) EXPECT() *MockRateProviderMockRecorder {
+	return m.recorder
+}
+
+// GetRate mocks base method.
+func (m *MockRateProvider) GetRate(currency string) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRate", currency)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRate indicates an expected call of GetRate.
+func (mr *MockRateProviderMockRecorder) GetRate(currency interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRate", reflect.TypeOf((*MockRateProvider)(nil).GetRate), currency)
+}
+
+// MockEventPublisher is a mock of EventPublisher interface.
+type MockEventPublisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockEventPublisherMockRecorder
+}
+
+// MockEventPublisherMockRecorder is the mock recorder for MockEventPublisher.
+type MockEventPublisherMockRecorder struct {
+	mock *MockEventPublisher
+}
+
+// NewMockEventPublisher creates a new mock instance.
+func NewMockEventPublisher(ctrl *gomock.Controller) *MockEventPublisher {
+	mock := &MockEventPublisher{ctrl: ctrl}
+	mock.recorder = &MockEventPublisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEventPublisher) EXPECT() *MockEventPublisherMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockEventPublisher) Publish(ctx context.Context, event domain.Event) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Publish", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockEventPublisherMockRecorder) Publish(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockEventPublisher)(nil).Publish), ctx, event)
+}
+
+// MockAuthenticator is a mock of Authenticator interface.
+type MockAuthenticator struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthenticatorMockRecorder
+}
+
+// MockAuthenticatorMockRecorder is the mock recorder for MockAuthenticator.
+type MockAuthenticatorMockRecorder struct {
+	mock *MockAuthenticator
+}
+
+// NewMockAuthenticator creates a new mock instance.
+func NewMockAuthenticator(ctrl *gomock.Controller) *MockAuthenticator {
+	mock := &MockAuthenticator{ctrl: ctrl}
+	mock.recorder = &MockAuthenticatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthenticator) EXPECT() *MockAuthenticatorMockRecorder {
+	return m.recorder
+}
+
+// AuthenticateAPIKey mocks base method.
+func (m *MockAuthenticator) AuthenticateAPIKey(ctx context.Context, key string) (*domain.Client, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateAPIKey", ctx, key)
+	ret0, _ := ret[0].(*domain.Client)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateAPIKey indicates an expected call of AuthenticateAPIKey.
+func (mr *MockAuthenticatorMockRecorder) AuthenticateAPIKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateAPIKey", reflect.TypeOf((*MockAuthenticator)(nil).AuthenticateAPIKey), ctx, key)
+}
+
+// AuthenticateToken mocks base method.
+func (m *MockAuthenticator) AuthenticateToken(ctx context.Context, token string) (*domain.Client, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateToken", ctx, token)
+	ret0, _ := ret[0].(*domain.Client)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateToken indicates an expected call of AuthenticateToken.
+func (mr *MockAuthenticatorMockRecorder) AuthenticateToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateToken", reflect.TypeOf((*MockAuthenticator)(nil).AuthenticateToken), ctx, token)
+}
+
+// MockRateLimiter is a mock of RateLimiter interface.
+type MockRateLimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimiterMockRecorder
+}
+
+// MockRateLimiterMockRecorder is the mock recorder for MockRateLimiter.
+type MockRateLimiterMockRecorder struct {
+	mock *MockRateLimiter
+}
+
+// NewMockRateLimiter creates a new mock instance.
+func NewMockRateLimiter(ctrl *gomock.Controller) *MockRateLimiter {
+	mock := &MockRateLimiter{ctrl: ctrl}
+	mock.recorder = &MockRateLimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateLimiter) EXPECT() *MockRateLimiterMockRecorder {
+	return m.recorder
+}
+
+// AllowClient mocks base method.
+func (m *MockRateLimiter) AllowClient(ctx context.Context, clientID, class string) (*domain.RateLimitResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllowClient", ctx, clientID, class)
+	ret0, _ := ret[0].(*domain.RateLimitResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AllowClient indicates an expected call of AllowClient.
+func (mr *MockRateLimiterMockRecorder) AllowClient(ctx, clientID, class interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowClient", reflect.TypeOf((*MockRateLimiter)(nil).AllowClient), ctx, clientID, class)
+}
+
+// AllowUser mocks base method.
+func (m *MockRateLimiter) AllowUser(ctx context.Context, userID int, class string) (*domain.RateLimitResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllowUser", ctx, userID, class)
+	ret0, _ := ret[0].(*domain.RateLimitResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AllowUser indicates an expected call of AllowUser.
+func (mr *MockRateLimiterMockRecorder) AllowUser(ctx, userID, class interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowUser", reflect.TypeOf((*MockRateLimiter)(nil).AllowUser), ctx, userID, class)
+}
+
 // MockRepository is a mock of Repository interface.
 type MockRepository struct {
 	ctrl     *gomock.Controller
 	recorder *MockRepositoryMockRecorder
 }
 
-// MockRepositoryMockRecorder is the mock recorder for MockRepository.
-type MockRepositoryMockRecorder struct {
-	mock *MockRepository
+// MockRepositoryMockRecorder is the mock recorder for MockRepository.
+type MockRepositoryMockRecorder struct {
+	mock *MockRepository
+}
+
+// NewMockRepository creates a new mock instance.
+func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
+	mock := &MockRepository{ctrl: ctrl}
+	mock.recorder = &MockRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
+	return m.recorder
+}
+
+// ClaimEvents mocks base method.
+func (m *MockRepository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.Event, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimEvents", ctx, limit, lease)
+	ret0, _ := ret[0].([]domain.Event)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimEvents indicates an expected call of ClaimEvents.
+func (mr *MockRepositoryMockRecorder) ClaimEvents(ctx, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimEvents", reflect.TypeOf((*MockRepository)(nil).ClaimEvents), ctx, limit, lease)
+}
+
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", ctx, limit, lease)
+	ret0, _ := ret[0].([]domain.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) ClaimWebhookDeliveries(ctx, limit, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).ClaimWebhookDeliveries), ctx, limit, lease)
+}
+
+// CommitReservation mocks base method.
+func (m *MockRepository) CommitReservation(ctx context.Context, input domain.ReservationInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CommitReservation", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CommitReservation indicates an expected call of CommitReservation.
+func (mr *MockRepositoryMockRecorder) CommitReservation(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitReservation", reflect.TypeOf((*MockRepository)(nil).CommitReservation), ctx, input)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockRepository) CreateAPIKey(ctx context.Context, apiKey *domain.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, apiKey)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockRepositoryMockRecorder) CreateAPIKey(ctx, apiKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockRepository)(nil).CreateAPIKey), ctx, apiKey)
+}
+
+// CreateIdempotencyKey mocks base method.
+func (m *MockRepository) CreateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdempotencyKey", ctx, idempotencyKey)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIdempotencyKey indicates an expected call of CreateIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) CreateIdempotencyKey(ctx, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).CreateIdempotencyKey), ctx, idempotencyKey)
+}
+
+// CreateTransaction mocks base method.
+func (m *MockRepository) CreateTransaction(ctx context.Context, transaction *domain.Transaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransaction", ctx, transaction)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTransaction indicates an expected call of CreateTransaction.
+func (mr *MockRepositoryMockRecorder) CreateTransaction(ctx, transaction interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransaction", reflect.TypeOf((*MockRepository)(nil).CreateTransaction), ctx, transaction)
+}
+
+// CreateUser mocks base method.
+func (m *MockRepository) CreateUser(ctx context.Context, user *domain.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockRepositoryMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), ctx, user)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockRepository) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, webhook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockRepositoryMockRecorder) CreateWebhook(ctx, webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockRepository)(nil).CreateWebhook), ctx, webhook)
+}
+
+// CreateWebhookDeliveries mocks base method.
+func (m *MockRepository) CreateWebhookDeliveries(ctx context.Context, event domain.Event, userIDs []int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhookDeliveries", ctx, event, userIDs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWebhookDeliveries indicates an expected call of CreateWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) CreateWebhookDeliveries(ctx, event, userIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).CreateWebhookDeliveries), ctx, event, userIDs)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockRepository) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) DeleteIdempotencyKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).DeleteIdempotencyKey), ctx, key)
+}
+
+// DeleteRateLimitBuckets mocks base method.
+func (m *MockRepository) DeleteRateLimitBuckets(ctx context.Context, updatedBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRateLimitBuckets", ctx, updatedBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRateLimitBuckets indicates an expected call of DeleteRateLimitBuckets.
+func (mr *MockRepositoryMockRecorder) DeleteRateLimitBuckets(ctx, updatedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRateLimitBuckets", reflect.TypeOf((*MockRepository)(nil).DeleteRateLimitBuckets), ctx, updatedBefore)
+}
+
+// DeleteSpendingLimits mocks base method.
+func (m *MockRepository) DeleteSpendingLimits(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSpendingLimits", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSpendingLimits indicates an expected call of DeleteSpendingLimits.
+func (mr *MockRepositoryMockRecorder) DeleteSpendingLimits(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSpendingLimits", reflect.TypeOf((*MockRepository)(nil).DeleteSpendingLimits), ctx, userID)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockRepository) DeleteWebhook(ctx context.Context, clientID string, webhookID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, clientID, webhookID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockRepositoryMockRecorder) DeleteWebhook(ctx, clientID, webhookID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockRepository)(nil).DeleteWebhook), ctx, clientID, webhookID)
+}
+
+// GetAPIKeyByHash mocks base method.
+func (m *MockRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByHash", ctx, hash)
+	ret0, _ := ret[0].(*domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByHash indicates an expected call of GetAPIKeyByHash.
+func (mr *MockRepositoryMockRecorder) GetAPIKeyByHash(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByHash", reflect.TypeOf((*MockRepository)(nil).GetAPIKeyByHash), ctx, hash)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockRepository) GetIdempotencyKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", ctx, key)
+	ret0, _ := ret[0].(*domain.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) GetIdempotencyKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).GetIdempotencyKey), ctx, key)
+}
+
+// GetReservationByOrderID mocks base method.
+func (m *MockRepository) GetReservationByOrderID(ctx context.Context, orderID int) (*domain.Reservation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReservationByOrderID", ctx, orderID)
+	ret0, _ := ret[0].(*domain.Reservation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReservationByOrderID indicates an expected call of GetReservationByOrderID.
+func (mr *MockRepositoryMockRecorder) GetReservationByOrderID(ctx, orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReservationByOrderID", reflect.TypeOf((*MockRepository)(nil).GetReservationByOrderID), ctx, orderID)
+}
+
+// GetRevenueByService mocks base method.
+func (m *MockRepository) GetRevenueByService(ctx context.Context, from, to time.Time) ([]domain.ServiceRevenue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevenueByService", ctx, from, to)
+	ret0, _ := ret[0].([]domain.ServiceRevenue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevenueByService indicates an expected call of GetRevenueByService.
+func (mr *MockRepositoryMockRecorder) GetRevenueByService(ctx, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevenueByService", reflect.TypeOf((*MockRepository)(nil).GetRevenueByService), ctx, from, to)
+}
+
+// GetSpendingLimits mocks base method.
+func (m *MockRepository) GetSpendingLimits(ctx context.Context, userID int) (*domain.SpendingLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSpendingLimits", ctx, userID)
+	ret0, _ := ret[0].(*domain.SpendingLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSpendingLimits indicates an expected call of GetSpendingLimits.
+func (mr *MockRepositoryMockRecorder) GetSpendingLimits(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSpendingLimits", reflect.TypeOf((*MockRepository)(nil).GetSpendingLimits), ctx, userID)
+}
+
+// GetTransactionsByUserID mocks base method.
+func (m *MockRepository) GetTransactionsByUserID(ctx context.Context, userID int) ([]domain.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactionsByUserID", ctx, userID)
+	ret0, _ := ret[0].([]domain.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactionsByUserID indicates an expected call of GetTransactionsByUserID.
+func (mr *MockRepositoryMockRecorder) GetTransactionsByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionsByUserID", reflect.TypeOf((*MockRepository)(nil).GetTransactionsByUserID), ctx, userID)
+}
+
+// GetUser mocks base method.
+func (m *MockRepository) GetUser(ctx context.Context, userID int) (*domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, userID)
+	ret0, _ := ret[0].(*domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockRepositoryMockRecorder) GetUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), ctx, userID)
+}
+
+// GetWebhook mocks base method.
+func (m *MockRepository) GetWebhook(ctx context.Context, clientID string, webhookID int) (*domain.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhook", ctx, clientID, webhookID)
+	ret0, _ := ret[0].(*domain.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhook indicates an expected call of GetWebhook.
+func (mr *MockRepositoryMockRecorder) GetWebhook(ctx, clientID, webhookID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhook", reflect.TypeOf((*MockRepository)(nil).GetWebhook), ctx, clientID, webhookID)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockRepository) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", ctx)
+	ret0, _ := ret[0].([]domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockRepositoryMockRecorder) ListAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockRepository)(nil).ListAPIKeys), ctx)
+}
+
+// ListTransactions mocks base method.
+func (m *MockRepository) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransactions", ctx, filter)
+	ret0, _ := ret[0].([]domain.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransactions indicates an expected call of ListTransactions.
+func (mr *MockRepositoryMockRecorder) ListTransactions(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransactions", reflect.TypeOf((*MockRepository)(nil).ListTransactions), ctx, filter)
+}
+
+// ListWebhookAttempts mocks base method.
+func (m *MockRepository) ListWebhookAttempts(ctx context.Context, filter domain.WebhookAttemptFilter) ([]domain.WebhookAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookAttempts", ctx, filter)
+	ret0, _ := ret[0].([]domain.WebhookAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookAttempts indicates an expected call of ListWebhookAttempts.
+func (mr *MockRepositoryMockRecorder) ListWebhookAttempts(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookAttempts", reflect.TypeOf((*MockRepository)(nil).ListWebhookAttempts), ctx, filter)
+}
+
+// ListWebhooks mocks base method.
+func (m *MockRepository) ListWebhooks(ctx context.Context, clientID string) ([]domain.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhooks", ctx, clientID)
+	ret0, _ := ret[0].([]domain.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhooks indicates an expected call of ListWebhooks.
+func (mr *MockRepositoryMockRecorder) ListWebhooks(ctx, clientID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhooks", reflect.TypeOf((*MockRepository)(nil).ListWebhooks), ctx, clientID)
+}
+
+// MakeBalanceOperation mocks base method.
+func (m *MockRepository) MakeBalanceOperation(ctx context.Context, input domain.BalanceOperationInput, check domain.SpendingCheck) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeBalanceOperation", ctx, input, check)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MakeBalanceOperation indicates an expected call of MakeBalanceOperation.
+func (mr *MockRepositoryMockRecorder) MakeBalanceOperation(ctx, input, check interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeBalanceOperation", reflect.TypeOf((*MockRepository)(nil).MakeBalanceOperation), ctx, input, check)
+}
+
+// MakeP2PTransfer mocks base method.
+func (m *MockRepository) MakeP2PTransfer(ctx context.Context, p2pInput domain.P2PInput, check domain.SpendingCheck) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MakeP2PTransfer", ctx, p2pInput, check)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MakeP2PTransfer indicates an expected call of MakeP2PTransfer.
+func (mr *MockRepositoryMockRecorder) MakeP2PTransfer(ctx, p2pInput, check interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeP2PTransfer", reflect.TypeOf((*MockRepository)(nil).MakeP2PTransfer), ctx, p2pInput, check)
+}
+
+// MarkEventFailed mocks base method.
+func (m *MockRepository) MarkEventFailed(ctx context.Context, eventID int64, nextAttemptAt time.Time, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEventFailed", ctx, eventID, nextAttemptAt, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkEventFailed indicates an expected call of MarkEventFailed.
+func (mr *MockRepositoryMockRecorder) MarkEventFailed(ctx, eventID, nextAttemptAt, lastError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEventFailed", reflect.TypeOf((*MockRepository)(nil).MarkEventFailed), ctx, eventID, nextAttemptAt, lastError)
+}
+
+// MarkEventPublished mocks base method.
+func (m *MockRepository) MarkEventPublished(ctx context.Context, eventID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEventPublished", ctx, eventID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkEventPublished indicates an expected call of MarkEventPublished.
+func (mr *MockRepositoryMockRecorder) MarkEventPublished(ctx, eventID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEventPublished", reflect.TypeOf((*MockRepository)(nil).MarkEventPublished), ctx, eventID)
 }
 
-// NewMockRepository creates a new mock instance.
-func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
-	mock := &MockRepository{ctrl: ctrl}
-	mock.recorder = &MockRepositoryMockRecorder{mock}
-	return mock
+// RecordWebhookAttempt mocks base method.
+func (m *MockRepository) RecordWebhookAttempt(ctx context.Context, attempt *domain.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordWebhookAttempt", ctx, attempt, status, nextAttemptAt)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
-	return m.recorder
+// RecordWebhookAttempt indicates an expected call of RecordWebhookAttempt.
+func (mr *MockRepositoryMockRecorder) RecordWebhookAttempt(ctx, attempt, status, nextAttemptAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordWebhookAttempt", reflect.TypeOf((*MockRepository)(nil).RecordWebhookAttempt), ctx, attempt, status, nextAttemptAt)
 }
 
-// CreateUser mocks base method.
-func (m *MockRepository) CreateUser(user *domain.User) error {
+// ReleaseReservation mocks base method.
+func (m *MockRepository) ReleaseReservation(ctx context.Context, input domain.ReservationInput) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", user)
+	ret := m.ctrl.Call(m, "ReleaseReservation", ctx, input)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockRepositoryMockRecorder) CreateUser(user interface{}) *gomock.Call {
+// ReleaseReservation indicates an expected call of ReleaseReservation.
+func (mr *MockRepositoryMockRecorder) ReleaseReservation(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseReservation", reflect.TypeOf((*MockRepository)(nil).ReleaseReservation), ctx, input)
 }
 
-// GetUser mocks base method.
-func (m *MockRepository) GetUser(userID int) (*domain.User, error) {
+// ReserveFunds mocks base method.
+func (m *MockRepository) ReserveFunds(ctx context.Context, input domain.ReservationInput, check domain.SpendingCheck) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", userID)
-	ret0, _ := ret[0].(*domain.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret := m.ctrl.Call(m, "ReserveFunds", ctx, input, check)
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
-// GetUser indicates an expected call of GetUser.
-func (mr *MockRepositoryMockRecorder) GetUser(userID interface{}) *gomock.Call {
+// ReserveFunds indicates an expected call of ReserveFunds.
+func (mr *MockRepositoryMockRecorder) ReserveFunds(ctx, input, check interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveFunds", reflect.TypeOf((*MockRepository)(nil).ReserveFunds), ctx, input, check)
 }
 
-// MakeP2PTransfer mocks base method.
-func (m *MockRepository) MakeP2PTransfer(p2pInput domain.P2PInput) error {
+// RevokeAPIKey mocks base method.
+func (m *MockRepository) RevokeAPIKey(ctx context.Context, apiKeyID int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MakeP2PTransfer", p2pInput)
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, apiKeyID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// MakeP2PTransfer indicates an expected call of MakeP2PTransfer.
-func (mr *MockRepositoryMockRecorder) MakeP2PTransfer(p2pInput interface{}) *gomock.Call {
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockRepositoryMockRecorder) RevokeAPIKey(ctx, apiKeyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockRepository)(nil).RevokeAPIKey), ctx, apiKeyID)
+}
+
+// SetSpendingLimits mocks base method.
+func (m *MockRepository) SetSpendingLimits(ctx context.Context, userID int, limits domain.SpendingLimits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSpendingLimits", ctx, userID, limits)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSpendingLimits indicates an expected call of SetSpendingLimits.
+func (mr *MockRepositoryMockRecorder) SetSpendingLimits(ctx, userID, limits interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSpendingLimits", reflect.TypeOf((*MockRepository)(nil).SetSpendingLimits), ctx, userID, limits)
+}
+
+// UpdateIdempotencyKey mocks base method.
+func (m *MockRepository) UpdateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateIdempotencyKey", ctx, idempotencyKey)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateIdempotencyKey indicates an expected call of UpdateIdempotencyKey.
+func (mr *MockRepositoryMockRecorder) UpdateIdempotencyKey(ctx, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIdempotencyKey", reflect.TypeOf((*MockRepository)(nil).UpdateIdempotencyKey), ctx, idempotencyKey)
+}
+
+// UpdateRateLimitBucket mocks base method.
+func (m *MockRepository) UpdateRateLimitBucket(ctx context.Context, key string, update func(*domain.RateLimitBucket, time.Time)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRateLimitBucket", ctx, key, update)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRateLimitBucket indicates an expected call of UpdateRateLimitBucket.
+func (mr *MockRepositoryMockRecorder) UpdateRateLimitBucket(ctx, key, update interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeP2PTransfer", reflect.TypeOf((*MockRepository)(nil).MakeP2PTransfer), p2pInput)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRateLimitBucket", reflect.TypeOf((*MockRepository)(nil).UpdateRateLimitBucket), ctx, key, update)
 }
 
 // UpdateUser mocks base method.
-func (m *MockRepository) UpdateUser(userID int, user *domain.User) error {
+func (m *MockRepository) UpdateUser(ctx context.Context, userID int, user *domain.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", userID, user)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, userID, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateUser indicates an expected call of UpdateUser.
-func (mr *MockRepositoryMockRecorder) UpdateUser(userID, user interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) UpdateUser(ctx, userID, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockRepository)(nil).UpdateUser), ctx, userID, user)
+}
+
+// UpdateWebhook mocks base method.
+func (m *MockRepository) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhook", ctx, webhook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhook indicates an expected call of UpdateWebhook.
+func (mr *MockRepositoryMockRecorder) UpdateWebhook(ctx, webhook interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockRepository)(nil).UpdateUser), userID, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhook", reflect.TypeOf((*MockRepository)(nil).UpdateWebhook), ctx, webhook)
 }
 
 // MockService is a mock of Service interface.
@@ -114,59 +787,409 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// CommitReservation mocks base method.
+func (m *MockService) CommitReservation(ctx context.Context, input domain.ReservationInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CommitReservation", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CommitReservation indicates an expected call of CommitReservation.
+func (mr *MockServiceMockRecorder) CommitReservation(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitReservation", reflect.TypeOf((*MockService)(nil).CommitReservation), ctx, input)
+}
+
+// CreateIdempotencyKey mocks base method.
+func (m *MockService) CreateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdempotencyKey", ctx, idempotencyKey)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIdempotencyKey indicates an expected call of CreateIdempotencyKey.
+func (mr *MockServiceMockRecorder) CreateIdempotencyKey(ctx, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdempotencyKey", reflect.TypeOf((*MockService)(nil).CreateIdempotencyKey), ctx, idempotencyKey)
+}
+
+// CreateTransaction mocks base method.
+func (m *MockService) CreateTransaction(ctx context.Context, transaction *domain.Transaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransaction", ctx, transaction)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTransaction indicates an expected call of CreateTransaction.
+func (mr *MockServiceMockRecorder) CreateTransaction(ctx, transaction interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransaction", reflect.TypeOf((*MockService)(nil).CreateTransaction), ctx, transaction)
+}
+
 // CreateUser mocks base method.
-func (m *MockService) CreateUser(user *domain.User) error {
+func (m *MockService) CreateUser(ctx context.Context, user *domain.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", user)
+	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateUser indicates an expected call of CreateUser.
-func (mr *MockServiceMockRecorder) CreateUser(user interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockService)(nil).CreateUser), ctx, user)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockService) CreateWebhook(ctx context.Context, input domain.WebhookInput) (*domain.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, input)
+	ret0, _ := ret[0].(*domain.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockServiceMockRecorder) CreateWebhook(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockService)(nil).CreateWebhook), ctx, input)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockService) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockServiceMockRecorder) DeleteIdempotencyKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockService)(nil).DeleteIdempotencyKey), ctx, key)
+}
+
+// DeleteSpendingLimits mocks base method.
+func (m *MockService) DeleteSpendingLimits(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSpendingLimits", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSpendingLimits indicates an expected call of DeleteSpendingLimits.
+func (mr *MockServiceMockRecorder) DeleteSpendingLimits(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSpendingLimits", reflect.TypeOf((*MockService)(nil).DeleteSpendingLimits), ctx, userID)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockService) DeleteWebhook(ctx context.Context, clientID string, webhookID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, clientID, webhookID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockServiceMockRecorder) DeleteWebhook(ctx, clientID, webhookID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockService)(nil).DeleteWebhook), ctx, clientID, webhookID)
+}
+
+// Deposit mocks base method.
+func (m *MockService) Deposit(ctx context.Context, input domain.BalanceOperationInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deposit", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Deposit indicates an expected call of Deposit.
+func (mr *MockServiceMockRecorder) Deposit(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deposit", reflect.TypeOf((*MockService)(nil).Deposit), ctx, input)
+}
+
+// GetBalance mocks base method.
+func (m *MockService) GetBalance(ctx context.Context, input domain.GetBalanceInput) (domain.Money, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBalance", ctx, input)
+	ret0, _ := ret[0].(domain.Money)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBalance indicates an expected call of GetBalance.
+func (mr *MockServiceMockRecorder) GetBalance(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockService)(nil).GetBalance), ctx, input)
+}
+
+// GetIdempotencyKey mocks base method.
+func (m *MockService) GetIdempotencyKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", ctx, key)
+	ret0, _ := ret[0].(*domain.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockServiceMockRecorder) GetIdempotencyKey(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockService)(nil).GetIdempotencyKey), ctx, key)
+}
+
+// GetReservationByOrderID mocks base method.
+func (m *MockService) GetReservationByOrderID(ctx context.Context, orderID int) (*domain.Reservation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReservationByOrderID", ctx, orderID)
+	ret0, _ := ret[0].(*domain.Reservation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReservationByOrderID indicates an expected call of GetReservationByOrderID.
+func (mr *MockServiceMockRecorder) GetReservationByOrderID(ctx, orderID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReservationByOrderID", reflect.TypeOf((*MockService)(nil).GetReservationByOrderID), ctx, orderID)
+}
+
+// GetRevenueReport mocks base method.
+func (m *MockService) GetRevenueReport(ctx context.Context, input domain.RevenueReportInput) ([]domain.ServiceRevenue, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevenueReport", ctx, input)
+	ret0, _ := ret[0].([]domain.ServiceRevenue)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevenueReport indicates an expected call of GetRevenueReport.
+func (mr *MockServiceMockRecorder) GetRevenueReport(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevenueReport", reflect.TypeOf((*MockService)(nil).GetRevenueReport), ctx, input)
+}
+
+// GetSpendingLimits mocks base method.
+func (m *MockService) GetSpendingLimits(ctx context.Context, userID int) (*domain.UserSpendingLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSpendingLimits", ctx, userID)
+	ret0, _ := ret[0].(*domain.UserSpendingLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSpendingLimits indicates an expected call of GetSpendingLimits.
+func (mr *MockServiceMockRecorder) GetSpendingLimits(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSpendingLimits", reflect.TypeOf((*MockService)(nil).GetSpendingLimits), ctx, userID)
+}
+
+// GetTransactionsByUserID mocks base method.
+func (m *MockService) GetTransactionsByUserID(ctx context.Context, userID int) ([]domain.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactionsByUserID", ctx, userID)
+	ret0, _ := ret[0].([]domain.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactionsByUserID indicates an expected call of GetTransactionsByUserID.
+func (mr *MockServiceMockRecorder) GetTransactionsByUserID(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockService)(nil).CreateUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionsByUserID", reflect.TypeOf((*MockService)(nil).GetTransactionsByUserID), ctx, userID)
 }
 
 // GetUser mocks base method.
-func (m *MockService) GetUser(userID int) (*domain.User, error) {
+func (m *MockService) GetUser(ctx context.Context, userID int) (*domain.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", userID)
+	ret := m.ctrl.Call(m, "GetUser", ctx, userID)
 	ret0, _ := ret[0].(*domain.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUser indicates an expected call of GetUser.
-func (mr *MockServiceMockRecorder) GetUser(userID interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) GetUser(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockService)(nil).GetUser), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockService)(nil).GetUser), ctx, userID)
 }
 
-// MakeP2PTransfer mocks base method.
-func (m *MockService) MakeP2PTransfer(p2pInput domain.P2PInput) error {
+// GetWebhook mocks base method.
+func (m *MockService) GetWebhook(ctx context.Context, clientID string, webhookID int) (*domain.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhook", ctx, clientID, webhookID)
+	ret0, _ := ret[0].(*domain.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhook indicates an expected call of GetWebhook.
+func (mr *MockServiceMockRecorder) GetWebhook(ctx, clientID, webhookID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhook", reflect.TypeOf((*MockService)(nil).GetWebhook), ctx, clientID, webhookID)
+}
+
+// ListTransactions mocks base method.
+func (m *MockService) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransactions", ctx, filter)
+	ret0, _ := ret[0].([]domain.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransactions indicates an expected call of ListTransactions.
+func (mr *MockServiceMockRecorder) ListTransactions(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransactions", reflect.TypeOf((*MockService)(nil).ListTransactions), ctx, filter)
+}
+
+// ListWebhookAttempts mocks base method.
+func (m *MockService) ListWebhookAttempts(ctx context.Context, filter domain.WebhookAttemptFilter) ([]domain.WebhookAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookAttempts", ctx, filter)
+	ret0, _ := ret[0].([]domain.WebhookAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookAttempts indicates an expected call of ListWebhookAttempts.
+func (mr *MockServiceMockRecorder) ListWebhookAttempts(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookAttempts", reflect.TypeOf((*MockService)(nil).ListWebhookAttempts), ctx, filter)
+}
+
+// ListWebhooks mocks base method.
+func (m *MockService) ListWebhooks(ctx context.Context, clientID string) ([]domain.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhooks", ctx, clientID)
+	ret0, _ := ret[0].([]domain.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhooks indicates an expected call of ListWebhooks.
+func (mr *MockServiceMockRecorder) ListWebhooks(ctx, clientID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhooks", reflect.TypeOf((*MockService)(nil).ListWebhooks), ctx, clientID)
+}
+
+// ReleaseReservation mocks base method.
+func (m *MockService) ReleaseReservation(ctx context.Context, input domain.ReservationInput) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MakeP2PTransfer", p2pInput)
+	ret := m.ctrl.Call(m, "ReleaseReservation", ctx, input)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// MakeP2PTransfer indicates an expected call of MakeP2PTransfer.
-func (mr *MockServiceMockRecorder) MakeP2PTransfer(p2pInput interface{}) *gomock.Call {
+// ReleaseReservation indicates an expected call of ReleaseReservation.
+func (mr *MockServiceMockRecorder) ReleaseReservation(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseReservation", reflect.TypeOf((*MockService)(nil).ReleaseReservation), ctx, input)
+}
+
+// ReserveFunds mocks base method.
+func (m *MockService) ReserveFunds(ctx context.Context, input domain.ReservationInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveFunds", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReserveFunds indicates an expected call of ReserveFunds.
+func (mr *MockServiceMockRecorder) ReserveFunds(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveFunds", reflect.TypeOf((*MockService)(nil).ReserveFunds), ctx, input)
+}
+
+// SetSpendingLimits mocks base method.
+func (m *MockService) SetSpendingLimits(ctx context.Context, input domain.SpendingLimitsInput) (*domain.UserSpendingLimits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSpendingLimits", ctx, input)
+	ret0, _ := ret[0].(*domain.UserSpendingLimits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetSpendingLimits indicates an expected call of SetSpendingLimits.
+func (mr *MockServiceMockRecorder) SetSpendingLimits(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeP2PTransfer", reflect.TypeOf((*MockService)(nil).MakeP2PTransfer), p2pInput)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSpendingLimits", reflect.TypeOf((*MockService)(nil).SetSpendingLimits), ctx, input)
+}
+
+// Transfer mocks base method.
+func (m *MockService) Transfer(ctx context.Context, input domain.P2PInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockServiceMockRecorder) Transfer(ctx, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockService)(nil).Transfer), ctx, input)
+}
+
+// UpdateIdempotencyKey mocks base method.
+func (m *MockService) UpdateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateIdempotencyKey", ctx, idempotencyKey)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateIdempotencyKey indicates an expected call of UpdateIdempotencyKey.
+func (mr *MockServiceMockRecorder) UpdateIdempotencyKey(ctx, idempotencyKey interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIdempotencyKey", reflect.TypeOf((*MockService)(nil).UpdateIdempotencyKey), ctx, idempotencyKey)
 }
 
 // UpdateUser mocks base method.
-func (m *MockService) UpdateUser(userID int, user *domain.User) error {
+func (m *MockService) UpdateUser(ctx context.Context, userID int, user *domain.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", userID, user)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, userID, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateUser indicates an expected call of UpdateUser.
-func (mr *MockServiceMockRecorder) UpdateUser(userID, user interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) UpdateUser(ctx, userID, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockService)(nil).UpdateUser), ctx, userID, user)
+}
+
+// UpdateWebhook mocks base method.
+func (m *MockService) UpdateWebhook(ctx context.Context, webhookID int, input domain.WebhookInput) (*domain.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhook", ctx, webhookID, input)
+	ret0, _ := ret[0].(*domain.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateWebhook indicates an expected call of UpdateWebhook.
+func (mr *MockServiceMockRecorder) UpdateWebhook(ctx, webhookID, input interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhook", reflect.TypeOf((*MockService)(nil).UpdateWebhook), ctx, webhookID, input)
+}
+
+// Withdraw mocks base method.
+func (m *MockService) Withdraw(ctx context.Context, input domain.BalanceOperationInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Withdraw", ctx, input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Withdraw indicates an expected call of Withdraw.
+func (mr *MockServiceMockRecorder) Withdraw(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockService)(nil).UpdateUser), userID, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdraw", reflect.TypeOf((*MockService)(nil).Withdraw), ctx, input)
 }
@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"net/http"
+)
+
+type httpPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPPublisher returns a publisher POSTing every event as JSON to url.
+// Any response status other than 2xx counts as a failed delivery.
+func NewHTTPPublisher(url string, client *http.Client) domain.EventPublisher {
+	return &httpPublisher{
+		url:    url,
+		client: client,
+	}
+}
+
+func (p *httpPublisher) Publish(ctx context.Context, event domain.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("event receiver responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package outbox
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+)
+
+type multiPublisher struct {
+	publishers []domain.EventPublisher
+}
+
+// NewMultiPublisher returns a publisher handing every event to all the given
+// publishers in order. It stops at the first error, so the event is published
+// again to all of them on the next attempt.
+func NewMultiPublisher(publishers ...domain.EventPublisher) domain.EventPublisher {
+	return &multiPublisher{
+		publishers: publishers,
+	}
+}
+
+func (p *multiPublisher) Publish(ctx context.Context, event domain.Event) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type publisherFunc func(event domain.Event) error
+
+func (f publisherFunc) Publish(_ context.Context, event domain.Event) error {
+	return f(event)
+}
+
+func TestRelay_PublishPending(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+
+	now := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	events := []domain.Event{
+		{ID: 1, Type: domain.EventTypeDeposit, Payload: json.RawMessage(`{"user_id":1}`)},
+		{ID: 2, Type: domain.EventTypeWithdrawal, Payload: json.RawMessage(`{"user_id":2}`), Attempts: 2},
+	}
+
+	publisher := publisherFunc(func(event domain.Event) error {
+		if event.ID == 2 {
+			return errors.New("receiver is down")
+		}
+		return nil
+	})
+
+	relay := NewRelay(repository, publisher, RelayConfig{BatchSize: 10, Backoff: time.Second})
+	relay.now = func() time.Time { return now }
+
+	gomock.InOrder(
+		repository.EXPECT().ClaimEvents(gomock.Any(), 10, DefaultLease).Return(events, nil),
+		repository.EXPECT().MarkEventPublished(gomock.Any(), int64(1)).Return(nil),
+		repository.EXPECT().MarkEventFailed(gomock.Any(), int64(2), now.Add(4*time.Second), "receiver is down").Return(nil),
+	)
+
+	published, err := relay.PublishPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+}
+
+func TestRelay_PublishPending_ClaimFailed(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+	repository.EXPECT().ClaimEvents(gomock.Any(), DefaultBatchSize, DefaultLease).Return(nil, errors.New("connection refused"))
+
+	relay := NewRelay(repository, NewWriterPublisher(io.Discard), RelayConfig{})
+
+	_, err := relay.PublishPending(context.Background())
+	assert.Error(t, err)
+}
+
+func TestMultiPublisher_Publish(t *testing.T) {
+	var published []string
+	publisher := func(name string, err error) domain.EventPublisher {
+		return publisherFunc(func(event domain.Event) error {
+			published = append(published, name)
+			return err
+		})
+	}
+
+	err := NewMultiPublisher(publisher("first", nil), publisher("second", nil)).Publish(context.Background(), domain.Event{ID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, published)
+
+	published = nil
+	err = NewMultiPublisher(publisher("first", errors.New("receiver is down")), publisher("second", nil)).Publish(context.Background(), domain.Event{ID: 1})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"first"}, published)
+}
+
+func TestWriterPublisher_Publish(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := NewWriterPublisher(buf)
+
+	createdAt := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	err := p.Publish(context.Background(), domain.Event{ID: 1, Type: domain.EventTypeDeposit, Payload: json.RawMessage(`{"user_id":1}`), CreatedAt: createdAt})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":1,"type":"balance.deposit","payload":{"user_id":1},"created_at":"2022-04-11T12:00:00Z"}`+"\n", buf.String())
+}
+
+func TestHTTPPublisher_Publish(t *testing.T) {
+	status := http.StatusNoContent
+	var received domain.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p := NewHTTPPublisher(server.URL, server.Client())
+
+	err := p.Publish(context.Background(), domain.Event{ID: 7, Type: domain.EventTypeTransfer, Payload: json.RawMessage(`{"from_user_id":1}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), received.ID)
+	assert.Equal(t, domain.EventTypeTransfer, received.Type)
+
+	status = http.StatusInternalServerError
+	err = p.Publish(context.Background(), domain.Event{ID: 8, Type: domain.EventTypeTransfer, Payload: json.RawMessage(`{}`)})
+	assert.Error(t, err)
+}
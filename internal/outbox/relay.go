@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/lov3allmy/avito-test-go/internal/retry"
+	"time"
+)
+
+// RelayConfig controls how often the outbox is polled and how failed events
+// are retried. Unset settings take the defaults below.
+type RelayConfig = retry.PollConfig
+
+const (
+	DefaultInterval   = time.Second
+	DefaultBatchSize  = 100
+	DefaultLease      = 30 * time.Second
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 10 * time.Minute
+)
+
+// Relay moves events from the outbox table to a publisher. Delivery is
+// at-least-once: an event is marked published only after the publisher
+// accepted it, and failed events are retried with exponential backoff.
+type Relay struct {
+	repository domain.Repository
+	publisher  domain.EventPublisher
+	config     RelayConfig
+	now        func() time.Time
+}
+
+var relayDefaults = RelayConfig{
+	Interval:   DefaultInterval,
+	BatchSize:  DefaultBatchSize,
+	Lease:      DefaultLease,
+	Backoff:    DefaultBackoff,
+	MaxBackoff: DefaultMaxBackoff,
+}
+
+func NewRelay(repository domain.Repository, publisher domain.EventPublisher, config RelayConfig) *Relay {
+	return &Relay{
+		repository: repository,
+		publisher:  publisher,
+		config:     config.WithDefaults(relayDefaults),
+		now:        time.Now,
+	}
+}
+
+// Run publishes pending events every interval until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	retry.Poll(ctx, r.config.Interval, func(ctx context.Context) {
+		if _, err := r.PublishPending(ctx); err != nil {
+			logging.FromContext(ctx).Error("publishing outbox events failed", "error", err)
+		}
+	})
+}
+
+// PublishPending claims one batch of due events and publishes them in order.
+// It returns the number of events that were published.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	events, err := r.repository.ClaimEvents(ctx, r.config.BatchSize, r.config.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			nextAttemptAt := r.now().Add(r.config.Delay(event.Attempts))
+			if err := r.repository.MarkEventFailed(ctx, event.ID, nextAttemptAt, err.Error()); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err := r.repository.MarkEventPublished(ctx, event.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
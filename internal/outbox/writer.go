@@ -0,0 +1,38 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"io"
+	"os"
+	"sync"
+)
+
+type writerPublisher struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriterPublisher returns a publisher writing every event to w as a single
+// line of JSON.
+func NewWriterPublisher(w io.Writer) domain.EventPublisher {
+	return &writerPublisher{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// NewFilePublisher appends events to the file at path, creating it if needed.
+func NewFilePublisher(path string) (domain.EventPublisher, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterPublisher(file), nil
+}
+
+func (p *writerPublisher) Publish(_ context.Context, event domain.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.encoder.Encode(event)
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Config holds the limits applied to every API client and to every user
+// whose money a request touches. A limit with zero Rate or Burst is not
+// enforced.
+type Config struct {
+	ClientRead  domain.RateLimit
+	ClientWrite domain.RateLimit
+	UserRead    domain.RateLimit
+	UserWrite   domain.RateLimit
+}
+
+const DefaultSweepInterval = time.Minute
+
+// Store keeps token buckets. Take counts one request against the bucket
+// with the given key, Sweep forgets buckets not used since updatedBefore.
+type Store interface {
+	Take(ctx context.Context, key string, limit domain.RateLimit) (*domain.RateLimitResult, error)
+	Sweep(ctx context.Context, updatedBefore time.Time) error
+}
+
+// Limiter enforces Config using token buckets from a Store.
+type Limiter struct {
+	store  Store
+	config Config
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, config Config) *Limiter {
+	return &Limiter{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+func (l *Limiter) AllowClient(ctx context.Context, clientID, class string) (*domain.RateLimitResult, error) {
+	limit := l.config.ClientRead
+	if class == domain.RateLimitWrite {
+		limit = l.config.ClientWrite
+	}
+	return l.allow(ctx, "client:"+clientID+":"+class, limit)
+}
+
+func (l *Limiter) AllowUser(ctx context.Context, userID int, class string) (*domain.RateLimitResult, error) {
+	limit := l.config.UserRead
+	if class == domain.RateLimitWrite {
+		limit = l.config.UserWrite
+	}
+	return l.allow(ctx, "user:"+strconv.Itoa(userID)+":"+class, limit)
+}
+
+func (l *Limiter) allow(ctx context.Context, key string, limit domain.RateLimit) (*domain.RateLimitResult, error) {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return &domain.RateLimitResult{Allowed: true}, nil
+	}
+	return l.store.Take(ctx, key, limit)
+}
+
+// Run forgets idle buckets every interval until ctx is cancelled. A bucket
+// idle for longer than it takes to refill is full, so dropping it changes
+// nothing for the next request.
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := l.store.Sweep(ctx, l.now().Add(-l.refillTime())); err != nil {
+			logging.FromContext(ctx).Error("sweeping rate limit buckets failed", "error", err)
+		}
+	}
+}
+
+// refillTime returns the longest time any configured bucket takes to fill up.
+func (l *Limiter) refillTime() time.Duration {
+	var longest time.Duration
+	for _, limit := range []domain.RateLimit{l.config.ClientRead, l.config.ClientWrite, l.config.UserRead, l.config.UserWrite} {
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			continue
+		}
+		if refill := seconds(float64(limit.Burst) / limit.Rate); refill > longest {
+			longest = refill
+		}
+	}
+	return longest
+}
+
+// take refills the bucket for the time passed since its last update and
+// counts one request against it.
+func take(bucket *domain.RateLimitBucket, limit domain.RateLimit, now time.Time) *domain.RateLimitResult {
+	burst := float64(limit.Burst)
+	if now.After(bucket.UpdatedAt) {
+		bucket.Tokens = math.Min(burst, bucket.Tokens+now.Sub(bucket.UpdatedAt).Seconds()*limit.Rate)
+		bucket.UpdatedAt = now
+	}
+
+	result := &domain.RateLimitResult{Limit: limit.Burst}
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - bucket.Tokens) / limit.Rate)
+	}
+	result.Remaining = int(math.Floor(bucket.Tokens))
+	result.ResetAfter = seconds((burst - bucket.Tokens) / limit.Rate)
+
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
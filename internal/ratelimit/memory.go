@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in the process memory, so every instance of the
+// service has limits of its own.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*domain.RateLimitBucket
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*domain.RateLimitBucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit domain.RateLimit) (*domain.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &domain.RateLimitBucket{Key: key, Tokens: float64(limit.Burst), UpdatedAt: now}
+		s.buckets[key] = bucket
+	}
+
+	return take(bucket, limit, now), nil
+}
+
+func (s *MemoryStore) Sweep(_ context.Context, updatedBefore time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, bucket := range s.buckets {
+		if bucket.UpdatedAt.Before(updatedBefore) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table, so that all
+// instances of the service share the same limits.
+type PostgresStore struct {
+	repository domain.Repository
+}
+
+func NewPostgresStore(repository domain.Repository) *PostgresStore {
+	return &PostgresStore{repository: repository}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit domain.RateLimit) (*domain.RateLimitResult, error) {
+	var result *domain.RateLimitResult
+	err := s.repository.UpdateRateLimitBucket(ctx, key, func(bucket *domain.RateLimitBucket, now time.Time) {
+		result = take(bucket, limit, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *PostgresStore) Sweep(ctx context.Context, updatedBefore time.Time) error {
+	return s.repository.DeleteRateLimitBuckets(ctx, updatedBefore)
+}
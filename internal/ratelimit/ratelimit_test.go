@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	limit := domain.RateLimit{Rate: 0.5, Burst: 2}
+
+	result, err := store.Take(context.Background(), "client:shop:write", limit)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, &domain.RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 2 * time.Second})
+
+	result, err = store.Take(context.Background(), "client:shop:write", limit)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, &domain.RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: 4 * time.Second})
+
+	result, err = store.Take(context.Background(), "client:shop:write", limit)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, &domain.RateLimitResult{Allowed: false, Limit: 2, Remaining: 0, RetryAfter: 2 * time.Second, ResetAfter: 4 * time.Second})
+
+	// Other keys have buckets of their own.
+	result, err = store.Take(context.Background(), "client:bank:write", limit)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Allowed, true)
+
+	now = now.Add(3 * time.Second)
+	result, err = store.Take(context.Background(), "client:shop:write", limit)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, &domain.RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: 3 * time.Second})
+
+	assert.Equal(t, store.Sweep(context.Background(), now), nil)
+	assert.Equal(t, len(store.buckets), 1)
+}
+
+func TestPostgresStore_Take(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	now := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+
+	var saved domain.RateLimitBucket
+	repository := mock_domain.NewMockRepository(c)
+	repository.EXPECT().UpdateRateLimitBucket(gomock.Any(), "user:1:write", gomock.Any()).DoAndReturn(func(_ context.Context, key string, update func(bucket *domain.RateLimitBucket, now time.Time)) error {
+		// A new bucket is created empty at the epoch and refills in full.
+		bucket := &domain.RateLimitBucket{Key: key, UpdatedAt: time.Unix(0, 0)}
+		update(bucket, now)
+		saved = *bucket
+		return nil
+	})
+	repository.EXPECT().DeleteRateLimitBuckets(gomock.Any(), now).Return(nil)
+
+	store := NewPostgresStore(repository)
+
+	result, err := store.Take(context.Background(), "user:1:write", domain.RateLimit{Rate: 1, Burst: 5})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, &domain.RateLimitResult{Allowed: true, Limit: 5, Remaining: 4, ResetAfter: time.Second})
+	assert.Equal(t, saved, domain.RateLimitBucket{Key: "user:1:write", Tokens: 4, UpdatedAt: now})
+
+	assert.Equal(t, store.Sweep(context.Background(), now), nil)
+}
+
+func TestLimiter(t *testing.T) {
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, Config{
+		ClientWrite: domain.RateLimit{Rate: 1, Burst: 1},
+		UserWrite:   domain.RateLimit{Rate: 1, Burst: 3},
+	})
+
+	result, err := limiter.AllowClient(context.Background(), "shop", domain.RateLimitWrite)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Allowed, true)
+	assert.Equal(t, result.Limit, 1)
+
+	result, err = limiter.AllowClient(context.Background(), "shop", domain.RateLimitWrite)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Allowed, false)
+
+	result, err = limiter.AllowUser(context.Background(), 1, domain.RateLimitWrite)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Allowed, true)
+	assert.Equal(t, result.Limit, 3)
+
+	// Reads are not limited in this config.
+	result, err = limiter.AllowClient(context.Background(), "shop", domain.RateLimitRead)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, &domain.RateLimitResult{Allowed: true})
+
+	assert.Equal(t, limiter.refillTime(), 3*time.Second)
+	assert.Equal(t, len(store.buckets), 2)
+}
@@ -0,0 +1,58 @@
+package rates
+
+import (
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cachedRate struct {
+	rate      float64
+	expiresAt time.Time
+}
+
+type cachedProvider struct {
+	provider domain.RateProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	rates map[string]cachedRate
+}
+
+// NewCachedProvider wraps a provider so that every currency is requested from
+// it at most once per ttl.
+func NewCachedProvider(provider domain.RateProvider, ttl time.Duration) domain.RateProvider {
+	return &cachedProvider{
+		provider: provider,
+		ttl:      ttl,
+		now:      time.Now,
+		rates:    make(map[string]cachedRate),
+	}
+}
+
+func (p *cachedProvider) GetRate(currency string) (float64, error) {
+	currency = strings.ToUpper(currency)
+
+	p.mu.Lock()
+	cached, ok := p.rates[currency]
+	p.mu.Unlock()
+	if ok && p.now().Before(cached.expiresAt) {
+		return cached.rate, nil
+	}
+
+	rate, err := p.provider.GetRate(currency)
+	if err != nil {
+		return 0, err
+	}
+
+	p.mu.Lock()
+	p.rates[currency] = cachedRate{
+		rate:      rate,
+		expiresAt: p.now().Add(p.ttl),
+	}
+	p.mu.Unlock()
+
+	return rate, nil
+}
@@ -0,0 +1,58 @@
+package rates
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type httpProvider struct {
+	url    string
+	base   string
+	client *http.Client
+}
+
+// NewHTTPProvider returns a provider asking an exchangerates-style API for
+// the latest rates: GET <url>?base=<base>&symbols=<currency>.
+func NewHTTPProvider(url, base string, client *http.Client) domain.RateProvider {
+	return &httpProvider{
+		url:    url,
+		base:   strings.ToUpper(base),
+		client: client,
+	}
+}
+
+func (p *httpProvider) GetRate(currency string) (float64, error) {
+	currency = strings.ToUpper(currency)
+	if currency == p.base {
+		return 1, nil
+	}
+
+	query := url.Values{}
+	query.Set("base", p.base)
+	query.Set("symbols", currency)
+
+	resp, err := p.client.Get(p.url + "?" + query.Encode())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("rates provider responded with status %d", resp.StatusCode)
+	}
+
+	response := ratesResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, err
+	}
+
+	rate, ok := response.Rates[currency]
+	if !ok {
+		return 0, domain.ErrUnknownCurrency
+	}
+	return rate, nil
+}
@@ -0,0 +1,95 @@
+package rates
+
+import (
+	"errors"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticProvider_GetRate(t *testing.T) {
+	p := NewStaticProvider(map[string]float64{"usd": 0.0125})
+
+	rate, err := p.GetRate("USD")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0125, rate)
+
+	_, err = p.GetRate("EUR")
+	assert.True(t, errors.Is(err, domain.ErrUnknownCurrency))
+}
+
+func TestFileProvider_GetRate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	err := os.WriteFile(path, []byte(`{"base":"RUB","rates":{"USD":0.0125,"EUR":0.0117}}`), 0o600)
+	assert.NoError(t, err)
+
+	p, err := NewFileProvider(path)
+	assert.NoError(t, err)
+
+	rate, err := p.GetRate("eur")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0117, rate)
+}
+
+func TestHTTPProvider_GetRate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "RUB", r.URL.Query().Get("base"))
+		if r.URL.Query().Get("symbols") == "USD" {
+			_, _ = w.Write([]byte(`{"base":"RUB","rates":{"USD":0.0125}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"base":"RUB","rates":{}}`))
+	}))
+	defer server.Close()
+
+	p := NewHTTPProvider(server.URL, "rub", server.Client())
+
+	rate, err := p.GetRate("usd")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0125, rate)
+
+	rate, err = p.GetRate("RUB")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, rate)
+
+	_, err = p.GetRate("XYZ")
+	assert.True(t, errors.Is(err, domain.ErrUnknownCurrency))
+}
+
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) GetRate(currency string) (float64, error) {
+	p.calls++
+	return float64(p.calls), nil
+}
+
+func TestCachedProvider_GetRate(t *testing.T) {
+	source := &countingProvider{}
+	p := NewCachedProvider(source, time.Minute).(*cachedProvider)
+
+	now := time.Date(2022, 4, 10, 12, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	rate, err := p.GetRate("USD")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, rate)
+
+	now = now.Add(30 * time.Second)
+	rate, err = p.GetRate("usd")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, rate)
+	assert.Equal(t, 1, source.calls)
+
+	now = now.Add(time.Minute)
+	rate, err = p.GetRate("USD")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, rate)
+	assert.Equal(t, 2, source.calls)
+}
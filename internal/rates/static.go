@@ -0,0 +1,53 @@
+package rates
+
+import (
+	"encoding/json"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"os"
+	"strings"
+)
+
+// ratesResponse is the exchangerates-style payload shared by the HTTP API and
+// the rates file: {"base":"RUB","rates":{"USD":0.0125,"EUR":0.0117}}.
+type ratesResponse struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+type staticProvider struct {
+	rates map[string]float64
+}
+
+func NewStaticProvider(rates map[string]float64) domain.RateProvider {
+	normalized := make(map[string]float64, len(rates))
+	for currency, rate := range rates {
+		normalized[strings.ToUpper(currency)] = rate
+	}
+	return &staticProvider{
+		rates: normalized,
+	}
+}
+
+// NewFileProvider reads rates once from a JSON file in the same format as the
+// HTTP API response, which is handy for tests and offline deployments.
+func NewFileProvider(path string) (domain.RateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	response := ratesResponse{}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+
+	return NewStaticProvider(response.Rates), nil
+}
+
+func (p *staticProvider) GetRate(currency string) (float64, error) {
+	rate, ok := p.rates[strings.ToUpper(currency)]
+	if !ok {
+		return 0, domain.ErrUnknownCurrency
+	}
+	return rate, nil
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// These tests need a real database, since sqlmock cannot emulate row locks.
+// Point TEST_POSTGRES_DSN at a database migrated with "avito-test-go migrate up", e.g.
+// TEST_POSTGRES_DSN="host=localhost port=5436 user=postgres password=qwerty dbname=avito_test_go sslmode=disable"
+const (
+	concurrencyFirstUserID  = 900001
+	concurrencySecondUserID = 900002
+)
+
+func connectToTestPostgres(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connecting to test database failed with error: %s", err)
+	}
+	db.SetMaxOpenConns(50)
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func resetTestUsers(t *testing.T, db *sqlx.DB, balances map[int]int64) {
+	t.Helper()
+	for userID, balance := range balances {
+		db.MustExec("DELETE FROM transactions WHERE user_id = $1 OR counterparty_id = $1", userID)
+		db.MustExec("DELETE FROM reservations WHERE user_id = $1", userID)
+		db.MustExec("INSERT INTO users (id, balance) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET balance = $2, reserved_balance = 0", userID, balance)
+	}
+}
+
+func getTestBalance(t *testing.T, db *sqlx.DB, userID int) int64 {
+	t.Helper()
+	var balance int64
+	if err := db.Get(&balance, "SELECT balance FROM users WHERE id = $1", userID); err != nil {
+		t.Fatalf("getting balance failed with error: %s", err)
+	}
+	return balance
+}
+
+func TestRepository_MakeBalanceOperation_ConcurrentWithdrawals(t *testing.T) {
+	db := connectToTestPostgres(t)
+	resetTestUsers(t, db, map[int]int64{concurrencyFirstUserID: 10000})
+
+	r := NewRepository(db)
+
+	const requests = 300
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		rejected  int
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := r.MakeBalanceOperation(context.Background(), domain.BalanceOperationInput{
+				UserID: concurrencyFirstUserID,
+				Amount: domain.NewMoney(100, domain.DefaultCurrency),
+				Type:   domain.TransactionTypeSubtract,
+			}, nil)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, domain.ErrInsufficientFunds):
+				rejected++
+			default:
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, succeeded)
+	assert.Equal(t, requests-100, rejected)
+	assert.Equal(t, int64(0), getTestBalance(t, db, concurrencyFirstUserID))
+}
+
+func TestRepository_MakeBalanceOperation_ConcurrentSpendingLimit(t *testing.T) {
+	db := connectToTestPostgres(t)
+	resetTestUsers(t, db, map[int]int64{concurrencyFirstUserID: 10000})
+
+	r := NewRepository(db)
+
+	// The limit allows 10 withdrawals of 100 out of a balance of 10000.
+	limit := domain.NewMoney(1000, domain.DefaultCurrency)
+	amount := domain.NewMoney(100, domain.DefaultCurrency)
+	check := func(ctx context.Context, outgoingTotal domain.OutgoingTotal) error {
+		spent, err := outgoingTotal(time.Now().Add(-time.Hour))
+		if err != nil {
+			return err
+		}
+		total, err := spent.Add(amount)
+		if err != nil {
+			return err
+		}
+		if limit.LessThan(total) {
+			return domain.ErrSpendingLimit
+		}
+		return nil
+	}
+
+	const requests = 50
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := r.MakeBalanceOperation(context.Background(), domain.BalanceOperationInput{
+				UserID: concurrencyFirstUserID,
+				Amount: amount,
+				Type:   domain.TransactionTypeSubtract,
+			}, check)
+			if err != nil && !errors.Is(err, domain.ErrSpendingLimit) {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, succeeded)
+	assert.Equal(t, int64(9000), getTestBalance(t, db, concurrencyFirstUserID))
+}
+
+func TestRepository_MakeP2PTransfer_ConcurrentOppositeTransfers(t *testing.T) {
+	db := connectToTestPostgres(t)
+	resetTestUsers(t, db, map[int]int64{concurrencyFirstUserID: 5000, concurrencySecondUserID: 5000})
+
+	r := NewRepository(db)
+
+	const requests = 400
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		input := domain.P2PInput{FromUserID: concurrencyFirstUserID, ToUserID: concurrencySecondUserID, Amount: domain.NewMoney(300, domain.DefaultCurrency)}
+		if i%2 == 1 {
+			input.FromUserID, input.ToUserID = input.ToUserID, input.FromUserID
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := r.MakeP2PTransfer(context.Background(), input, nil)
+			if err != nil && !errors.Is(err, domain.ErrInsufficientFunds) {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	first := getTestBalance(t, db, concurrencyFirstUserID)
+	second := getTestBalance(t, db, concurrencySecondUserID)
+	assert.GreaterOrEqual(t, first, int64(0))
+	assert.GreaterOrEqual(t, second, int64(0))
+	assert.Equal(t, int64(10000), first+second)
+}
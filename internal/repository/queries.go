@@ -0,0 +1,73 @@
+package repository
+
+import "fmt"
+
+// queryNames maps the queries of the repository to the names of their
+// constants.
+var queryNames = newQueryNames()
+
+func newQueryNames() map[string]string {
+	names := map[string]string{
+		QueryGetUser:                     "QueryGetUser",
+		QueryLockUser:                    "QueryLockUser",
+		QueryCreateUser:                  "QueryCreateUser",
+		QueryUpdateUser:                  "QueryUpdateUser",
+		QueryTakeFromUserBalance:         "QueryTakeFromUserBalance",
+		QueryPutToUserBalance:            "QueryPutToUserBalance",
+		QueryCreateTransaction:           "QueryCreateTransaction",
+		QueryGetTransactionsByUserID:     "QueryGetTransactionsByUserID",
+		QueryGetRevenueByService:         "QueryGetRevenueByService",
+		QueryReserveUserBalance:          "QueryReserveUserBalance",
+		QueryTakeFromUserReservedBalance: "QueryTakeFromUserReservedBalance",
+		QueryReturnUserReservedBalance:   "QueryReturnUserReservedBalance",
+		QueryCreateReservation:           "QueryCreateReservation",
+		QueryGetReservationByOrderID:     "QueryGetReservationByOrderID",
+		QueryLockReservationByOrderID:    "QueryLockReservationByOrderID",
+		QueryUpdateReservationStatus:     "QueryUpdateReservationStatus",
+		QueryGetIdempotencyKey:           "QueryGetIdempotencyKey",
+		QueryCreateIdempotencyKey:        "QueryCreateIdempotencyKey",
+		QueryUpdateIdempotencyKey:        "QueryUpdateIdempotencyKey",
+		QueryDeleteIdempotencyKey:        "QueryDeleteIdempotencyKey",
+		QueryCreateEvent:                 "QueryCreateEvent",
+		QueryClaimEvents:                 "QueryClaimEvents",
+		QueryMarkEventPublished:          "QueryMarkEventPublished",
+		QueryMarkEventFailed:             "QueryMarkEventFailed",
+		QueryCreateWebhook:               "QueryCreateWebhook",
+		QueryGetWebhook:                  "QueryGetWebhook",
+		QueryListWebhooks:                "QueryListWebhooks",
+		QueryUpdateWebhook:               "QueryUpdateWebhook",
+		QueryDeleteWebhook:               "QueryDeleteWebhook",
+		QueryCreateWebhookDeliveries:     "QueryCreateWebhookDeliveries",
+		QueryClaimWebhookDeliveries:      "QueryClaimWebhookDeliveries",
+		QueryUpdateWebhookDelivery:       "QueryUpdateWebhookDelivery",
+		QueryCreateWebhookAttempt:        "QueryCreateWebhookAttempt",
+		QueryListWebhookAttempts:         "QueryListWebhookAttempts",
+		QueryCreateAPIKey:                "QueryCreateAPIKey",
+		QueryGetAPIKeyByHash:             "QueryGetAPIKeyByHash",
+		QueryListAPIKeys:                 "QueryListAPIKeys",
+		QueryRevokeAPIKey:                "QueryRevokeAPIKey",
+		QueryCreateRateLimitBucket:       "QueryCreateRateLimitBucket",
+		QueryLockRateLimitBucket:         "QueryLockRateLimitBucket",
+		QueryUpdateRateLimitBucket:       "QueryUpdateRateLimitBucket",
+		QueryDeleteRateLimitBuckets:      "QueryDeleteRateLimitBuckets",
+		QueryGetSpendingLimits:           "QueryGetSpendingLimits",
+		QuerySetSpendingLimits:           "QuerySetSpendingLimits",
+		QueryDeleteSpendingLimits:        "QueryDeleteSpendingLimits",
+		QueryGetOutgoingTotal:            "QueryGetOutgoingTotal",
+	}
+
+	// The transaction list is sorted by a column and in an order formatted
+	// into the query.
+	for _, column := range transactionSortColumns {
+		for _, order := range sortOrders {
+			names[fmt.Sprintf(QueryListTransactions, column, order)] = "QueryListTransactions"
+		}
+	}
+	return names
+}
+
+// QueryName returns the name of the constant a query of the repository comes
+// from, e.g. "QueryGetUser" for QueryGetUser, or "" for any other query.
+func QueryName(query string) string {
+	return queryNames[query]
+}
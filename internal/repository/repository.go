@@ -1,20 +1,118 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"sort"
+	"time"
 )
 
+const pgUniqueViolation = "23505"
+
 const (
 	QueryGetUser             = "SELECT * FROM users WHERE id = $1"
+	QueryLockUser            = "SELECT * FROM users WHERE id = $1 FOR UPDATE"
 	QueryCreateUser          = "INSERT INTO users (id, balance) VALUES ($1, $2)"
 	QueryUpdateUser          = "UPDATE users SET balance = $1 WHERE id = $2"
 	QueryTakeFromUserBalance = "UPDATE users SET balance = (balance - $1) WHERE id = $2"
 	QueryPutToUserBalance    = "UPDATE users SET balance = (balance + $1) WHERE id = $2"
+
+	QueryCreateTransaction       = "INSERT INTO transactions (user_id, type, amount, counterparty_id, service_id, order_id, comment, client_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	QueryGetTransactionsByUserID = "SELECT id, user_id, type, amount, counterparty_id, service_id, order_id, comment, client_id, created_at FROM transactions WHERE user_id = $1 ORDER BY created_at DESC, id DESC"
+	QueryListTransactions        = "SELECT id, user_id, type, amount, counterparty_id, service_id, order_id, comment, client_id, created_at FROM transactions WHERE user_id = $1 ORDER BY %[1]s %[2]s, id %[2]s LIMIT $2 OFFSET $3"
+
+	QueryGetRevenueByService = "SELECT service_id, SUM(amount) AS revenue FROM transactions WHERE type IN ($1, $2) AND service_id IS NOT NULL AND created_at >= $3 AND created_at < $4 GROUP BY service_id ORDER BY service_id"
+
+	QueryReserveUserBalance          = "UPDATE users SET balance = (balance - $1), reserved_balance = (reserved_balance + $1) WHERE id = $2 AND balance >= $1"
+	QueryTakeFromUserReservedBalance = "UPDATE users SET reserved_balance = (reserved_balance - $1) WHERE id = $2 AND reserved_balance >= $1"
+	QueryReturnUserReservedBalance   = "UPDATE users SET balance = (balance + $1), reserved_balance = (reserved_balance - $1) WHERE id = $2 AND reserved_balance >= $1"
+
+	QueryCreateReservation        = "INSERT INTO reservations (user_id, service_id, order_id, amount, status) VALUES ($1, $2, $3, $4, $5)"
+	QueryGetReservationByOrderID  = "SELECT id, user_id, service_id, order_id, amount, status, created_at, updated_at FROM reservations WHERE order_id = $1"
+	QueryLockReservationByOrderID = QueryGetReservationByOrderID + " FOR UPDATE"
+	QueryUpdateReservationStatus  = "UPDATE reservations SET status = $1, updated_at = now() WHERE id = $2"
+
+	QueryGetIdempotencyKey    = "SELECT key, request_hash, response_status, response_body, created_at, expires_at FROM idempotency_keys WHERE key = $1 AND expires_at > now()"
+	QueryCreateIdempotencyKey = "INSERT INTO idempotency_keys (key, request_hash, response_status, response_body, expires_at) VALUES ($1, $2, 0, NULL, $3) ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, response_status = 0, response_body = NULL, created_at = now(), expires_at = EXCLUDED.expires_at WHERE idempotency_keys.expires_at <= now()"
+	QueryUpdateIdempotencyKey = "UPDATE idempotency_keys SET response_status = $1, response_body = $2 WHERE key = $3"
+	QueryDeleteIdempotencyKey = "DELETE FROM idempotency_keys WHERE key = $1"
+
+	QueryCreateEvent        = "INSERT INTO outbox (type, payload) VALUES ($1, $2)"
+	QueryClaimEvents        = "UPDATE outbox SET next_attempt_at = now() + $2 * interval '1 second' WHERE id IN (SELECT id FROM outbox WHERE published_at IS NULL AND next_attempt_at <= now() ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED) RETURNING id, type, payload, attempts, last_error, created_at, next_attempt_at, published_at"
+	QueryMarkEventPublished = "UPDATE outbox SET published_at = now(), last_error = NULL WHERE id = $1"
+	QueryMarkEventFailed    = "UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3 WHERE id = $1"
+
+	QueryCreateWebhook = "INSERT INTO webhooks (client_id, url, event_types, user_id, secret, active) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at"
+	QueryGetWebhook    = "SELECT id, client_id, url, event_types, user_id, secret, active, created_at, updated_at FROM webhooks WHERE id = $1 AND client_id = $2"
+	QueryListWebhooks  = "SELECT id, client_id, url, event_types, user_id, secret, active, created_at, updated_at FROM webhooks WHERE client_id = $1 ORDER BY id"
+	QueryUpdateWebhook = "UPDATE webhooks SET url = $1, event_types = $2, user_id = $3, secret = $4, active = $5, updated_at = now() WHERE id = $6 AND client_id = $7 RETURNING updated_at"
+	QueryDeleteWebhook = "DELETE FROM webhooks WHERE id = $1 AND client_id = $2"
+
+	QueryCreateWebhookDeliveries = "INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, body) SELECT id, $1, $2, $3 FROM webhooks WHERE active AND $2 = ANY(event_types) AND (user_id IS NULL OR user_id = ANY($4)) ON CONFLICT (webhook_id, event_id) DO NOTHING"
+	QueryClaimWebhookDeliveries  = "UPDATE webhook_deliveries d SET next_attempt_at = now() + $2 * interval '1 second' FROM webhooks w WHERE w.id = d.webhook_id AND d.id IN (SELECT wd.id FROM webhook_deliveries wd JOIN webhooks ON webhooks.id = wd.webhook_id WHERE webhooks.active AND wd.status = $3 AND wd.next_attempt_at <= now() ORDER BY wd.id LIMIT $1 FOR UPDATE OF wd SKIP LOCKED) RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.body, d.status, d.attempts, w.url, w.secret"
+	QueryUpdateWebhookDelivery   = "UPDATE webhook_deliveries SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4 WHERE id = $5"
+	QueryCreateWebhookAttempt    = "INSERT INTO webhook_attempts (delivery_id, attempt, status_code, error, duration_ms) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
+	QueryListWebhookAttempts     = "SELECT a.id, a.delivery_id, d.event_id, d.event_type, a.attempt, a.status_code, a.error, a.duration_ms, d.status AS delivery_status, a.created_at FROM webhook_attempts a JOIN webhook_deliveries d ON d.id = a.delivery_id JOIN webhooks w ON w.id = d.webhook_id WHERE d.webhook_id = $1 AND w.client_id = $4 ORDER BY a.id DESC LIMIT $2 OFFSET $3"
+
+	QueryCreateAPIKey    = "INSERT INTO api_keys (client_id, prefix, key_hash, scopes) VALUES ($1, $2, $3, $4) RETURNING id, created_at"
+	QueryGetAPIKeyByHash = "SELECT id, client_id, prefix, key_hash, scopes, created_at, revoked_at FROM api_keys WHERE key_hash = $1"
+	QueryListAPIKeys     = "SELECT id, client_id, prefix, key_hash, scopes, created_at, revoked_at FROM api_keys ORDER BY id"
+	QueryRevokeAPIKey    = "UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL"
+
+	QueryCreateRateLimitBucket  = "INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, 0, 'epoch') ON CONFLICT (key) DO NOTHING"
+	QueryLockRateLimitBucket    = "SELECT key, tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE"
+	QueryUpdateRateLimitBucket  = "UPDATE rate_limit_buckets SET tokens = $1, updated_at = $2 WHERE key = $3"
+	QueryDeleteRateLimitBuckets = "DELETE FROM rate_limit_buckets WHERE updated_at < $1"
+
+	QueryGetSpendingLimits    = "SELECT daily, monthly, max_transfer FROM spending_limits WHERE user_id = $1"
+	QuerySetSpendingLimits    = "INSERT INTO spending_limits (user_id, daily, monthly, max_transfer) VALUES ($1, $2, $3, $4) ON CONFLICT (user_id) DO UPDATE SET daily = EXCLUDED.daily, monthly = EXCLUDED.monthly, max_transfer = EXCLUDED.max_transfer, updated_at = now()"
+	QueryDeleteSpendingLimits = "DELETE FROM spending_limits WHERE user_id = $1"
+	QueryGetOutgoingTotal     = "SELECT (SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE user_id = $1 AND type IN ($2, $3, $4) AND created_at >= $6) + (SELECT COALESCE(SUM(amount), 0) FROM reservations WHERE user_id = $1 AND status = $5 AND created_at >= $6)"
 )
 
+var transactionSortColumns = map[string]string{
+	domain.TransactionSortByDate:   "created_at",
+	domain.TransactionSortByAmount: "amount",
+}
+
+var sortOrders = map[string]string{
+	domain.SortOrderAsc:  "ASC",
+	domain.SortOrderDesc: "DESC",
+}
+
+// webhookRow is a webhook as it is stored, with the event types in a
+// Postgres array.
+type webhookRow struct {
+	domain.Webhook
+	EventTypes pq.StringArray `db:"event_types"`
+}
+
+func (row webhookRow) toWebhook() *domain.Webhook {
+	webhook := row.Webhook
+	webhook.EventTypes = row.EventTypes
+	return &webhook
+}
+
+// apiKeyRow is an API key as it is stored, with the scopes in a Postgres
+// array.
+type apiKeyRow struct {
+	domain.APIKey
+	Scopes pq.StringArray `db:"scopes"`
+}
+
+func (row apiKeyRow) toAPIKey() *domain.APIKey {
+	apiKey := row.APIKey
+	apiKey.Scopes = row.Scopes
+	return &apiKey
+}
+
 type repository struct {
 	postgres *sqlx.DB
 }
@@ -25,10 +123,10 @@ func NewRepository(db *sqlx.DB) domain.Repository {
 	}
 }
 
-func (r *repository) GetUser(userID int) (*domain.User, error) {
+func (r *repository) GetUser(ctx context.Context, userID int) (*domain.User, error) {
 	user := &domain.User{}
 
-	err := r.postgres.Get(user, QueryGetUser, userID)
+	err := r.postgres.GetContext(ctx, user, QueryGetUser, userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -39,44 +137,66 @@ func (r *repository) GetUser(userID int) (*domain.User, error) {
 	return user, nil
 }
 
-func (r *repository) CreateUser(user *domain.User) error {
-	res, err := r.postgres.Exec(QueryCreateUser, user.ID, user.Balance)
+func (r *repository) CreateUser(ctx context.Context, user *domain.User) error {
+	res, err := r.postgres.ExecContext(ctx, QueryCreateUser, user.ID, user.Balance)
 	if err != nil {
-		return err
+		return mapPostgresError(err)
 	}
 	createdRows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 	if createdRows == 0 {
-		return errors.New("no one rows created")
+		return domain.ErrInternal.Wrap(errNothingInserted)
 	}
 	return nil
 }
 
-func (r *repository) UpdateUser(userID int, user *domain.User) error {
-	res, err := r.postgres.Exec(QueryUpdateUser, user.Balance, userID)
+func (r *repository) UpdateUser(ctx context.Context, userID int, user *domain.User) error {
+	res, err := r.postgres.ExecContext(ctx, QueryUpdateUser, user.Balance, userID)
 	if err != nil {
-		return err
+		return mapPostgresError(err)
 	}
 	updatedRows, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 	if updatedRows == 0 {
-		return errors.New("no one rows updated")
+		return domain.ErrUserNotFound
 	}
 
 	return nil
 }
 
-func (r *repository) MakeP2PTransfer(p2pInput domain.P2PInput) error {
-	tx, err := r.postgres.Begin()
+// MakeBalanceOperation puts money on the balance or takes it off. A
+// withdrawal is checked against the balance and the spending check with the
+// user row locked.
+func (r *repository) MakeBalanceOperation(ctx context.Context, input domain.BalanceOperationInput, check domain.SpendingCheck) error {
+	tx, err := r.postgres.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	res, err := tx.Exec(QueryTakeFromUserBalance, p2pInput.Amount, p2pInput.FromUserID)
+	query := QueryPutToUserBalance
+	if input.Type == domain.TransactionTypeSubtract {
+		query = QueryTakeFromUserBalance
+
+		user, err := lockUser(ctx, tx, input.UserID)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := checkSpending(ctx, tx, input.UserID, check); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if user.Balance.LessThan(input.Amount) {
+			_ = tx.Rollback()
+			return domain.ErrInsufficientFunds
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, query, input.Amount, input.UserID)
 	if err != nil {
 		_ = tx.Rollback()
 		return err
@@ -88,10 +208,91 @@ func (r *repository) MakeP2PTransfer(p2pInput domain.P2PInput) error {
 	}
 	if updatedRows == 0 {
 		_ = tx.Rollback()
-		return errors.New("no one rows updated")
+		return domain.ErrUserNotFound
 	}
 
-	res, err = tx.Exec(QueryPutToUserBalance, p2pInput.Amount, p2pInput.ToUserID)
+	err = createTransaction(ctx, tx, &domain.Transaction{
+		UserID:    input.UserID,
+		Type:      input.Type,
+		Amount:    input.Amount,
+		ServiceID: nullableID(input.ServiceID),
+		OrderID:   nullableID(input.OrderID),
+		Comment:   input.Comment,
+		ClientID:  nullableString(input.ClientID),
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	eventType := domain.EventTypeDeposit
+	if input.Type == domain.TransactionTypeSubtract {
+		eventType = domain.EventTypeWithdrawal
+	}
+	err = createEvent(ctx, tx, eventType, domain.BalanceEvent{
+		UserID:    input.UserID,
+		Amount:    input.Amount,
+		ServiceID: nullableID(input.ServiceID),
+		OrderID:   nullableID(input.OrderID),
+		Comment:   input.Comment,
+		ClientID:  input.ClientID,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) MakeP2PTransfer(ctx context.Context, p2pInput domain.P2PInput, check domain.SpendingCheck) error {
+	tx, err := r.postgres.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Both rows are always locked in ascending id order, so two transfers
+	// going in opposite directions cannot deadlock each other.
+	lockOrder := []int{p2pInput.FromUserID, p2pInput.ToUserID}
+	if lockOrder[0] > lockOrder[1] {
+		lockOrder[0], lockOrder[1] = lockOrder[1], lockOrder[0]
+	}
+	var fromUser *domain.User
+	for _, userID := range lockOrder {
+		user, err := lockUser(ctx, tx, userID)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if userID == p2pInput.FromUserID {
+			fromUser = user
+		}
+	}
+	if err := checkSpending(ctx, tx, p2pInput.FromUserID, check); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if fromUser.Balance.LessThan(p2pInput.Amount) {
+		_ = tx.Rollback()
+		return domain.ErrInsufficientFunds
+	}
+
+	res, err := tx.ExecContext(ctx, QueryTakeFromUserBalance, p2pInput.Amount, p2pInput.FromUserID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	updatedRows, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if updatedRows == 0 {
+		_ = tx.Rollback()
+		return domain.ErrUserNotFound
+	}
+
+	res, err = tx.ExecContext(ctx, QueryPutToUserBalance, p2pInput.Amount, p2pInput.ToUserID)
 	if err != nil {
 		_ = tx.Rollback()
 		return err
@@ -103,8 +304,701 @@ func (r *repository) MakeP2PTransfer(p2pInput domain.P2PInput) error {
 	}
 	if updatedRows == 0 {
 		_ = tx.Rollback()
-		return errors.New("no one rows updated")
+		return domain.ErrUserNotFound
+	}
+
+	err = createTransaction(ctx, tx, &domain.Transaction{
+		UserID:         p2pInput.FromUserID,
+		Type:           domain.TransactionTypeP2POut,
+		Amount:         p2pInput.Amount,
+		CounterpartyID: &p2pInput.ToUserID,
+		Comment:        p2pInput.Comment,
+		ClientID:       nullableString(p2pInput.ClientID),
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	err = createTransaction(ctx, tx, &domain.Transaction{
+		UserID:         p2pInput.ToUserID,
+		Type:           domain.TransactionTypeP2PIn,
+		Amount:         p2pInput.Amount,
+		CounterpartyID: &p2pInput.FromUserID,
+		Comment:        p2pInput.Comment,
+		ClientID:       nullableString(p2pInput.ClientID),
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	err = createEvent(ctx, tx, domain.EventTypeTransfer, domain.TransferEvent{
+		FromUserID: p2pInput.FromUserID,
+		ToUserID:   p2pInput.ToUserID,
+		Amount:     p2pInput.Amount,
+		Comment:    p2pInput.Comment,
+		ClientID:   p2pInput.ClientID,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) CreateTransaction(ctx context.Context, transaction *domain.Transaction) error {
+	return createTransaction(ctx, r.postgres, transaction)
+}
+
+func (r *repository) GetTransactionsByUserID(ctx context.Context, userID int) ([]domain.Transaction, error) {
+	transactions := make([]domain.Transaction, 0)
+
+	err := r.postgres.SelectContext(ctx, &transactions, QueryGetTransactionsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+func (r *repository) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	column, ok := transactionSortColumns[filter.SortBy]
+	if !ok {
+		return nil, domain.ErrInvalidInput.WithMessage(fmt.Sprintf("unknown sort field %q", filter.SortBy))
+	}
+	order, ok := sortOrders[filter.Order]
+	if !ok {
+		return nil, domain.ErrInvalidInput.WithMessage(fmt.Sprintf("unknown sort order %q", filter.Order))
+	}
+
+	transactions := make([]domain.Transaction, 0)
+
+	err := r.postgres.SelectContext(ctx, &transactions, fmt.Sprintf(QueryListTransactions, column, order), filter.UserID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// GetRevenueByService sums up the charges made for every service in the
+// [from, to) period. Charges are plain withdrawals carrying a service id and
+// committed reservations.
+func (r *repository) GetRevenueByService(ctx context.Context, from, to time.Time) ([]domain.ServiceRevenue, error) {
+	revenues := make([]domain.ServiceRevenue, 0)
+
+	err := r.postgres.SelectContext(ctx, &revenues, QueryGetRevenueByService, domain.TransactionTypeSubtract, domain.TransactionTypeCommit, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return revenues, nil
+}
+
+func (r *repository) GetReservationByOrderID(ctx context.Context, orderID int) (*domain.Reservation, error) {
+	reservation := &domain.Reservation{}
+
+	err := r.postgres.GetContext(ctx, reservation, QueryGetReservationByOrderID, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+func (r *repository) ReserveFunds(ctx context.Context, input domain.ReservationInput, check domain.SpendingCheck) error {
+	tx, err := r.postgres.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	user, err := lockUser(ctx, tx, input.UserID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := checkSpending(ctx, tx, input.UserID, check); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if user.Balance.LessThan(input.Amount) {
+		_ = tx.Rollback()
+		return domain.ErrInsufficientFunds
+	}
+
+	res, err := tx.ExecContext(ctx, QueryReserveUserBalance, input.Amount, input.UserID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	updatedRows, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if updatedRows == 0 {
+		_ = tx.Rollback()
+		return domain.ErrInsufficientFunds
+	}
+
+	_, err = tx.ExecContext(ctx, QueryCreateReservation, input.UserID, input.ServiceID, input.OrderID, input.Amount, domain.ReservationStatusReserved)
+	if err != nil {
+		_ = tx.Rollback()
+		return mapPostgresError(err)
+	}
+
+	err = createTransaction(ctx, tx, &domain.Transaction{
+		UserID:    input.UserID,
+		Type:      domain.TransactionTypeReserve,
+		Amount:    input.Amount,
+		ServiceID: &input.ServiceID,
+		OrderID:   &input.OrderID,
+		ClientID:  nullableString(input.ClientID),
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	err = createEvent(ctx, tx, domain.EventTypeReservationCreated, domain.ReservationEvent{
+		UserID:    input.UserID,
+		ServiceID: input.ServiceID,
+		OrderID:   input.OrderID,
+		Amount:    input.Amount,
+		ClientID:  input.ClientID,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) CommitReservation(ctx context.Context, input domain.ReservationInput) error {
+	return r.finishReservation(ctx, input, domain.ReservationStatusCommitted, QueryTakeFromUserReservedBalance, domain.TransactionTypeCommit, domain.EventTypeReservationCommitted)
+}
+
+func (r *repository) ReleaseReservation(ctx context.Context, input domain.ReservationInput) error {
+	return r.finishReservation(ctx, input, domain.ReservationStatusReleased, QueryReturnUserReservedBalance, domain.TransactionTypeRelease, domain.EventTypeReservationReleased)
+}
+
+// finishReservation moves a reservation out of the "reserved" status. The
+// reservation row is locked for the duration of the transaction and checked
+// against the input again, so concurrent commit and release requests for the
+// same order cannot both succeed.
+func (r *repository) finishReservation(ctx context.Context, input domain.ReservationInput, status, balanceQuery, transactionType, eventType string) error {
+	tx, err := r.postgres.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	reservation := &domain.Reservation{}
+	err = tx.GetContext(ctx, reservation, QueryLockReservationByOrderID, input.OrderID)
+	if err != nil {
+		_ = tx.Rollback()
+		if err == sql.ErrNoRows {
+			return domain.ErrReservationNotFound
+		}
+		return err
+	}
+	if !reservation.Matches(input) {
+		_ = tx.Rollback()
+		return domain.ErrReservationMismatch
+	}
+	if reservation.Status != domain.ReservationStatusReserved {
+		_ = tx.Rollback()
+		return domain.ErrConflict.WithMessage("reservation is already " + reservation.Status)
+	}
+
+	res, err := tx.ExecContext(ctx, balanceQuery, reservation.Amount, reservation.UserID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	updatedRows, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if updatedRows == 0 {
+		_ = tx.Rollback()
+		return domain.ErrConflict.WithMessage("reserved balance is less than the reservation amount")
+	}
+
+	_, err = tx.ExecContext(ctx, QueryUpdateReservationStatus, status, reservation.ID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	err = createTransaction(ctx, tx, &domain.Transaction{
+		UserID:    reservation.UserID,
+		Type:      transactionType,
+		Amount:    reservation.Amount,
+		ServiceID: &reservation.ServiceID,
+		OrderID:   &reservation.OrderID,
+		ClientID:  nullableString(input.ClientID),
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	err = createEvent(ctx, tx, eventType, domain.ReservationEvent{
+		UserID:    reservation.UserID,
+		ServiceID: reservation.ServiceID,
+		OrderID:   reservation.OrderID,
+		Amount:    reservation.Amount,
+		ClientID:  input.ClientID,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) GetIdempotencyKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	idempotencyKey := &domain.IdempotencyKey{}
+
+	err := r.postgres.GetContext(ctx, idempotencyKey, QueryGetIdempotencyKey, key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return idempotencyKey, nil
+}
+
+// CreateIdempotencyKey claims the key for a new request. It returns false when
+// the key is already claimed by another request and has not expired yet.
+func (r *repository) CreateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) (bool, error) {
+	res, err := r.postgres.ExecContext(ctx, QueryCreateIdempotencyKey, idempotencyKey.Key, idempotencyKey.RequestHash, idempotencyKey.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	createdRows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return createdRows != 0, nil
+}
+
+func (r *repository) UpdateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) error {
+	res, err := r.postgres.ExecContext(ctx, QueryUpdateIdempotencyKey, idempotencyKey.ResponseStatus, idempotencyKey.ResponseBody, idempotencyKey.Key)
+	if err != nil {
+		return err
+	}
+	updatedRows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updatedRows == 0 {
+		return domain.ErrConflict.WithMessage("idempotency key does not exist anymore")
+	}
+	return nil
+}
+
+func (r *repository) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	_, err := r.postgres.ExecContext(ctx, QueryDeleteIdempotencyKey, key)
+	return err
+}
+
+// ClaimEvents returns up to limit events that are due for publishing, oldest
+// first, and hides them from other callers for the lease duration. Events that
+// are neither marked published nor failed before the lease ends are claimed
+// again, so a crashed relay cannot lose them.
+func (r *repository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.Event, error) {
+	events := make([]domain.Event, 0)
+
+	err := r.postgres.SelectContext(ctx, &events, QueryClaimEvents, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
+
+func (r *repository) MarkEventPublished(ctx context.Context, eventID int64) error {
+	_, err := r.postgres.ExecContext(ctx, QueryMarkEventPublished, eventID)
+	return err
+}
+
+func (r *repository) MarkEventFailed(ctx context.Context, eventID int64, nextAttemptAt time.Time, lastError string) error {
+	_, err := r.postgres.ExecContext(ctx, QueryMarkEventFailed, eventID, nextAttemptAt, lastError)
+	return err
+}
+
+func (r *repository) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	row := r.postgres.QueryRowxContext(ctx, QueryCreateWebhook, webhook.ClientID, webhook.URL, pq.StringArray(webhook.EventTypes), webhook.UserID, webhook.Secret, webhook.Active)
+	return row.Scan(&webhook.ID, &webhook.CreatedAt, &webhook.UpdatedAt)
+}
+
+func (r *repository) GetWebhook(ctx context.Context, clientID string, webhookID int) (*domain.Webhook, error) {
+	row := webhookRow{}
+
+	err := r.postgres.GetContext(ctx, &row, QueryGetWebhook, webhookID, clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return row.toWebhook(), nil
+}
+
+func (r *repository) ListWebhooks(ctx context.Context, clientID string) ([]domain.Webhook, error) {
+	rows := make([]webhookRow, 0)
+
+	err := r.postgres.SelectContext(ctx, &rows, QueryListWebhooks, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]domain.Webhook, 0, len(rows))
+	for _, row := range rows {
+		webhooks = append(webhooks, *row.toWebhook())
+	}
+	return webhooks, nil
+}
+
+func (r *repository) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	row := r.postgres.QueryRowxContext(ctx, QueryUpdateWebhook, webhook.URL, pq.StringArray(webhook.EventTypes), webhook.UserID, webhook.Secret, webhook.Active, webhook.ID, webhook.ClientID)
+	err := row.Scan(&webhook.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return domain.ErrWebhookNotFound
+	}
+	return err
+}
+
+func (r *repository) DeleteWebhook(ctx context.Context, clientID string, webhookID int) error {
+	res, err := r.postgres.ExecContext(ctx, QueryDeleteWebhook, webhookID, clientID)
+	if err != nil {
+		return err
+	}
+	deletedRows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deletedRows == 0 {
+		return domain.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// CreateWebhookDeliveries queues the event for every active webhook
+// subscribed to its type and, for webhooks bound to a user, concerning one of
+// userIDs. Queueing the same event twice is a no-op.
+func (r *repository) CreateWebhookDeliveries(ctx context.Context, event domain.Event, userIDs []int) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ids := make(pq.Int64Array, 0, len(userIDs))
+	for _, userID := range userIDs {
+		ids = append(ids, int64(userID))
+	}
+
+	_, err = r.postgres.ExecContext(ctx, QueryCreateWebhookDeliveries, event.ID, event.Type, body, ids)
+	return err
+}
+
+// ClaimWebhookDeliveries returns up to limit pending deliveries of active
+// webhooks that are due, oldest first, and hides them from other callers for
+// the lease duration.
+func (r *repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	deliveries := make([]domain.WebhookDelivery, 0)
+
+	err := r.postgres.SelectContext(ctx, &deliveries, QueryClaimWebhookDeliveries, limit, lease.Seconds(), domain.WebhookDeliveryStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt saves the attempt and moves its delivery to the given
+// status. A delivery left pending is tried again at nextAttemptAt.
+func (r *repository) RecordWebhookAttempt(ctx context.Context, attempt *domain.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	tx, err := r.postgres.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	row := tx.QueryRowxContext(ctx, QueryCreateWebhookAttempt, attempt.DeliveryID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.DurationMS)
+	if err := row.Scan(&attempt.ID, &attempt.CreatedAt); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, QueryUpdateWebhookDelivery, status, attempt.Attempt, attempt.Error, nextAttemptAt, attempt.DeliveryID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
 	}
+	attempt.DeliveryStatus = status
 
 	return tx.Commit()
 }
+
+func (r *repository) ListWebhookAttempts(ctx context.Context, filter domain.WebhookAttemptFilter) ([]domain.WebhookAttempt, error) {
+	attempts := make([]domain.WebhookAttempt, 0)
+
+	err := r.postgres.SelectContext(ctx, &attempts, QueryListWebhookAttempts, filter.WebhookID, filter.Limit, filter.Offset, filter.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+func (r *repository) CreateAPIKey(ctx context.Context, apiKey *domain.APIKey) error {
+	row := r.postgres.QueryRowxContext(ctx, QueryCreateAPIKey, apiKey.ClientID, apiKey.Prefix, apiKey.Hash, pq.StringArray(apiKey.Scopes))
+	return mapPostgresError(row.Scan(&apiKey.ID, &apiKey.CreatedAt))
+}
+
+func (r *repository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	row := apiKeyRow{}
+
+	err := r.postgres.GetContext(ctx, &row, QueryGetAPIKeyByHash, hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return row.toAPIKey(), nil
+}
+
+func (r *repository) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	rows := make([]apiKeyRow, 0)
+
+	err := r.postgres.SelectContext(ctx, &rows, QueryListAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys := make([]domain.APIKey, 0, len(rows))
+	for _, row := range rows {
+		apiKeys = append(apiKeys, *row.toAPIKey())
+	}
+	return apiKeys, nil
+}
+
+// RevokeAPIKey makes the key unusable. Revoking an unknown or already revoked
+// key gives domain.ErrNotFound.
+func (r *repository) RevokeAPIKey(ctx context.Context, apiKeyID int) error {
+	res, err := r.postgres.ExecContext(ctx, QueryRevokeAPIKey, apiKeyID)
+	if err != nil {
+		return err
+	}
+	revokedRows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revokedRows == 0 {
+		return domain.ErrNotFound.WithMessage("there is no active api key with that id")
+	}
+
+	return nil
+}
+
+// UpdateRateLimitBucket locks the bucket, creating it empty and last updated
+// at the Unix epoch if it does not exist, and saves the changes made by
+// update. update gets the database clock, so that all instances sharing the
+// bucket agree on the time.
+func (r *repository) UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *domain.RateLimitBucket, now time.Time)) error {
+	tx, err := r.postgres.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, QueryCreateRateLimitBucket, key); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	bucket := &domain.RateLimitBucket{}
+	var now time.Time
+	if err := tx.QueryRowxContext(ctx, QueryLockRateLimitBucket, key).Scan(&bucket.Key, &bucket.Tokens, &bucket.UpdatedAt, &now); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	update(bucket, now)
+
+	if _, err := tx.ExecContext(ctx, QueryUpdateRateLimitBucket, bucket.Tokens, bucket.UpdatedAt, key); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) DeleteRateLimitBuckets(ctx context.Context, updatedBefore time.Time) error {
+	_, err := r.postgres.ExecContext(ctx, QueryDeleteRateLimitBuckets, updatedBefore)
+	return err
+}
+
+func (r *repository) GetSpendingLimits(ctx context.Context, userID int) (*domain.SpendingLimits, error) {
+	limits := &domain.SpendingLimits{}
+
+	err := r.postgres.GetContext(ctx, limits, QueryGetSpendingLimits, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+func (r *repository) SetSpendingLimits(ctx context.Context, userID int, limits domain.SpendingLimits) error {
+	_, err := r.postgres.ExecContext(ctx, QuerySetSpendingLimits, userID, limits.Daily, limits.Monthly, limits.MaxTransfer)
+	return err
+}
+
+func (r *repository) DeleteSpendingLimits(ctx context.Context, userID int) error {
+	res, err := r.postgres.ExecContext(ctx, QueryDeleteSpendingLimits, userID)
+	if err != nil {
+		return err
+	}
+	deletedRows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deletedRows == 0 {
+		return domain.ErrNotFound.WithMessage("there are no spending limits for that user")
+	}
+
+	return nil
+}
+
+// checkSpending runs the spending check of an operation, if there is one,
+// after the user row has been locked by tx. Operations of the same user wait
+// for each other on that lock, so each of them sees the totals including the
+// ones committed before it.
+func checkSpending(ctx context.Context, tx *sqlx.Tx, userID int, check domain.SpendingCheck) error {
+	if check == nil {
+		return nil
+	}
+	return check(ctx, func(since time.Time) (domain.Money, error) {
+		return getOutgoingTotal(ctx, tx, userID, since)
+	})
+}
+
+// getOutgoingTotal sums the money that has left the balance of the user since
+// the given time: withdrawals, outgoing transfers, committed reservations and
+// reservations still held.
+func getOutgoingTotal(ctx context.Context, queryer sqlx.QueryerContext, userID int, since time.Time) (domain.Money, error) {
+	var total domain.Money
+
+	err := sqlx.GetContext(ctx, queryer, &total, QueryGetOutgoingTotal, userID, domain.TransactionTypeSubtract, domain.TransactionTypeP2POut, domain.TransactionTypeCommit, domain.ReservationStatusReserved, since)
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	return total, nil
+}
+
+// lockUser fetches the user row and locks it until the end of tx, so that a
+// balance check made on the result stays valid until the balance is updated.
+func lockUser(ctx context.Context, tx *sqlx.Tx, userID int) (*domain.User, error) {
+	user := &domain.User{}
+
+	err := tx.GetContext(ctx, user, QueryLockUser, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, domain.ErrUserNotFound.WithMessage(fmt.Sprintf("there is no user with id %d", userID))
+		}
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Debug("user locked", "user_id", userID, "balance", user.Balance)
+	return user, nil
+}
+
+// createTransaction appends an entry to the ledger using either the plain
+// connection or an already opened transaction, so that balance changes and
+// their ledger entries can be committed together.
+func createTransaction(ctx context.Context, execer sqlx.ExecerContext, transaction *domain.Transaction) error {
+	res, err := execer.ExecContext(ctx, QueryCreateTransaction, transaction.UserID, transaction.Type, transaction.Amount, transaction.CounterpartyID, transaction.ServiceID, transaction.OrderID, transaction.Comment, transaction.ClientID)
+	if err != nil {
+		return mapPostgresError(err)
+	}
+	createdRows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if createdRows == 0 {
+		return domain.ErrInternal.Wrap(errNothingInserted)
+	}
+
+	logging.FromContext(ctx).Debug("ledger entry written", "user_id", transaction.UserID, "type", transaction.Type, "amount", transaction.Amount)
+	return nil
+}
+
+// createEvent writes an event to the outbox, normally inside the transaction
+// making the change the event is about.
+func createEvent(ctx context.Context, execer sqlx.ExecerContext, eventType string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := execer.ExecContext(ctx, QueryCreateEvent, eventType, body); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Debug("event written to outbox", "event_type", eventType)
+	return nil
+}
+
+// errNothingInserted is the cause of the internal error returned when an
+// INSERT reports no rows, which Postgres only does if something is badly off.
+var errNothingInserted = errors.New("insert affected no rows")
+
+// mapPostgresError turns constraint violations reported by Postgres into
+// domain errors. Any other error is returned as is.
+func mapPostgresError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+		return domain.ErrDuplicate.Wrap(err)
+	}
+	return err
+}
+
+// nullableID maps the zero value of an optional id to NULL.
+func nullableID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// nullableString maps an empty optional string to NULL.
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -1,13 +1,27 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/lov3allmy/avito-test-go/internal/domain"
 	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
 	"testing"
+	"time"
 )
 
+func rubles(amount int64) domain.Money {
+	return domain.NewMoney(amount*100, domain.DefaultCurrency)
+}
+
 func TestRepository_CreateUser(t *testing.T) {
 	mockDB, mock, err := sqlmock.New()
 	if err != nil {
@@ -24,7 +38,7 @@ func TestRepository_CreateUser(t *testing.T) {
 		name         string
 		mockBehavior mockBehavior
 		user         domain.User
-		expectedErr  bool
+		expectedErr  error
 	}{
 		{
 			name: "OK",
@@ -33,21 +47,698 @@ func TestRepository_CreateUser(t *testing.T) {
 			},
 			user: domain.User{
 				ID:      1,
-				Balance: 0,
+				Balance: rubles(0),
 			},
 		},
+		{
+			name: "Duplicate",
+			mockBehavior: func(user domain.User) {
+				mock.ExpectExec("INSERT INTO users").WithArgs(user.ID, user.Balance).WillReturnError(&pq.Error{Code: "23505"})
+			},
+			user: domain.User{
+				ID:      1,
+				Balance: rubles(0),
+			},
+			expectedErr: domain.ErrDuplicate,
+		},
+		{
+			name: "Nothing inserted",
+			mockBehavior: func(user domain.User) {
+				mock.ExpectExec("INSERT INTO users").WithArgs(user.ID, user.Balance).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			user: domain.User{
+				ID:      1,
+				Balance: rubles(0),
+			},
+			expectedErr: domain.ErrInternal,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			test.mockBehavior(test.user)
-			err := r.CreateUser(&test.user)
+			err := r.CreateUser(context.Background(), &test.user)
+			assert.ErrorIs(t, err, test.expectedErr)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_MakeBalanceOperation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	type mockBehavior func(input domain.BalanceOperationInput)
+
+	tests := []struct {
+		name         string
+		mockBehavior mockBehavior
+		input        domain.BalanceOperationInput
+		check        domain.SpendingCheck
+		expectedErr  bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(input domain.BalanceOperationInput) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE users SET balance = \\(balance \\+").WithArgs(input.Amount, input.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO transactions").WithArgs(input.UserID, input.Type, input.Amount, nil, nil, nil, input.Comment, nil).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO outbox").WithArgs(domain.EventTypeDeposit, []byte(`{"user_id":1,"amount":"10.00","comment":"top up"}`)).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			input: domain.BalanceOperationInput{
+				UserID:  1,
+				Amount:  rubles(10),
+				Type:    domain.TransactionTypeAdd,
+				Comment: "top up",
+			},
+		},
+		{
+			name: "Outbox insert failed",
+			mockBehavior: func(input domain.BalanceOperationInput) {
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE users SET balance = \\(balance \\+").WithArgs(input.Amount, input.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO transactions").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO outbox").WithArgs(domain.EventTypeDeposit, sqlmock.AnyArg()).WillReturnError(errors.New("insert failed"))
+				mock.ExpectRollback()
+			},
+			input: domain.BalanceOperationInput{
+				UserID: 1,
+				Amount: rubles(10),
+				Type:   domain.TransactionTypeAdd,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Ledger insert failed",
+			mockBehavior: func(input domain.BalanceOperationInput) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT \\* FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(input.UserID).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "reserved_balance"}).AddRow(input.UserID, 1000, 0))
+				mock.ExpectExec("UPDATE users SET balance = \\(balance -").WithArgs(input.Amount, input.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO transactions").WillReturnError(errors.New("insert failed"))
+				mock.ExpectRollback()
+			},
+			input: domain.BalanceOperationInput{
+				UserID: 1,
+				Amount: rubles(10),
+				Type:   domain.TransactionTypeSubtract,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Spending limit",
+			mockBehavior: func(input domain.BalanceOperationInput) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT \\* FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(input.UserID).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "reserved_balance"}).AddRow(input.UserID, 100000, 0))
+				mock.ExpectQuery("SELECT \\(SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM transactions").WithArgs(input.UserID, domain.TransactionTypeSubtract, domain.TransactionTypeP2POut, domain.TransactionTypeCommit, domain.ReservationStatusReserved, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow([]byte("99500")))
+				mock.ExpectRollback()
+			},
+			input: domain.BalanceOperationInput{
+				UserID: 1,
+				Amount: rubles(10),
+				Type:   domain.TransactionTypeSubtract,
+			},
+			check: func(ctx context.Context, outgoingTotal domain.OutgoingTotal) error {
+				spent, err := outgoingTotal(time.Now())
+				if err != nil {
+					return err
+				}
+				if spent != rubles(995) {
+					return fmt.Errorf("spent %s", spent)
+				}
+				return domain.ErrSpendingLimit
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Not enough balance",
+			mockBehavior: func(input domain.BalanceOperationInput) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT \\* FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(input.UserID).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "reserved_balance"}).AddRow(input.UserID, 500, 0))
+				mock.ExpectRollback()
+			},
+			input: domain.BalanceOperationInput{
+				UserID: 1,
+				Amount: rubles(10),
+				Type:   domain.TransactionTypeSubtract,
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(test.input)
+			err := r.MakeBalanceOperation(context.Background(), test.input, test.check)
+			if test.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_MakeP2PTransfer(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	input := domain.P2PInput{
+		FromUserID: 2,
+		ToUserID:   1,
+		Amount:     rubles(10),
+		ClientID:   "shop",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(input.ToUserID).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "reserved_balance"}).AddRow(input.ToUserID, 0, 0))
+	mock.ExpectQuery("SELECT \\* FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(input.FromUserID).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "reserved_balance"}).AddRow(input.FromUserID, 1000, 0))
+	mock.ExpectExec("UPDATE users SET balance = \\(balance -").WithArgs(input.Amount, input.FromUserID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET balance = \\(balance \\+").WithArgs(input.Amount, input.ToUserID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO transactions").WithArgs(input.FromUserID, domain.TransactionTypeP2POut, input.Amount, input.ToUserID, nil, nil, "", input.ClientID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO transactions").WithArgs(input.ToUserID, domain.TransactionTypeP2PIn, input.Amount, input.FromUserID, nil, nil, "", input.ClientID).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(domain.EventTypeTransfer, []byte(`{"from_user_id":2,"to_user_id":1,"amount":"10.00","client_id":"shop"}`)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, r.MakeP2PTransfer(context.Background(), input, nil))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetTransactionsByUserID(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	createdAt := time.Date(2022, 4, 10, 12, 0, 0, 0, time.UTC)
+	counterpartyID := 2
+	rows := sqlmock.NewRows([]string{"id", "user_id", "type", "amount", "counterparty_id", "service_id", "order_id", "comment", "created_at"}).
+		AddRow(2, 1, domain.TransactionTypeP2POut, 500, counterpartyID, nil, nil, "", createdAt).
+		AddRow(1, 1, domain.TransactionTypeAdd, 1000, nil, nil, nil, "top up", createdAt)
+
+	mock.ExpectQuery("SELECT (.+) FROM transactions WHERE user_id = \\$1").WithArgs(1).WillReturnRows(rows)
+
+	transactions, err := r.GetTransactionsByUserID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Transaction{
+		{ID: 2, UserID: 1, Type: domain.TransactionTypeP2POut, Amount: rubles(5), CounterpartyID: &counterpartyID, CreatedAt: createdAt},
+		{ID: 1, UserID: 1, Type: domain.TransactionTypeAdd, Amount: rubles(10), Comment: "top up", CreatedAt: createdAt},
+	}, transactions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ListTransactions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	tests := []struct {
+		name          string
+		filter        domain.TransactionFilter
+		expectedQuery string
+		expectedErr   bool
+	}{
+		{
+			name:          "Sort by amount ascending",
+			filter:        domain.TransactionFilter{UserID: 1, Limit: 10, Offset: 5, SortBy: "amount", Order: "asc"},
+			expectedQuery: "ORDER BY amount ASC, id ASC LIMIT \\$2 OFFSET \\$3",
+		},
+		{
+			name:          "Sort by date descending",
+			filter:        domain.TransactionFilter{UserID: 1, Limit: 10, SortBy: "date", Order: "desc"},
+			expectedQuery: "ORDER BY created_at DESC, id DESC LIMIT \\$2 OFFSET \\$3",
+		},
+		{
+			name:        "Unknown sort field",
+			filter:      domain.TransactionFilter{UserID: 1, Limit: 10, SortBy: "id; DROP TABLE users", Order: "desc"},
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !test.expectedErr {
+				rows := sqlmock.NewRows([]string{"id", "user_id", "type", "amount", "counterparty_id", "service_id", "order_id", "comment", "created_at"})
+				mock.ExpectQuery(test.expectedQuery).WithArgs(test.filter.UserID, test.filter.Limit, test.filter.Offset).WillReturnRows(rows)
+			}
+			transactions, err := r.ListTransactions(context.Background(), test.filter)
 			if test.expectedErr {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
+				assert.Empty(t, transactions)
 			}
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
+
+func TestRepository_ReserveFunds(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	input := domain.ReservationInput{UserID: 1, ServiceID: 2, OrderID: 3, Amount: rubles(10)}
+	since := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	// The spending check reads the total after the user row is locked.
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM users WHERE id = \\$1 FOR UPDATE").WithArgs(input.UserID).WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "reserved_balance"}).AddRow(input.UserID, 1000, 0))
+	mock.ExpectQuery("SELECT \\(SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM transactions WHERE user_id = \\$1 AND type IN \\(\\$2, \\$3, \\$4\\) AND created_at >= \\$6\\) \\+ \\(SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM reservations WHERE user_id = \\$1 AND status = \\$5 AND created_at >= \\$6\\)").
+		WithArgs(input.UserID, domain.TransactionTypeSubtract, domain.TransactionTypeP2POut, domain.TransactionTypeCommit, domain.ReservationStatusReserved, since).
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow([]byte("150000")))
+	mock.ExpectExec("UPDATE users SET balance = \\(balance - \\$1\\), reserved_balance").WithArgs(input.Amount, input.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO reservations").WithArgs(input.UserID, input.ServiceID, input.OrderID, input.Amount, domain.ReservationStatusReserved).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO transactions").WithArgs(input.UserID, domain.TransactionTypeReserve, input.Amount, nil, input.ServiceID, input.OrderID, "", nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO outbox").WithArgs(domain.EventTypeReservationCreated, []byte(`{"user_id":1,"service_id":2,"order_id":3,"amount":"10.00"}`)).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	var spent domain.Money
+	assert.NoError(t, r.ReserveFunds(context.Background(), input, func(ctx context.Context, outgoingTotal domain.OutgoingTotal) error {
+		var err error
+		spent, err = outgoingTotal(since)
+		return err
+	}))
+	assert.Equal(t, domain.NewMoney(150000, domain.DefaultCurrency), spent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CommitReservation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	type mockBehavior func(input domain.ReservationInput)
+
+	reservationRows := func(status string) *sqlmock.Rows {
+		now := time.Now()
+		return sqlmock.NewRows([]string{"id", "user_id", "service_id", "order_id", "amount", "status", "created_at", "updated_at"}).
+			AddRow(7, 1, 2, 3, 1000, status, now, now)
+	}
+
+	tests := []struct {
+		name         string
+		mockBehavior mockBehavior
+		input        domain.ReservationInput
+		expectedErr  error
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(input domain.ReservationInput) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT (.+) FROM reservations WHERE order_id = \\$1 FOR UPDATE").WithArgs(input.OrderID).WillReturnRows(reservationRows(domain.ReservationStatusReserved))
+				mock.ExpectExec("UPDATE users SET reserved_balance").WithArgs(input.Amount, input.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE reservations SET status").WithArgs(domain.ReservationStatusCommitted, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO transactions").WithArgs(input.UserID, domain.TransactionTypeCommit, input.Amount, nil, input.ServiceID, input.OrderID, "", nil).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO outbox").WithArgs(domain.EventTypeReservationCommitted, []byte(`{"user_id":1,"service_id":2,"order_id":3,"amount":"10.00"}`)).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			input: domain.ReservationInput{UserID: 1, ServiceID: 2, OrderID: 3, Amount: rubles(10)},
+		},
+		{
+			name: "Amount differs",
+			mockBehavior: func(input domain.ReservationInput) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT (.+) FROM reservations").WithArgs(input.OrderID).WillReturnRows(reservationRows(domain.ReservationStatusReserved))
+				mock.ExpectRollback()
+			},
+			input:       domain.ReservationInput{UserID: 1, ServiceID: 2, OrderID: 3, Amount: rubles(11)},
+			expectedErr: domain.ErrReservationMismatch,
+		},
+		{
+			name: "Already released",
+			mockBehavior: func(input domain.ReservationInput) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT (.+) FROM reservations").WithArgs(input.OrderID).WillReturnRows(reservationRows(domain.ReservationStatusReleased))
+				mock.ExpectRollback()
+			},
+			input:       domain.ReservationInput{UserID: 1, ServiceID: 2, OrderID: 3, Amount: rubles(10)},
+			expectedErr: domain.ErrConflict,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.mockBehavior(test.input)
+			err := r.CommitReservation(context.Background(), test.input)
+			assert.ErrorIs(t, err, test.expectedErr)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_GetRevenueByService(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	from := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	rows := sqlmock.NewRows([]string{"service_id", "revenue"}).
+		AddRow(1, []byte("10000")).
+		AddRow(2, []byte("5000"))
+	mock.ExpectQuery("SELECT service_id, SUM\\(amount\\) AS revenue FROM transactions (.+) GROUP BY service_id").
+		WithArgs(domain.TransactionTypeSubtract, domain.TransactionTypeCommit, from, to).
+		WillReturnRows(rows)
+
+	revenues, err := r.GetRevenueByService(context.Background(), from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.ServiceRevenue{{ServiceID: 1, Revenue: rubles(100)}, {ServiceID: 2, Revenue: rubles(50)}}, revenues)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CreateIdempotencyKey(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	idempotencyKey := &domain.IdempotencyKey{
+		Key:         "key-1",
+		RequestHash: "hash",
+		ExpiresAt:   time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name            string
+		rowsAffected    int64
+		expectedCreated bool
+	}{
+		{
+			name:            "New key",
+			rowsAffected:    1,
+			expectedCreated: true,
+		},
+		{
+			name:            "Key already claimed",
+			rowsAffected:    0,
+			expectedCreated: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock.ExpectExec("INSERT INTO idempotency_keys (.+) ON CONFLICT \\(key\\) DO UPDATE (.+) WHERE idempotency_keys.expires_at <= now\\(\\)").
+				WithArgs(idempotencyKey.Key, idempotencyKey.RequestHash, idempotencyKey.ExpiresAt).
+				WillReturnResult(sqlmock.NewResult(0, test.rowsAffected))
+
+			created, err := r.CreateIdempotencyKey(context.Background(), idempotencyKey)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCreated, created)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_UpdateIdempotencyKey(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	idempotencyKey := &domain.IdempotencyKey{
+		Key:            "key-1",
+		ResponseStatus: 200,
+		ResponseBody:   []byte(`{}`),
+	}
+
+	mock.ExpectExec("UPDATE idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, r.UpdateIdempotencyKey(context.Background(), idempotencyKey))
+	assert.ErrorIs(t, r.UpdateIdempotencyKey(context.Background(), idempotencyKey), domain.ErrConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_ClaimEvents(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	createdAt := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "type", "payload", "attempts", "last_error", "created_at", "next_attempt_at", "published_at"}).
+		AddRow(2, domain.EventTypeWithdrawal, []byte(`{"user_id":1}`), 1, "timeout", createdAt, createdAt, nil).
+		AddRow(1, domain.EventTypeDeposit, []byte(`{"user_id":1}`), 0, nil, createdAt, createdAt, nil)
+	mock.ExpectQuery("UPDATE outbox SET next_attempt_at (.+) FOR UPDATE SKIP LOCKED\\) RETURNING").
+		WithArgs(10, float64(30)).
+		WillReturnRows(rows)
+
+	events, err := r.ClaimEvents(context.Background(), 10, 30*time.Second)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(1), events[0].ID)
+	assert.Equal(t, int64(2), events[1].ID)
+	assert.Equal(t, "timeout", *events[1].LastError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_GetWebhook(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	createdAt := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "client_id", "url", "event_types", "user_id", "secret", "active", "created_at", "updated_at"}).
+		AddRow(1, "shop", "https://merchant.example/hooks", []byte("{balance.deposit,balance.transfer}"), nil, "secret", true, createdAt, createdAt)
+	mock.ExpectQuery("SELECT (.+) FROM webhooks WHERE id = \\$1 AND client_id = \\$2").WithArgs(1, "shop").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT (.+) FROM webhooks WHERE id = \\$1 AND client_id = \\$2").WithArgs(1, "other").WillReturnError(sql.ErrNoRows)
+
+	webhook, err := r.GetWebhook(context.Background(), "shop", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Webhook{
+		ID:         1,
+		ClientID:   "shop",
+		URL:        "https://merchant.example/hooks",
+		EventTypes: []string{domain.EventTypeDeposit, domain.EventTypeTransfer},
+		Secret:     "secret",
+		Active:     true,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	}, webhook)
+
+	webhook, err = r.GetWebhook(context.Background(), "other", 1)
+	assert.NoError(t, err)
+	assert.Nil(t, webhook)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_DeleteWebhook(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	mock.ExpectExec("DELETE FROM webhooks WHERE id = \\$1 AND client_id = \\$2").WithArgs(1, "shop").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM webhooks WHERE id = \\$1 AND client_id = \\$2").WithArgs(1, "other").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, r.DeleteWebhook(context.Background(), "shop", 1))
+	assert.ErrorIs(t, r.DeleteWebhook(context.Background(), "other", 1), domain.ErrWebhookNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CreateWebhookDeliveries(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	createdAt := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	event := domain.Event{
+		ID:        1,
+		Type:      domain.EventTypeTransfer,
+		Payload:   []byte(`{"from_user_id":2,"to_user_id":3}`),
+		CreatedAt: createdAt,
+	}
+
+	mock.ExpectExec("INSERT INTO webhook_deliveries (.+) SELECT (.+) FROM webhooks (.+) ON CONFLICT \\(webhook_id, event_id\\) DO NOTHING").
+		WithArgs(event.ID, event.Type, []byte(`{"id":1,"type":"balance.transfer","payload":{"from_user_id":2,"to_user_id":3},"created_at":"2022-04-11T12:00:00Z"}`), "{2,3}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.NoError(t, r.CreateWebhookDeliveries(context.Background(), event, []int{2, 3}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_RecordWebhookAttempt(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	now := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	statusCode := 500
+	message := "webhook responded with status 500"
+	attempt := &domain.WebhookAttempt{
+		DeliveryID: 7,
+		Attempt:    3,
+		StatusCode: &statusCode,
+		Error:      &message,
+		DurationMS: 12,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO webhook_attempts (.+) RETURNING id, created_at").
+		WithArgs(attempt.DeliveryID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.DurationMS).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(11, now))
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = \\$1, attempts = \\$2").
+		WithArgs(domain.WebhookDeliveryStatusDead, attempt.Attempt, attempt.Error, now, attempt.DeliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, r.RecordWebhookAttempt(context.Background(), attempt, domain.WebhookDeliveryStatusDead, now))
+	assert.Equal(t, int64(11), attempt.ID)
+	assert.Equal(t, domain.WebhookDeliveryStatusDead, attempt.DeliveryStatus)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_UpdateRateLimitBucket(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	updatedAt := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	now := updatedAt.Add(2 * time.Second)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO rate_limit_buckets (.+) ON CONFLICT \\(key\\) DO NOTHING").
+		WithArgs("user:1:write").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT key, tokens, updated_at, now\\(\\) FROM rate_limit_buckets WHERE key = \\$1 FOR UPDATE").
+		WithArgs("user:1:write").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "tokens", "updated_at", "now"}).AddRow("user:1:write", 0.5, updatedAt, now))
+	mock.ExpectExec("UPDATE rate_limit_buckets SET tokens = \\$1, updated_at = \\$2 WHERE key = \\$3").
+		WithArgs(1.5, now, "user:1:write").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = r.UpdateRateLimitBucket(context.Background(), "user:1:write", func(bucket *domain.RateLimitBucket, dbNow time.Time) {
+		assert.Equal(t, &domain.RateLimitBucket{Key: "user:1:write", Tokens: 0.5, UpdatedAt: updatedAt}, bucket)
+		bucket.Tokens = 1.5
+		bucket.UpdatedAt = dbNow
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetSpendingLimits(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+	db := sqlx.NewDb(mockDB, "sqlmock")
+
+	r := NewRepository(db)
+
+	daily := domain.NewMoney(100000, domain.DefaultCurrency)
+
+	mock.ExpectExec("INSERT INTO spending_limits (.+) ON CONFLICT \\(user_id\\) DO UPDATE").
+		WithArgs(1, int64(100000), nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, r.SetSpendingLimits(context.Background(), 1, domain.SpendingLimits{Daily: &daily}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryName(t *testing.T) {
+	// Every query constant must have a name, or its spans are named after
+	// the SQL verb alone.
+	file, err := parser.ParseFile(token.NewFileSet(), "repository.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parsing repository.go: %s", err)
+	}
+
+	named := map[string]bool{}
+	for _, name := range queryNames {
+		named[name] = true
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, ident := range spec.(*ast.ValueSpec).Names {
+				if strings.HasPrefix(ident.Name, "Query") {
+					assert.True(t, named[ident.Name], "no name for %s", ident.Name)
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, "QueryGetUser", QueryName(QueryGetUser))
+	assert.Equal(t, "QueryListTransactions", QueryName(fmt.Sprintf(QueryListTransactions, "amount", "ASC")))
+	assert.Equal(t, "", QueryName("SELECT 1"))
+}
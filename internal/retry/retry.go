@@ -0,0 +1,73 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// PollConfig controls a background job that claims due items of a queue
+// table in batches: every Interval it leases up to BatchSize items for Lease,
+// and an item that fails is retried with exponential backoff from Backoff up
+// to MaxBackoff.
+type PollConfig struct {
+	Interval   time.Duration
+	BatchSize  int
+	Lease      time.Duration
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// WithDefaults returns the config with settings that are not positive taken
+// from defaults.
+func (c PollConfig) WithDefaults(defaults PollConfig) PollConfig {
+	if c.Interval <= 0 {
+		c.Interval = defaults.Interval
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaults.BatchSize
+	}
+	if c.Lease <= 0 {
+		c.Lease = defaults.Lease
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = defaults.Backoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaults.MaxBackoff
+	}
+	return c
+}
+
+// Delay returns the delay before the next attempt of an item that has already
+// failed the given number of times.
+func (c PollConfig) Delay(failures int) time.Duration {
+	return Delay(c.Backoff, c.MaxBackoff, failures)
+}
+
+// Delay returns initial doubled once for every failure, but not more than max.
+func Delay(initial, max time.Duration, failures int) time.Duration {
+	delay := initial
+	for i := 0; i < failures; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
+
+// Poll calls poll right away and then every interval until ctx is cancelled.
+func Poll(ctx context.Context, interval time.Duration, poll func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
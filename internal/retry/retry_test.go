@@ -0,0 +1,38 @@
+package retry
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPollConfig_WithDefaults(t *testing.T) {
+	defaults := PollConfig{Interval: time.Second, BatchSize: 100, Lease: time.Minute, Backoff: time.Second, MaxBackoff: time.Hour}
+
+	config := PollConfig{BatchSize: 10, Backoff: -time.Second}.WithDefaults(defaults)
+	assert.Equal(t, PollConfig{Interval: time.Second, BatchSize: 10, Lease: time.Minute, Backoff: time.Second, MaxBackoff: time.Hour}, config)
+}
+
+func TestDelay(t *testing.T) {
+	config := PollConfig{Backoff: time.Second, MaxBackoff: 10 * time.Second}
+
+	assert.Equal(t, time.Second, config.Delay(0))
+	assert.Equal(t, 2*time.Second, config.Delay(1))
+	assert.Equal(t, 8*time.Second, config.Delay(3))
+	assert.Equal(t, 10*time.Second, config.Delay(4))
+	assert.Equal(t, 10*time.Second, config.Delay(100))
+}
+
+func TestPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	polls := 0
+	Poll(ctx, time.Millisecond, func(context.Context) {
+		polls++
+		if polls == 3 {
+			cancel()
+		}
+	})
+	assert.Equal(t, 3, polls)
+}
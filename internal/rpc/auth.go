@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/lov3allmy/avito-test-go/internal/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+const metadataAPIKey = "x-api-key"
+
+// methodScopes lists the scope each method needs. Methods missing here are
+// refused to everyone.
+var methodScopes = map[string]string{
+	pb.BalanceService_GetBalance_FullMethodName:       domain.ScopeBalanceRead,
+	pb.BalanceService_Deposit_FullMethodName:          domain.ScopeBalanceWrite,
+	pb.BalanceService_Withdraw_FullMethodName:         domain.ScopeBalanceWrite,
+	pb.BalanceService_Transfer_FullMethodName:         domain.ScopeTransfer,
+	pb.BalanceService_ListTransactions_FullMethodName: domain.ScopeBalanceRead,
+}
+
+type clientKey struct{}
+
+// NewAuthInterceptor authenticates calls by "x-api-key" or
+// "authorization: Bearer <token>" metadata, the same credentials the REST API
+// takes, and checks the scope of the method. The client is put into the
+// context of the call and its id into the records logged for it.
+func NewAuthInterceptor(authenticator domain.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		client, err := authenticate(ctx, authenticator)
+		if err != nil {
+			return nil, err
+		}
+
+		scope, ok := methodScopes[info.FullMethod]
+		if !ok || !client.HasScope(scope) {
+			return nil, domain.ErrForbidden.WithMessage(`the "` + scope + `" scope is required`)
+		}
+
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("client_id", client.ID))
+		return handler(context.WithValue(ctx, clientKey{}, client), request)
+	}
+}
+
+func authenticate(ctx context.Context, authenticator domain.Authenticator) (*domain.Client, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if keys := md.Get(metadataAPIKey); len(keys) > 0 && keys[0] != "" {
+		return authenticator.AuthenticateAPIKey(ctx, keys[0])
+	}
+
+	authorization := md.Get("authorization")
+	if len(authorization) == 0 {
+		return nil, domain.ErrUnauthorized.WithMessage(`"x-api-key" or "authorization" metadata is required`)
+	}
+	parts := strings.SplitN(authorization[0], " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+		return nil, domain.ErrUnauthorized.WithMessage(`"authorization" metadata must be "Bearer <token>"`)
+	}
+	return authenticator.AuthenticateToken(ctx, parts[1])
+}
+
+// clientID returns the id of the client making the call.
+func clientID(ctx context.Context) string {
+	if client, ok := ctx.Value(clientKey{}).(*domain.Client); ok {
+		return client.ID
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errorStatusCodes = map[string]codes.Code{
+	domain.ErrorCodeInvalidInput:        codes.InvalidArgument,
+	domain.ErrorCodeUnauthorized:        codes.Unauthenticated,
+	domain.ErrorCodeForbidden:           codes.PermissionDenied,
+	domain.ErrorCodeUnknownCurrency:     codes.InvalidArgument,
+	domain.ErrorCodeNotFound:            codes.NotFound,
+	domain.ErrorCodeUserNotFound:        codes.NotFound,
+	domain.ErrorCodeReservationNotFound: codes.NotFound,
+	domain.ErrorCodeWebhookNotFound:     codes.NotFound,
+	domain.ErrorCodeDuplicate:           codes.AlreadyExists,
+	domain.ErrorCodeConflict:            codes.Aborted,
+	domain.ErrorCodeReservationMismatch: codes.FailedPrecondition,
+	domain.ErrorCodeInsufficientFunds:   codes.FailedPrecondition,
+	domain.ErrorCodeSpendingLimit:       codes.FailedPrecondition,
+	domain.ErrorCodeRateLimited:         codes.ResourceExhausted,
+	domain.ErrorCodeInternal:            codes.Internal,
+}
+
+// ErrorInterceptor turns errors returned by the handlers into gRPC statuses.
+// Domain errors keep their message, anything else is logged and reported as
+// a generic internal error, the same way the REST API does.
+func ErrorInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	response, err := handler(ctx, request)
+	if err == nil {
+		return response, nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return nil, err
+	}
+
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		domainErr = domain.ErrInternal
+	}
+
+	code, ok := errorStatusCodes[domainErr.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	if code == codes.Internal {
+		logging.FromContext(ctx).Error("call failed", "method", info.FullMethod, "error", err)
+	}
+
+	return nil, status.Error(code, domainErr.Message)
+}
@@ -0,0 +1,53 @@
+package rpc
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+const metadataRequestID = "x-request-id"
+
+// RequestIDInterceptor takes the request id from "x-request-id" metadata or
+// generates one, sends it back in the response header and logs the call with
+// it once it is served, the same way the REST API does.
+func RequestIDInterceptor(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	started := time.Now()
+
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(metadataRequestID); len(ids) > 0 && logging.ValidRequestID(ids[0]) {
+			requestID = ids[0]
+		}
+	}
+	if requestID == "" {
+		generated, err := logging.NewRequestID()
+		if err != nil {
+			return nil, err
+		}
+		requestID = generated
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, requestID))
+
+	logger := logging.FromContext(ctx).With("request_id", requestID)
+	response, err := handler(logging.NewContext(ctx, logger), request)
+
+	code := status.Code(err)
+	level := logging.LevelInfo
+	switch {
+	case code == codes.Internal || code == codes.Unknown:
+		level = logging.LevelError
+	case code != codes.OK:
+		level = logging.LevelWarn
+	}
+	logger.Log(level, "call served",
+		"method", info.FullMethod,
+		"code", code.String(),
+		"duration_ms", float64(time.Since(started).Microseconds())/1000,
+	)
+	return response, err
+}
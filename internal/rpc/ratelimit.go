@@ -0,0 +1,84 @@
+package rpc
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"math"
+	"strconv"
+)
+
+const metadataRetryAfter = "retry-after"
+
+// methodRateLimits lists the rate limit class of each method: calls that move
+// money are writes, the rest are reads.
+var methodRateLimits = map[string]string{
+	pb.BalanceService_GetBalance_FullMethodName:       domain.RateLimitRead,
+	pb.BalanceService_Deposit_FullMethodName:          domain.RateLimitWrite,
+	pb.BalanceService_Withdraw_FullMethodName:         domain.RateLimitWrite,
+	pb.BalanceService_Transfer_FullMethodName:         domain.RateLimitWrite,
+	pb.BalanceService_ListTransactions_FullMethodName: domain.RateLimitRead,
+}
+
+// NewRateLimitInterceptor counts calls against the limits of the
+// authenticated client and of the user the request is about, the same limits
+// the REST API enforces. Calls over a limit fail with ResourceExhausted and a
+// "retry-after" header. It must run after the auth interceptor; a nil limiter
+// lets every call through.
+func NewRateLimitInterceptor(limiter domain.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if limiter == nil {
+			return handler(ctx, request)
+		}
+		class, ok := methodRateLimits[info.FullMethod]
+		if !ok {
+			class = domain.RateLimitWrite
+		}
+
+		if id := clientID(ctx); id != "" {
+			result, err := limiter.AllowClient(ctx, id, class)
+			if err != nil {
+				return nil, err
+			}
+			if err := enforceRateLimit(ctx, result); err != nil {
+				return nil, err
+			}
+		}
+
+		if id, ok := userID(request); ok {
+			result, err := limiter.AllowUser(ctx, id, class)
+			if err != nil {
+				return nil, err
+			}
+			if err := enforceRateLimit(ctx, result); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, request)
+	}
+}
+
+// enforceRateLimit fails the call if the limit is exhausted. A limit of zero
+// is not checked.
+func enforceRateLimit(ctx context.Context, result *domain.RateLimitResult) error {
+	if result.Limit == 0 || result.Allowed {
+		return nil
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds())))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataRetryAfter, retryAfter))
+	return domain.ErrRateLimited.WithMessage("rate limit exceeded, retry in " + retryAfter + "s")
+}
+
+// userID returns the user whose balance the request reads or changes.
+func userID(request interface{}) (int, bool) {
+	switch request := request.(type) {
+	case *pb.TransferRequest:
+		return int(request.GetFromUserId()), true
+	case interface{ GetUserId() int64 }:
+		return int(request.GetUserId()), true
+	}
+	return 0, false
+}
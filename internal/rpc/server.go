@@ -0,0 +1,172 @@
+package rpc
+
+//go:generate protoc -I ../../api/proto --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative balance.proto
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"strings"
+)
+
+type Server struct {
+	pb.UnimplementedBalanceServiceServer
+
+	service domain.Service
+}
+
+func NewServer(service domain.Service) *Server {
+	return &Server{
+		service: service,
+	}
+}
+
+// NewGRPCServer returns a gRPC server with the balance service registered,
+// calls traced and logged with a request id, authenticated, rate limited and
+// domain errors translated into gRPC status codes.
+func NewGRPCServer(service domain.Service, authenticator domain.Authenticator, limiter domain.RateLimiter) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		TracingInterceptor,
+		RequestIDInterceptor,
+		ErrorInterceptor,
+		NewAuthInterceptor(authenticator),
+		NewRateLimitInterceptor(limiter),
+	))
+	pb.RegisterBalanceServiceServer(server, NewServer(service))
+	return server
+}
+
+func (s *Server) GetBalance(ctx context.Context, request *pb.GetBalanceRequest) (*pb.GetBalanceResponse, error) {
+	balance, err := s.service.GetBalance(ctx, domain.GetBalanceInput{
+		ID:       int(request.GetUserId()),
+		Currency: request.GetCurrency(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetBalanceResponse{
+		Balance: toProtoMoney(balance),
+	}, nil
+}
+
+func (s *Server) Deposit(ctx context.Context, request *pb.BalanceOperationRequest) (*pb.BalanceOperationResponse, error) {
+	input, err := fromBalanceOperationRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.service.Deposit(ctx, input); err != nil {
+		return nil, err
+	}
+	return &pb.BalanceOperationResponse{}, nil
+}
+
+func (s *Server) Withdraw(ctx context.Context, request *pb.BalanceOperationRequest) (*pb.BalanceOperationResponse, error) {
+	input, err := fromBalanceOperationRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.service.Withdraw(ctx, input); err != nil {
+		return nil, err
+	}
+	return &pb.BalanceOperationResponse{}, nil
+}
+
+func (s *Server) Transfer(ctx context.Context, request *pb.TransferRequest) (*pb.TransferResponse, error) {
+	amount, err := fromProtoMoney(request.GetAmount())
+	if err != nil {
+		return nil, err
+	}
+	err = s.service.Transfer(ctx, domain.P2PInput{
+		FromUserID: int(request.GetFromUserId()),
+		ToUserID:   int(request.GetToUserId()),
+		Amount:     amount,
+		Comment:    request.GetComment(),
+		ClientID:   clientID(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.TransferResponse{}, nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, request *pb.ListTransactionsRequest) (*pb.ListTransactionsResponse, error) {
+	transactions, err := s.service.ListTransactions(ctx, domain.TransactionFilter{
+		UserID: int(request.GetUserId()),
+		Limit:  int(request.GetLimit()),
+		Offset: int(request.GetOffset()),
+		SortBy: request.GetSortBy(),
+		Order:  request.GetOrder(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.ListTransactionsResponse{
+		Transactions: make([]*pb.Transaction, 0, len(transactions)),
+	}
+	for _, transaction := range transactions {
+		response.Transactions = append(response.Transactions, toProtoTransaction(transaction))
+	}
+	return response, nil
+}
+
+func fromBalanceOperationRequest(ctx context.Context, request *pb.BalanceOperationRequest) (domain.BalanceOperationInput, error) {
+	amount, err := fromProtoMoney(request.GetAmount())
+	if err != nil {
+		return domain.BalanceOperationInput{}, err
+	}
+	return domain.BalanceOperationInput{
+		UserID:    int(request.GetUserId()),
+		Amount:    amount,
+		ServiceID: int(request.GetServiceId()),
+		OrderID:   int(request.GetOrderId()),
+		Comment:   request.GetComment(),
+		ClientID:  clientID(ctx),
+	}, nil
+}
+
+// fromProtoMoney reads an amount of money to move. Balances are kept in the
+// default currency only, so any other currency is rejected instead of being
+// booked as the same number of kopecks.
+func fromProtoMoney(money *pb.Money) (domain.Money, error) {
+	currency := strings.ToUpper(money.GetCurrency())
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+	if currency != domain.DefaultCurrency {
+		return domain.Money{}, domain.ErrInvalidInput.WithMessage("amount must be in " + domain.DefaultCurrency)
+	}
+	return domain.NewMoney(money.GetMinorUnits(), currency), nil
+}
+
+func toProtoMoney(money domain.Money) *pb.Money {
+	return &pb.Money{
+		MinorUnits: money.MinorUnits,
+		Currency:   money.Currency,
+	}
+}
+
+func toProtoTransaction(transaction domain.Transaction) *pb.Transaction {
+	return &pb.Transaction{
+		Id:             int64(transaction.ID),
+		UserId:         int64(transaction.UserID),
+		Type:           transaction.Type,
+		Amount:         toProtoMoney(transaction.Amount),
+		CounterpartyId: toProtoID(transaction.CounterpartyID),
+		ServiceId:      toProtoID(transaction.ServiceID),
+		OrderId:        toProtoID(transaction.OrderID),
+		Comment:        transaction.Comment,
+		CreatedAt:      timestamppb.New(transaction.CreatedAt),
+	}
+}
+
+func toProtoID(id *int) *int64 {
+	if id == nil {
+		return nil
+	}
+	protoID := int64(*id)
+	return &protoID
+}
@@ -0,0 +1,461 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/lov3allmy/avito-test-go/internal/ratelimit"
+	"github.com/lov3allmy/avito-test-go/internal/rpc/pb"
+	"github.com/lov3allmy/avito-test-go/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rubles(amount int64) domain.Money {
+	return domain.NewMoney(amount*100, domain.DefaultCurrency)
+}
+
+const (
+	testAPIKey     = "ak_test"
+	readOnlyAPIKey = "ak_read_only"
+)
+
+// testAuthenticator knows two API keys: testAPIKey with every scope and
+// readOnlyAPIKey with balance:read only.
+type testAuthenticator struct{}
+
+func (testAuthenticator) AuthenticateAPIKey(_ context.Context, key string) (*domain.Client, error) {
+	switch key {
+	case testAPIKey:
+		return &domain.Client{ID: "shop", Scopes: domain.Scopes}, nil
+	case readOnlyAPIKey:
+		return &domain.Client{ID: "reports", Scopes: []string{domain.ScopeBalanceRead}}, nil
+	default:
+		return nil, domain.ErrUnauthorized
+	}
+}
+
+func (testAuthenticator) AuthenticateToken(context.Context, string) (*domain.Client, error) {
+	return nil, domain.ErrUnauthorized
+}
+
+// newTestClient serves the service over an in-memory connection, calling it
+// with testAPIKey.
+func newTestClient(t *testing.T, service domain.Service) pb.BalanceServiceClient {
+	return newTestClientWithKey(t, service, testAPIKey)
+}
+
+func newTestClientWithKey(t *testing.T, service domain.Service, apiKey string) pb.BalanceServiceClient {
+	return dialTestServer(t, NewGRPCServer(service, testAuthenticator{}, nil), apiKey)
+}
+
+func dialTestServer(t *testing.T, server *grpc.Server, apiKey string) pb.BalanceServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if apiKey != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, metadataAPIKey, apiKey)
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return pb.NewBalanceServiceClient(conn)
+}
+
+func TestServer_GetBalance(t *testing.T) {
+	type mockBehavior func(s *mock_domain.MockService)
+
+	tests := []struct {
+		name            string
+		request         *pb.GetBalanceRequest
+		mockBehavior    mockBehavior
+		expectedBalance *pb.Money
+		expectedCode    codes.Code
+	}{
+		{
+			name:    "OK",
+			request: &pb.GetBalanceRequest{UserId: 1, Currency: "USD"},
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().GetBalance(gomock.Any(), domain.GetBalanceInput{ID: 1, Currency: "USD"}).Return(domain.NewMoney(1250, "USD"), nil)
+			},
+			expectedBalance: &pb.Money{MinorUnits: 1250, Currency: "USD"},
+			expectedCode:    codes.OK,
+		},
+		{
+			name:    "User not found",
+			request: &pb.GetBalanceRequest{UserId: 1},
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().GetBalance(gomock.Any(), domain.GetBalanceInput{ID: 1}).Return(domain.Money{}, domain.ErrUserNotFound)
+			},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service)
+
+			response, err := newTestClient(t, service).GetBalance(context.Background(), test.request)
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			if test.expectedBalance != nil {
+				assert.Equal(t, test.expectedBalance.MinorUnits, response.GetBalance().GetMinorUnits())
+				assert.Equal(t, test.expectedBalance.Currency, response.GetBalance().GetCurrency())
+			}
+		})
+	}
+}
+
+func TestServer_Deposit(t *testing.T) {
+	type mockBehavior func(s *mock_domain.MockService)
+
+	input := domain.BalanceOperationInput{UserID: 1, Amount: rubles(10), ClientID: "shop"}
+
+	tests := []struct {
+		name            string
+		amount          *pb.Money
+		mockBehavior    mockBehavior
+		expectedCode    codes.Code
+		expectedMessage string
+	}{
+		{
+			name:   "OK",
+			amount: &pb.Money{MinorUnits: 1000},
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().Deposit(gomock.Any(), input).Return(nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:   "Default currency",
+			amount: &pb.Money{MinorUnits: 1000, Currency: "rub"},
+			mockBehavior: func(s *mock_domain.MockService) {
+				s.EXPECT().Deposit(gomock.Any(), input).Return(nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:            "Foreign currency",
+			amount:          &pb.Money{MinorUnits: 1000, Currency: "USD"},
+			mockBehavior:    func(s *mock_domain.MockService) {},
+			expectedCode:    codes.InvalidArgument,
+			expectedMessage: "amount must be in RUB",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service)
+
+			_, err := newTestClient(t, service).Deposit(context.Background(), &pb.BalanceOperationRequest{
+				UserId: 1,
+				Amount: test.amount,
+			})
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			assert.Equal(t, test.expectedMessage, status.Convert(err).Message())
+		})
+	}
+}
+
+func TestServer_Withdraw(t *testing.T) {
+	type mockBehavior func(s *mock_domain.MockService, input domain.BalanceOperationInput)
+
+	tests := []struct {
+		name            string
+		mockBehavior    mockBehavior
+		expectedCode    codes.Code
+		expectedMessage string
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(s *mock_domain.MockService, input domain.BalanceOperationInput) {
+				s.EXPECT().Withdraw(gomock.Any(), input).Return(nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name: "Not enough balance",
+			mockBehavior: func(s *mock_domain.MockService, input domain.BalanceOperationInput) {
+				s.EXPECT().Withdraw(gomock.Any(), input).Return(domain.ErrInsufficientFunds)
+			},
+			expectedCode:    codes.FailedPrecondition,
+			expectedMessage: "not enough balance",
+		},
+		{
+			name: "Internal error",
+			mockBehavior: func(s *mock_domain.MockService, input domain.BalanceOperationInput) {
+				s.EXPECT().Withdraw(gomock.Any(), input).Return(errors.New("pq: connection refused"))
+			},
+			expectedCode:    codes.Internal,
+			expectedMessage: "internal server error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			service := mock_domain.NewMockService(c)
+			test.mockBehavior(service, domain.BalanceOperationInput{
+				UserID:    1,
+				Amount:    rubles(10),
+				ServiceID: 2,
+				OrderID:   3,
+				Comment:   "order",
+				ClientID:  "shop",
+			})
+
+			_, err := newTestClient(t, service).Withdraw(context.Background(), &pb.BalanceOperationRequest{
+				UserId:    1,
+				Amount:    &pb.Money{MinorUnits: 1000},
+				ServiceId: 2,
+				OrderId:   3,
+				Comment:   "order",
+			})
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			assert.Equal(t, test.expectedMessage, status.Convert(err).Message())
+		})
+	}
+}
+
+func TestServer_Transfer(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	service := mock_domain.NewMockService(c)
+	service.EXPECT().Transfer(gomock.Any(), domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(10), ClientID: "shop"}).Return(domain.ErrUserNotFound.WithMessage("there is no user with id 2"))
+
+	_, err := newTestClient(t, service).Transfer(context.Background(), &pb.TransferRequest{
+		FromUserId: 1,
+		ToUserId:   2,
+		Amount:     &pb.Money{MinorUnits: 1000, Currency: domain.DefaultCurrency},
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "there is no user with id 2", status.Convert(err).Message())
+}
+
+func TestServer_TransferForeignCurrency(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	// The service is not called: 1000 cents must not be booked as 10 rubles.
+	service := mock_domain.NewMockService(c)
+
+	_, err := newTestClient(t, service).Transfer(context.Background(), &pb.TransferRequest{
+		FromUserId: 1,
+		ToUserId:   2,
+		Amount:     &pb.Money{MinorUnits: 1000, Currency: "USD"},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "amount must be in RUB", status.Convert(err).Message())
+}
+
+func TestServer_LongComment(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	// The comment is checked by the service, so the repository is not called.
+	client := newTestClient(t, service.NewService(mock_domain.NewMockRepository(c), nil, domain.SpendingLimits{}))
+	comment := strings.Repeat("ы", domain.MaxCommentLength+1)
+
+	_, err := client.Deposit(context.Background(), &pb.BalanceOperationRequest{
+		UserId:  1,
+		Amount:  &pb.Money{MinorUnits: 1000},
+		Comment: comment,
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "comment must be at most 255 characters", status.Convert(err).Message())
+
+	_, err = client.Transfer(context.Background(), &pb.TransferRequest{
+		FromUserId: 1,
+		ToUserId:   2,
+		Amount:     &pb.Money{MinorUnits: 1000},
+		Comment:    comment,
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_ListTransactions(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	counterpartyID := 2
+	createdAt := time.Date(2022, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	service := mock_domain.NewMockService(c)
+	service.EXPECT().ListTransactions(gomock.Any(), domain.TransactionFilter{UserID: 1, Limit: 10, SortBy: domain.TransactionSortByAmount}).Return([]domain.Transaction{
+		{ID: 5, UserID: 1, Type: domain.TransactionTypeP2POut, Amount: rubles(10), CounterpartyID: &counterpartyID, CreatedAt: createdAt},
+	}, nil)
+
+	response, err := newTestClient(t, service).ListTransactions(context.Background(), &pb.ListTransactionsRequest{
+		UserId: 1,
+		Limit:  10,
+		SortBy: domain.TransactionSortByAmount,
+	})
+	require.NoError(t, err)
+	require.Len(t, response.GetTransactions(), 1)
+
+	transaction := response.GetTransactions()[0]
+	assert.Equal(t, int64(5), transaction.GetId())
+	assert.Equal(t, domain.TransactionTypeP2POut, transaction.GetType())
+	assert.Equal(t, int64(1000), transaction.GetAmount().GetMinorUnits())
+	assert.Equal(t, int64(2), transaction.GetCounterpartyId())
+	assert.Nil(t, transaction.ServiceId)
+	assert.Equal(t, createdAt, transaction.GetCreatedAt().AsTime())
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	tests := []struct {
+		name         string
+		apiKey       string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "No credentials",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Unknown key",
+			apiKey:       "ak_unknown",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Missing scope",
+			apiKey:       readOnlyAPIKey,
+			expectedCode: codes.PermissionDenied,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			_, err := newTestClientWithKey(t, mock_domain.NewMockService(c), test.apiKey).Deposit(context.Background(), &pb.BalanceOperationRequest{
+				UserId: 1,
+				Amount: &pb.Money{MinorUnits: 1000},
+			})
+			assert.Equal(t, test.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	service := mock_domain.NewMockService(c)
+	service.EXPECT().Deposit(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	service.EXPECT().GetBalance(gomock.Any(), gomock.Any()).Return(rubles(10), nil)
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Config{
+		ClientWrite: domain.RateLimit{Rate: 0.001, Burst: 3},
+		UserWrite:   domain.RateLimit{Rate: 0.001, Burst: 1},
+	})
+	client := dialTestServer(t, NewGRPCServer(service, testAuthenticator{}, limiter), testAPIKey)
+	deposit := func(userID int64) error {
+		_, err := client.Deposit(context.Background(), &pb.BalanceOperationRequest{
+			UserId: userID,
+			Amount: &pb.Money{MinorUnits: 1000},
+		})
+		return err
+	}
+
+	require.NoError(t, deposit(1))
+
+	var header metadata.MD
+	_, err := client.Deposit(context.Background(), &pb.BalanceOperationRequest{
+		UserId: 1,
+		Amount: &pb.Money{MinorUnits: 1000},
+	}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, header.Get(metadataRetryAfter))
+
+	require.NoError(t, deposit(2))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(deposit(3)), "the client has used up its write limit")
+
+	_, err = client.GetBalance(context.Background(), &pb.GetBalanceRequest{UserId: 1})
+	assert.NoError(t, err, "reads have limits of their own")
+}
+
+func TestServer_RequestID(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	service := mock_domain.NewMockService(c)
+	service.EXPECT().GetBalance(gomock.Any(), gomock.Any()).Return(domain.NewMoney(1250, "RUB"), nil).Times(2)
+	client := newTestClient(t, service)
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), metadataRequestID, "req-42")
+	_, err := client.GetBalance(ctx, &pb.GetBalanceRequest{UserId: 1}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"req-42"}, header.Get(metadataRequestID))
+
+	_, err = client.GetBalance(context.Background(), &pb.GetBalanceRequest{UserId: 1}, grpc.Header(&header))
+	require.NoError(t, err)
+	if assert.Len(t, header.Get(metadataRequestID), 1) {
+		assert.Len(t, header.Get(metadataRequestID)[0], 32)
+	}
+}
+
+func TestServer_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	service := mock_domain.NewMockService(c)
+	service.EXPECT().GetBalance(gomock.Any(), gomock.Any()).Return(domain.NewMoney(1250, "RUB"), nil)
+	client := newTestClient(t, service)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-4bf92f3577b34da6a3ce929e0e4736ce-00f067aa0ba902b7-01")
+	_, err := client.GetBalance(ctx, &pb.GetBalanceRequest{UserId: 1})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "balance.v1.BalanceService/GetBalance", spans[0].Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929e0e4736ce", spans[0].SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	}
+}
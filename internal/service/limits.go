@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"time"
+)
+
+// outgoingOperation is money about to leave a user balance.
+type outgoingOperation struct {
+	userID   int
+	amount   domain.Money
+	transfer bool
+}
+
+// spendingRule checks an outgoing operation against the limits that apply to
+// the user and returns domain.ErrSpendingLimit when the operation must be
+// rejected.
+type spendingRule func(s *service, operation outgoingOperation, limits domain.SpendingLimits, outgoingTotal domain.OutgoingTotal) error
+
+// spendingRules are evaluated in order, cheap checks first.
+var spendingRules = []spendingRule{
+	maxTransferRule,
+	dailyLimitRule,
+	monthlyLimitRule,
+}
+
+// spendingCheck returns the check running all spending rules for the
+// operation. The repository runs it inside the transaction of the operation
+// with the user row locked, so concurrent operations of one user cannot
+// together go over a limit.
+func (s *service) spendingCheck(ctx context.Context, operation outgoingOperation) (domain.SpendingCheck, error) {
+	limits, err := s.effectiveSpendingLimits(ctx, operation.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, outgoingTotal domain.OutgoingTotal) error {
+		for _, rule := range spendingRules {
+			if err := rule(s, operation, limits, outgoingTotal); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// effectiveSpendingLimits returns the limits set for the user, falling back
+// to the global ones.
+func (s *service) effectiveSpendingLimits(ctx context.Context, userID int) (domain.SpendingLimits, error) {
+	override, err := s.repository.GetSpendingLimits(ctx, userID)
+	if err != nil {
+		return domain.SpendingLimits{}, err
+	}
+	if override == nil {
+		return s.spendingLimits, nil
+	}
+	return mergeSpendingLimits(*override, s.spendingLimits), nil
+}
+
+func mergeSpendingLimits(override, defaults domain.SpendingLimits) domain.SpendingLimits {
+	if override.Daily == nil {
+		override.Daily = defaults.Daily
+	}
+	if override.Monthly == nil {
+		override.Monthly = defaults.Monthly
+	}
+	if override.MaxTransfer == nil {
+		override.MaxTransfer = defaults.MaxTransfer
+	}
+	return override
+}
+
+func maxTransferRule(s *service, operation outgoingOperation, limits domain.SpendingLimits, outgoingTotal domain.OutgoingTotal) error {
+	if !operation.transfer || limits.MaxTransfer == nil || !limits.MaxTransfer.LessThan(operation.amount) {
+		return nil
+	}
+	return domain.ErrSpendingLimit.WithMessage("transfer amount is over the limit").WithDetails(domain.SpendingLimitViolation{
+		Limit:  domain.SpendingLimitMaxTransfer,
+		Max:    *limits.MaxTransfer,
+		Amount: operation.amount,
+	})
+}
+
+func dailyLimitRule(s *service, operation outgoingOperation, limits domain.SpendingLimits, outgoingTotal domain.OutgoingTotal) error {
+	now := s.now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return checkPeriodLimit(operation, domain.SpendingLimitDaily, limits.Daily, outgoingTotal, since)
+}
+
+func monthlyLimitRule(s *service, operation outgoingOperation, limits domain.SpendingLimits, outgoingTotal domain.OutgoingTotal) error {
+	now := s.now().UTC()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return checkPeriodLimit(operation, domain.SpendingLimitMonthly, limits.Monthly, outgoingTotal, since)
+}
+
+// checkPeriodLimit rejects the operation if together with everything the
+// user has spent since the given time it would go over max.
+func checkPeriodLimit(operation outgoingOperation, limit string, max *domain.Money, outgoingTotal domain.OutgoingTotal, since time.Time) error {
+	if max == nil {
+		return nil
+	}
+
+	spent, err := outgoingTotal(since)
+	if err != nil {
+		return err
+	}
+	total, err := spent.Add(operation.amount)
+	if err != nil {
+		return domain.ErrInvalidInput.Wrap(err)
+	}
+	if !max.LessThan(total) {
+		return nil
+	}
+
+	return domain.ErrSpendingLimit.WithMessage(limit + " spending limit is exceeded").WithDetails(domain.SpendingLimitViolation{
+		Limit:  limit,
+		Max:    *max,
+		Spent:  &spent,
+		Amount: operation.amount,
+	})
+}
+
+func (s *service) GetSpendingLimits(ctx context.Context, userID int) (*domain.UserSpendingLimits, error) {
+	if userID <= 0 {
+		return nil, domain.ErrInvalidInput.WithMessage("user id must be positive")
+	}
+
+	override, err := s.repository.GetSpendingLimits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if override == nil {
+		override = &domain.SpendingLimits{}
+	}
+
+	return &domain.UserSpendingLimits{
+		UserID:    userID,
+		Override:  *override,
+		Effective: mergeSpendingLimits(*override, s.spendingLimits),
+	}, nil
+}
+
+// SetSpendingLimits replaces the limits set for the user. Limits left out of
+// the input fall back to the global ones.
+func (s *service) SetSpendingLimits(ctx context.Context, input domain.SpendingLimitsInput) (*domain.UserSpendingLimits, error) {
+	if input.UserID <= 0 {
+		return nil, domain.ErrInvalidInput.WithMessage("user id must be positive")
+	}
+	for _, limit := range []*domain.Money{input.Daily, input.Monthly, input.MaxTransfer} {
+		if limit != nil && limit.MinorUnits <= 0 {
+			return nil, domain.ErrInvalidInput.WithMessage("limits must be positive")
+		}
+	}
+
+	override := domain.SpendingLimits{
+		Daily:       input.Daily,
+		Monthly:     input.Monthly,
+		MaxTransfer: input.MaxTransfer,
+	}
+	if err := s.repository.SetSpendingLimits(ctx, input.UserID, override); err != nil {
+		return nil, err
+	}
+
+	return &domain.UserSpendingLimits{
+		UserID:    input.UserID,
+		Override:  override,
+		Effective: mergeSpendingLimits(override, s.spendingLimits),
+	}, nil
+}
+
+func (s *service) DeleteSpendingLimits(ctx context.Context, userID int) error {
+	return s.repository.DeleteSpendingLimits(ctx, userID)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+)
+
+// Operations are the names money operations are logged and counted under.
+const (
+	OperationDeposit            = "deposit"
+	OperationWithdrawal         = "withdrawal"
+	OperationTransfer           = "transfer"
+	OperationReservation        = "reservation"
+	OperationReservationCommit  = "reservation_commit"
+	OperationReservationRelease = "reservation_release"
+)
+
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	OutcomeFailed   = "failed"
+)
+
+// Outcome classifies the result of a money operation: operations refused
+// with a domain error, e.g. for insufficient funds, are rejected, any other
+// error means the operation failed.
+func Outcome(err error) string {
+	var domainErr *domain.Error
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.As(err, &domainErr) && domainErr.Code != domain.ErrorCodeInternal:
+		return OutcomeRejected
+	default:
+		return OutcomeFailed
+	}
+}
+
+// logOperation records the outcome of a money operation, with the error code
+// for rejected operations.
+func logOperation(ctx context.Context, operation string, err error, args ...interface{}) {
+	logger := logging.FromContext(ctx)
+	fields := append([]interface{}{"operation", operation}, args...)
+
+	switch outcome := Outcome(err); outcome {
+	case OutcomeSuccess:
+		logger.Info("money operation completed", append(fields, "outcome", outcome)...)
+	case OutcomeRejected:
+		var domainErr *domain.Error
+		errors.As(err, &domainErr)
+		logger.Warn("money operation rejected", append(fields, "outcome", outcome, "error_code", domainErr.Code, "error", err)...)
+	default:
+		logger.Error("money operation failed", append(fields, "outcome", outcome, "error", err)...)
+	}
+}
@@ -1,31 +1,396 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type service struct {
-	repository domain.Repository
+	repository     domain.Repository
+	rateProvider   domain.RateProvider
+	spendingLimits domain.SpendingLimits
+	now            func() time.Time
 }
 
-func NewService(repository domain.Repository) domain.Service {
+// NewService creates the service. spendingLimits apply to every user without
+// limits of their own.
+func NewService(repository domain.Repository, rateProvider domain.RateProvider, spendingLimits domain.SpendingLimits) domain.Service {
 	return &service{
-		repository: repository,
+		repository:     repository,
+		rateProvider:   rateProvider,
+		spendingLimits: spendingLimits,
+		now:            time.Now,
 	}
 }
 
-func (s *service) GetUser(userID int) (*domain.User, error) {
-	return s.repository.GetUser(userID)
+func (s *service) GetUser(ctx context.Context, userID int) (*domain.User, error) {
+	return s.repository.GetUser(ctx, userID)
 }
 
-func (s *service) CreateUser(user *domain.User) error {
-	return s.repository.CreateUser(user)
+func (s *service) CreateUser(ctx context.Context, user *domain.User) error {
+	return s.repository.CreateUser(ctx, user)
 }
 
-func (s *service) UpdateUser(userID int, user *domain.User) error {
-	return s.repository.UpdateUser(userID, user)
+func (s *service) UpdateUser(ctx context.Context, userID int, user *domain.User) error {
+	return s.repository.UpdateUser(ctx, userID, user)
 }
 
-func (s *service) MakeP2PTransfer(p2pInput domain.P2PInput) error {
-	return s.repository.MakeP2PTransfer(p2pInput)
+// GetBalance returns the balance of the user, converted into
+// input.Currency when it is set and differs from the balance currency.
+func (s *service) GetBalance(ctx context.Context, input domain.GetBalanceInput) (domain.Money, error) {
+	if input.ID <= 0 {
+		return domain.Money{}, domain.ErrInvalidInput.WithMessage("user id must be positive")
+	}
+
+	user, err := s.repository.GetUser(ctx, input.ID)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	if user == nil {
+		return domain.Money{}, domain.ErrUserNotFound
+	}
+
+	currency := strings.ToUpper(input.Currency)
+	if currency == "" || currency == user.Balance.Currency {
+		return user.Balance, nil
+	}
+	return s.convertBalance(user.Balance, currency)
+}
+
+// Deposit puts money on the user balance. A user who has never had a balance
+// is created on the first deposit. The type of the input is ignored.
+func (s *service) Deposit(ctx context.Context, input domain.BalanceOperationInput) (err error) {
+	defer func() {
+		logOperation(ctx, OperationDeposit, err, "user_id", input.UserID, "amount", input.Amount, "client_id", input.ClientID)
+	}()
+
+	input.Type = domain.TransactionTypeAdd
+	if err := validateBalanceOperation(input); err != nil {
+		return err
+	}
+
+	user, err := s.repository.GetUser(ctx, input.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		// A concurrent deposit may create the user first, which is fine.
+		err := s.repository.CreateUser(ctx, &domain.User{
+			ID:      input.UserID,
+			Balance: domain.NewMoney(0, domain.DefaultCurrency),
+		})
+		if err != nil && !errors.Is(err, domain.ErrDuplicate) {
+			return err
+		}
+	}
+
+	return s.repository.MakeBalanceOperation(ctx, input, nil)
+}
+
+// Withdraw takes money from the user balance. It fails with
+// domain.ErrSpendingLimit if the withdrawal breaks a spending limit and with
+// domain.ErrInsufficientFunds if the balance is less than the amount. The
+// type of the input is ignored.
+func (s *service) Withdraw(ctx context.Context, input domain.BalanceOperationInput) (err error) {
+	defer func() {
+		logOperation(ctx, OperationWithdrawal, err, "user_id", input.UserID, "amount", input.Amount, "client_id", input.ClientID)
+	}()
+
+	input.Type = domain.TransactionTypeSubtract
+	if err := validateBalanceOperation(input); err != nil {
+		return err
+	}
+	check, err := s.spendingCheck(ctx, outgoingOperation{userID: input.UserID, amount: input.Amount})
+	if err != nil {
+		return err
+	}
+
+	return s.repository.MakeBalanceOperation(ctx, input, check)
+}
+
+// Transfer moves money between two existing users. It fails with
+// domain.ErrSpendingLimit if the transfer breaks a spending limit of the
+// sender and with domain.ErrInsufficientFunds if the sender balance is less
+// than the amount.
+func (s *service) Transfer(ctx context.Context, input domain.P2PInput) (err error) {
+	defer func() {
+		logOperation(ctx, OperationTransfer, err, "from_user_id", input.FromUserID, "to_user_id", input.ToUserID, "amount", input.Amount, "client_id", input.ClientID)
+	}()
+
+	if input.FromUserID <= 0 || input.ToUserID <= 0 {
+		return domain.ErrInvalidInput.WithMessage("user ids must be positive")
+	}
+	if input.FromUserID == input.ToUserID {
+		return domain.ErrInvalidInput.WithMessage("cannot transfer money to the same user")
+	}
+	if err := validateAmount(input.Amount); err != nil {
+		return err
+	}
+	if err := validateComment(input.Comment); err != nil {
+		return err
+	}
+	check, err := s.spendingCheck(ctx, outgoingOperation{userID: input.FromUserID, amount: input.Amount, transfer: true})
+	if err != nil {
+		return err
+	}
+
+	return s.repository.MakeP2PTransfer(ctx, input, check)
+}
+
+func (s *service) CreateTransaction(ctx context.Context, transaction *domain.Transaction) error {
+	return s.repository.CreateTransaction(ctx, transaction)
+}
+
+func (s *service) GetTransactionsByUserID(ctx context.Context, userID int) ([]domain.Transaction, error) {
+	return s.repository.GetTransactionsByUserID(ctx, userID)
+}
+
+func (s *service) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	if filter.Limit < 0 || filter.Limit > domain.MaxTransactionsLimit || filter.Offset < 0 {
+		return nil, domain.ErrInvalidInput.WithMessage(fmt.Sprintf("limit must be from 1 to %d and offset must not be negative", domain.MaxTransactionsLimit))
+	}
+	if filter.Limit == 0 {
+		filter.Limit = domain.DefaultTransactionsLimit
+	}
+	if filter.SortBy == "" {
+		filter.SortBy = domain.TransactionSortByDate
+	}
+	if filter.Order == "" {
+		filter.Order = domain.SortOrderDesc
+	}
+	return s.repository.ListTransactions(ctx, filter)
+}
+
+func (s *service) GetReservationByOrderID(ctx context.Context, orderID int) (*domain.Reservation, error) {
+	return s.repository.GetReservationByOrderID(ctx, orderID)
+}
+
+// ReserveFunds moves money from the user balance to the reserved one. It
+// fails with domain.ErrSpendingLimit if the reservation breaks a spending
+// limit and with domain.ErrInsufficientFunds if the balance is less than the
+// amount.
+func (s *service) ReserveFunds(ctx context.Context, input domain.ReservationInput) (err error) {
+	defer func() {
+		logOperation(ctx, OperationReservation, err, reservationFields(input)...)
+	}()
+
+	if input.UserID <= 0 {
+		return domain.ErrInvalidInput.WithMessage("user id must be positive")
+	}
+	if err := validateAmount(input.Amount); err != nil {
+		return err
+	}
+	check, err := s.spendingCheck(ctx, outgoingOperation{userID: input.UserID, amount: input.Amount})
+	if err != nil {
+		return err
+	}
+
+	return s.repository.ReserveFunds(ctx, input, check)
+}
+
+// CommitReservation takes the reserved money as the payment for the order.
+// It fails with domain.ErrReservationNotFound, domain.ErrReservationMismatch
+// if the input differs from the reservation and domain.ErrConflict if the
+// reservation is already committed or released.
+func (s *service) CommitReservation(ctx context.Context, input domain.ReservationInput) (err error) {
+	defer func() {
+		logOperation(ctx, OperationReservationCommit, err, reservationFields(input)...)
+	}()
+
+	return s.repository.CommitReservation(ctx, input)
+}
+
+// ReleaseReservation returns the reserved money to the user balance. It fails
+// the same way as CommitReservation.
+func (s *service) ReleaseReservation(ctx context.Context, input domain.ReservationInput) (err error) {
+	defer func() {
+		logOperation(ctx, OperationReservationRelease, err, reservationFields(input)...)
+	}()
+
+	return s.repository.ReleaseReservation(ctx, input)
+}
+
+func reservationFields(input domain.ReservationInput) []interface{} {
+	return []interface{}{"user_id", input.UserID, "amount", input.Amount, "service_id", input.ServiceID, "order_id", input.OrderID, "client_id", input.ClientID}
+}
+
+func (s *service) GetRevenueReport(ctx context.Context, input domain.RevenueReportInput) ([]domain.ServiceRevenue, error) {
+	from := time.Date(input.Year, time.Month(input.Month), 1, 0, 0, 0, 0, time.UTC)
+	return s.repository.GetRevenueByService(ctx, from, from.AddDate(0, 1, 0))
+}
+
+// convertBalance converts a balance in the base currency into the requested
+// one, rounded to the minor units of that currency.
+func (s *service) convertBalance(balance domain.Money, currency string) (domain.Money, error) {
+	rate, err := s.rateProvider.GetRate(currency)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	return balance.Convert(rate, currency)
+}
+
+func (s *service) GetIdempotencyKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	return s.repository.GetIdempotencyKey(ctx, key)
+}
+
+func (s *service) CreateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) (bool, error) {
+	idempotencyKey.ExpiresAt = time.Now().Add(domain.IdempotencyKeyTTL)
+	return s.repository.CreateIdempotencyKey(ctx, idempotencyKey)
+}
+
+func (s *service) UpdateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) error {
+	return s.repository.UpdateIdempotencyKey(ctx, idempotencyKey)
+}
+
+func (s *service) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	return s.repository.DeleteIdempotencyKey(ctx, key)
+}
+
+// CreateWebhook subscribes input.URL to balance events. A random secret is
+// generated when the input has none; webhooks are active unless created with
+// "active": false.
+func (s *service) CreateWebhook(ctx context.Context, input domain.WebhookInput) (*domain.Webhook, error) {
+	if err := validateWebhookURL(input.URL); err != nil {
+		return nil, err
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		generated, err := generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+
+	webhook := &domain.Webhook{
+		ClientID:   input.ClientID,
+		URL:        input.URL,
+		EventTypes: input.EventTypes,
+		UserID:     input.UserID,
+		Secret:     secret,
+		Active:     input.Active == nil || *input.Active,
+	}
+	if err := s.repository.CreateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// GetWebhook returns the webhook if it belongs to the client. Webhooks of
+// other clients are reported as not found.
+func (s *service) GetWebhook(ctx context.Context, clientID string, webhookID int) (*domain.Webhook, error) {
+	webhook, err := s.repository.GetWebhook(ctx, clientID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *service) ListWebhooks(ctx context.Context, clientID string) ([]domain.Webhook, error) {
+	return s.repository.ListWebhooks(ctx, clientID)
+}
+
+// UpdateWebhook replaces the URL, event types and user of the webhook. The
+// secret and the active flag are kept unless the input sets them.
+func (s *service) UpdateWebhook(ctx context.Context, webhookID int, input domain.WebhookInput) (*domain.Webhook, error) {
+	if err := validateWebhookURL(input.URL); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.GetWebhook(ctx, input.ClientID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.URL = input.URL
+	webhook.EventTypes = input.EventTypes
+	webhook.UserID = input.UserID
+	if input.Secret != "" {
+		webhook.Secret = input.Secret
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+
+	if err := s.repository.UpdateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *service) DeleteWebhook(ctx context.Context, clientID string, webhookID int) error {
+	return s.repository.DeleteWebhook(ctx, clientID, webhookID)
+}
+
+// ListWebhookAttempts returns the delivery attempts of the webhook, newest
+// first.
+func (s *service) ListWebhookAttempts(ctx context.Context, filter domain.WebhookAttemptFilter) ([]domain.WebhookAttempt, error) {
+	if filter.Limit < 0 || filter.Limit > domain.MaxWebhookAttemptsLimit || filter.Offset < 0 {
+		return nil, domain.ErrInvalidInput.WithMessage(fmt.Sprintf("limit must be from 1 to %d and offset must not be negative", domain.MaxWebhookAttemptsLimit))
+	}
+	if filter.Limit == 0 {
+		filter.Limit = domain.DefaultWebhookAttemptsLimit
+	}
+
+	if _, err := s.GetWebhook(ctx, filter.ClientID, filter.WebhookID); err != nil {
+		return nil, err
+	}
+	return s.repository.ListWebhookAttempts(ctx, filter)
+}
+
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.ErrInvalidInput.WithMessage("webhook url must be an absolute http or https url")
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func validateBalanceOperation(input domain.BalanceOperationInput) error {
+	if input.UserID <= 0 {
+		return domain.ErrInvalidInput.WithMessage("user id must be positive")
+	}
+	if err := validateAmount(input.Amount); err != nil {
+		return err
+	}
+	return validateComment(input.Comment)
+}
+
+// validateAmount accepts positive amounts in the default currency, the only
+// one balances are kept in.
+func validateAmount(amount domain.Money) error {
+	if amount.MinorUnits <= 0 {
+		return domain.ErrInvalidInput.WithMessage("amount must be positive")
+	}
+	if amount.Currency != domain.DefaultCurrency {
+		return domain.ErrInvalidInput.WithMessage("amount must be in " + domain.DefaultCurrency)
+	}
+	return nil
+}
+
+func validateComment(comment string) error {
+	if utf8.RuneCountInString(comment) > domain.MaxCommentLength {
+		return domain.ErrInvalidInput.WithMessage(fmt.Sprintf("comment must be at most %d characters", domain.MaxCommentLength))
+	}
+	return nil
 }
@@ -0,0 +1,590 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rubles(amount int64) domain.Money {
+	return domain.NewMoney(amount*100, domain.DefaultCurrency)
+}
+
+func TestService_GetBalance(t *testing.T) {
+	type mockBehavior func(r *mock_domain.MockRepository, p *mock_domain.MockRateProvider)
+
+	tests := []struct {
+		name            string
+		input           domain.GetBalanceInput
+		mockBehavior    mockBehavior
+		expectedBalance domain.Money
+		expectedErr     error
+	}{
+		{
+			name:  "OK",
+			input: domain.GetBalanceInput{ID: 1},
+			mockBehavior: func(r *mock_domain.MockRepository, p *mock_domain.MockRateProvider) {
+				r.EXPECT().GetUser(gomock.Any(), 1).Return(&domain.User{ID: 1, Balance: rubles(10)}, nil)
+			},
+			expectedBalance: rubles(10),
+		},
+		{
+			name:  "Converted",
+			input: domain.GetBalanceInput{ID: 1, Currency: "usd"},
+			mockBehavior: func(r *mock_domain.MockRepository, p *mock_domain.MockRateProvider) {
+				r.EXPECT().GetUser(gomock.Any(), 1).Return(&domain.User{ID: 1, Balance: rubles(1000)}, nil)
+				p.EXPECT().GetRate("USD").Return(0.0125, nil)
+			},
+			expectedBalance: domain.NewMoney(1250, "USD"),
+		},
+		{
+			name:  "User not found",
+			input: domain.GetBalanceInput{ID: 1},
+			mockBehavior: func(r *mock_domain.MockRepository, p *mock_domain.MockRateProvider) {
+				r.EXPECT().GetUser(gomock.Any(), 1).Return(nil, nil)
+			},
+			expectedErr: domain.ErrUserNotFound,
+		},
+		{
+			name:         "Invalid user id",
+			input:        domain.GetBalanceInput{ID: -1},
+			mockBehavior: func(r *mock_domain.MockRepository, p *mock_domain.MockRateProvider) {},
+			expectedErr:  domain.ErrInvalidInput,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repository := mock_domain.NewMockRepository(c)
+			rateProvider := mock_domain.NewMockRateProvider(c)
+			test.mockBehavior(repository, rateProvider)
+
+			balance, err := NewService(repository, rateProvider, domain.SpendingLimits{}).GetBalance(context.Background(), test.input)
+			assert.ErrorIs(t, err, test.expectedErr)
+			assert.Equal(t, test.expectedBalance, balance)
+		})
+	}
+}
+
+func TestService_Deposit(t *testing.T) {
+	errRepository := errors.New("repository returning error")
+
+	type mockBehavior func(r *mock_domain.MockRepository)
+
+	tests := []struct {
+		name         string
+		input        domain.BalanceOperationInput
+		mockBehavior mockBehavior
+		expectedErr  error
+	}{
+		{
+			name:  "Existing user",
+			input: domain.BalanceOperationInput{UserID: 1, Amount: rubles(10)},
+			mockBehavior: func(r *mock_domain.MockRepository) {
+				r.EXPECT().GetUser(gomock.Any(), 1).Return(&domain.User{ID: 1, Balance: rubles(0)}, nil)
+				r.EXPECT().MakeBalanceOperation(gomock.Any(), domain.BalanceOperationInput{UserID: 1, Amount: rubles(10), Type: domain.TransactionTypeAdd}, nil).Return(nil)
+			},
+		},
+		{
+			name:  "New user",
+			input: domain.BalanceOperationInput{UserID: 1, Amount: rubles(10)},
+			mockBehavior: func(r *mock_domain.MockRepository) {
+				r.EXPECT().GetUser(gomock.Any(), 1).Return(nil, nil)
+				r.EXPECT().CreateUser(gomock.Any(), &domain.User{ID: 1, Balance: rubles(0)}).Return(nil)
+				r.EXPECT().MakeBalanceOperation(gomock.Any(), domain.BalanceOperationInput{UserID: 1, Amount: rubles(10), Type: domain.TransactionTypeAdd}, nil).Return(nil)
+			},
+		},
+		{
+			name:  "User created concurrently",
+			input: domain.BalanceOperationInput{UserID: 1, Amount: rubles(10)},
+			mockBehavior: func(r *mock_domain.MockRepository) {
+				r.EXPECT().GetUser(gomock.Any(), 1).Return(nil, nil)
+				r.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(domain.ErrDuplicate)
+				r.EXPECT().MakeBalanceOperation(gomock.Any(), gomock.Any(), nil).Return(nil)
+			},
+		},
+		{
+			name:  "Creating user failed",
+			input: domain.BalanceOperationInput{UserID: 1, Amount: rubles(10)},
+			mockBehavior: func(r *mock_domain.MockRepository) {
+				r.EXPECT().GetUser(gomock.Any(), 1).Return(nil, nil)
+				r.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(errRepository)
+			},
+			expectedErr: errRepository,
+		},
+		{
+			name:         "Long comment",
+			input:        domain.BalanceOperationInput{UserID: 1, Amount: rubles(10), Comment: strings.Repeat("a", domain.MaxCommentLength+1)},
+			mockBehavior: func(r *mock_domain.MockRepository) {},
+			expectedErr:  domain.ErrInvalidInput,
+		},
+		{
+			name:         "Zero amount",
+			input:        domain.BalanceOperationInput{UserID: 1, Amount: rubles(0)},
+			mockBehavior: func(r *mock_domain.MockRepository) {},
+			expectedErr:  domain.ErrInvalidInput,
+		},
+		{
+			name:         "Foreign currency",
+			input:        domain.BalanceOperationInput{UserID: 1, Amount: domain.NewMoney(1000, "USD")},
+			mockBehavior: func(r *mock_domain.MockRepository) {},
+			expectedErr:  domain.ErrInvalidInput,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repository := mock_domain.NewMockRepository(c)
+			test.mockBehavior(repository)
+
+			err := NewService(repository, nil, domain.SpendingLimits{}).Deposit(context.Background(), test.input)
+			assert.ErrorIs(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestService_Withdraw(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+	repository.EXPECT().GetSpendingLimits(gomock.Any(), 1).Return(nil, nil)
+	repository.EXPECT().MakeBalanceOperation(gomock.Any(), domain.BalanceOperationInput{UserID: 1, Amount: rubles(10), Type: domain.TransactionTypeSubtract}, gomock.Any()).Return(domain.ErrInsufficientFunds)
+
+	err := NewService(repository, nil, domain.SpendingLimits{}).Withdraw(context.Background(), domain.BalanceOperationInput{UserID: 1, Amount: rubles(10), Type: domain.TransactionTypeAdd})
+	assert.ErrorIs(t, err, domain.ErrInsufficientFunds)
+}
+
+func TestService_ReserveFunds(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount domain.Money
+	}{
+		{name: "Zero amount", amount: rubles(0)},
+		{name: "Negative amount", amount: rubles(-10)},
+		{name: "Foreign currency", amount: domain.NewMoney(1000, "USD")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			// Invalid amounts are rejected before the repository is called.
+			s := NewService(mock_domain.NewMockRepository(c), nil, domain.SpendingLimits{})
+			err := s.ReserveFunds(context.Background(), domain.ReservationInput{UserID: 1, ServiceID: 2, OrderID: 3, Amount: test.amount})
+			assert.ErrorIs(t, err, domain.ErrInvalidInput)
+		})
+	}
+}
+
+func TestService_Transfer(t *testing.T) {
+	type mockBehavior func(r *mock_domain.MockRepository, input domain.P2PInput)
+
+	tests := []struct {
+		name         string
+		input        domain.P2PInput
+		mockBehavior mockBehavior
+		expectedErr  error
+	}{
+		{
+			name:  "OK",
+			input: domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(10)},
+			mockBehavior: func(r *mock_domain.MockRepository, input domain.P2PInput) {
+				r.EXPECT().GetSpendingLimits(gomock.Any(), 1).Return(nil, nil)
+				r.EXPECT().MakeP2PTransfer(gomock.Any(), input, gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:  "Not enough balance",
+			input: domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(10)},
+			mockBehavior: func(r *mock_domain.MockRepository, input domain.P2PInput) {
+				r.EXPECT().GetSpendingLimits(gomock.Any(), 1).Return(nil, nil)
+				r.EXPECT().MakeP2PTransfer(gomock.Any(), input, gomock.Any()).Return(domain.ErrInsufficientFunds)
+			},
+			expectedErr: domain.ErrInsufficientFunds,
+		},
+		{
+			name:         "Same user",
+			input:        domain.P2PInput{FromUserID: 1, ToUserID: 1, Amount: rubles(10)},
+			mockBehavior: func(r *mock_domain.MockRepository, input domain.P2PInput) {},
+			expectedErr:  domain.ErrInvalidInput,
+		},
+		{
+			name:         "Long comment",
+			input:        domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(10), Comment: strings.Repeat("a", domain.MaxCommentLength+1)},
+			mockBehavior: func(r *mock_domain.MockRepository, input domain.P2PInput) {},
+			expectedErr:  domain.ErrInvalidInput,
+		},
+		{
+			name:         "Negative amount",
+			input:        domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(-10)},
+			mockBehavior: func(r *mock_domain.MockRepository, input domain.P2PInput) {},
+			expectedErr:  domain.ErrInvalidInput,
+		},
+		{
+			name:         "Foreign currency",
+			input:        domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: domain.NewMoney(1000, "USD")},
+			mockBehavior: func(r *mock_domain.MockRepository, input domain.P2PInput) {},
+			expectedErr:  domain.ErrInvalidInput,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repository := mock_domain.NewMockRepository(c)
+			test.mockBehavior(repository, test.input)
+
+			err := NewService(repository, nil, domain.SpendingLimits{}).Transfer(context.Background(), test.input)
+			assert.ErrorIs(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestService_LogsMoneyOperations(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+	repository.EXPECT().GetSpendingLimits(gomock.Any(), 1).Return(nil, nil).Times(3)
+	gomock.InOrder(
+		repository.EXPECT().MakeP2PTransfer(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil),
+		repository.EXPECT().MakeP2PTransfer(gomock.Any(), gomock.Any(), gomock.Any()).Return(domain.ErrInsufficientFunds),
+		repository.EXPECT().MakeP2PTransfer(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("connection refused")),
+	)
+
+	buf := &bytes.Buffer{}
+	ctx := logging.NewContext(context.Background(), logging.New(buf, logging.LevelInfo))
+	s := NewService(repository, nil, domain.SpendingLimits{})
+	input := domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(10), ClientID: "shop"}
+	for i := 0; i < 3; i++ {
+		_ = s.Transfer(ctx, input)
+	}
+
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		record := map[string]interface{}{}
+		assert.NoError(t, decoder.Decode(&record))
+		delete(record, "time")
+		records = append(records, record)
+	}
+
+	fields := map[string]interface{}{"operation": "transfer", "from_user_id": float64(1), "to_user_id": float64(2), "amount": "10.00", "client_id": "shop"}
+	with := func(extra map[string]interface{}) map[string]interface{} {
+		record := map[string]interface{}{}
+		for key, value := range fields {
+			record[key] = value
+		}
+		for key, value := range extra {
+			record[key] = value
+		}
+		return record
+	}
+	assert.Equal(t, records, []map[string]interface{}{
+		with(map[string]interface{}{"level": "INFO", "msg": "money operation completed", "outcome": OutcomeSuccess}),
+		with(map[string]interface{}{"level": "WARN", "msg": "money operation rejected", "outcome": OutcomeRejected, "error_code": domain.ErrorCodeInsufficientFunds, "error": domain.ErrInsufficientFunds.Error()}),
+		with(map[string]interface{}{"level": "ERROR", "msg": "money operation failed", "outcome": OutcomeFailed, "error": "connection refused"}),
+	})
+}
+
+func TestService_SpendingLimits(t *testing.T) {
+	now := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+	today := time.Date(2022, 4, 11, 0, 0, 0, 0, time.UTC)
+	month := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	limit := func(amount int64) *domain.Money {
+		money := rubles(amount)
+		return &money
+	}
+	defaults := domain.SpendingLimits{Daily: limit(1000), Monthly: limit(10000), MaxTransfer: limit(500)}
+
+	// runCheck stands in for the repository, which runs the check with the
+	// balance of the user locked.
+	runCheck := func(spentToday, spentMonth int64) func(ctx context.Context, input interface{}, check domain.SpendingCheck) error {
+		return func(ctx context.Context, input interface{}, check domain.SpendingCheck) error {
+			return check(ctx, func(since time.Time) (domain.Money, error) {
+				switch since {
+				case today:
+					return rubles(spentToday), nil
+				case month:
+					return rubles(spentMonth), nil
+				}
+				return domain.Money{}, fmt.Errorf("unexpected since %s", since)
+			})
+		}
+	}
+
+	tests := []struct {
+		name        string
+		operation   string
+		amount      int64
+		override    *domain.SpendingLimits
+		spentToday  int64
+		spentMonth  int64
+		expectedErr error
+	}{
+		{
+			name:       "OK",
+			operation:  OperationTransfer,
+			amount:     100,
+			spentToday: 900,
+			spentMonth: 900,
+		},
+		{
+			name:      "Transfer over max",
+			operation: OperationTransfer,
+			amount:    501,
+			expectedErr: domain.ErrSpendingLimit.WithMessage("transfer amount is over the limit").WithDetails(domain.SpendingLimitViolation{
+				Limit:  domain.SpendingLimitMaxTransfer,
+				Max:    rubles(500),
+				Amount: rubles(501),
+			}),
+		},
+		{
+			name:      "Withdrawal is not limited by max transfer",
+			operation: OperationWithdrawal,
+			amount:    501,
+		},
+		{
+			name:       "Daily limit",
+			operation:  OperationTransfer,
+			amount:     101,
+			spentToday: 900,
+			expectedErr: domain.ErrSpendingLimit.WithMessage("daily spending limit is exceeded").WithDetails(domain.SpendingLimitViolation{
+				Limit:  domain.SpendingLimitDaily,
+				Max:    rubles(1000),
+				Spent:  limit(900),
+				Amount: rubles(101),
+			}),
+		},
+		{
+			name:        "Monthly limit",
+			operation:   OperationWithdrawal,
+			amount:      200,
+			spentMonth:  9900,
+			expectedErr: domain.ErrSpendingLimit,
+		},
+		{
+			name:       "Reservation",
+			operation:  OperationReservation,
+			amount:     101,
+			spentToday: 900,
+			expectedErr: domain.ErrSpendingLimit.WithMessage("daily spending limit is exceeded").WithDetails(domain.SpendingLimitViolation{
+				Limit:  domain.SpendingLimitDaily,
+				Max:    rubles(1000),
+				Spent:  limit(900),
+				Amount: rubles(101),
+			}),
+		},
+		{
+			name:       "User override",
+			operation:  OperationTransfer,
+			amount:     2000,
+			override:   &domain.SpendingLimits{Daily: limit(5000), MaxTransfer: limit(5000)},
+			spentMonth: 8500,
+			expectedErr: domain.ErrSpendingLimit.WithMessage("monthly spending limit is exceeded").WithDetails(domain.SpendingLimitViolation{
+				Limit:  domain.SpendingLimitMonthly,
+				Max:    rubles(10000),
+				Spent:  limit(8500),
+				Amount: rubles(2000),
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repository := mock_domain.NewMockRepository(c)
+			repository.EXPECT().GetSpendingLimits(gomock.Any(), 1).Return(test.override, nil)
+
+			s := NewService(repository, nil, defaults).(*service)
+			s.now = func() time.Time { return now }
+
+			var err error
+			switch test.operation {
+			case OperationTransfer:
+				repository.EXPECT().MakeP2PTransfer(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(runCheck(test.spentToday, test.spentMonth))
+				err = s.Transfer(context.Background(), domain.P2PInput{FromUserID: 1, ToUserID: 2, Amount: rubles(test.amount)})
+			case OperationWithdrawal:
+				repository.EXPECT().MakeBalanceOperation(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(runCheck(test.spentToday, test.spentMonth))
+				err = s.Withdraw(context.Background(), domain.BalanceOperationInput{UserID: 1, Amount: rubles(test.amount)})
+			case OperationReservation:
+				repository.EXPECT().ReserveFunds(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(runCheck(test.spentToday, test.spentMonth))
+				err = s.ReserveFunds(context.Background(), domain.ReservationInput{UserID: 1, ServiceID: 2, OrderID: 3, Amount: rubles(test.amount)})
+			}
+			if test.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, test.expectedErr)
+			var domainErr *domain.Error
+			if errors.As(test.expectedErr, &domainErr) && domainErr.Details != nil {
+				assert.Equal(t, test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestService_SetSpendingLimits(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	daily := rubles(100)
+	monthly := rubles(1000)
+
+	repository := mock_domain.NewMockRepository(c)
+	repository.EXPECT().SetSpendingLimits(gomock.Any(), 1, domain.SpendingLimits{Daily: &daily}).Return(nil)
+
+	s := NewService(repository, nil, domain.SpendingLimits{Monthly: &monthly})
+
+	limits, err := s.SetSpendingLimits(context.Background(), domain.SpendingLimitsInput{UserID: 1, Daily: &daily})
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.UserSpendingLimits{
+		UserID:    1,
+		Override:  domain.SpendingLimits{Daily: &daily},
+		Effective: domain.SpendingLimits{Daily: &daily, Monthly: &monthly},
+	}, limits)
+
+	negative := rubles(-1)
+	_, err = s.SetSpendingLimits(context.Background(), domain.SpendingLimitsInput{UserID: 1, MaxTransfer: &negative})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+}
+
+func TestService_ListTransactions(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+	repository.EXPECT().ListTransactions(gomock.Any(), domain.TransactionFilter{
+		UserID: 1,
+		Limit:  domain.DefaultTransactionsLimit,
+		SortBy: domain.TransactionSortByDate,
+		Order:  domain.SortOrderDesc,
+	}).Return([]domain.Transaction{}, nil)
+
+	s := NewService(repository, nil, domain.SpendingLimits{})
+
+	_, err := s.ListTransactions(context.Background(), domain.TransactionFilter{UserID: 1})
+	assert.NoError(t, err)
+
+	_, err = s.ListTransactions(context.Background(), domain.TransactionFilter{UserID: 1, Limit: domain.MaxTransactionsLimit + 1})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+}
+
+func TestService_CreateWebhook(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+	repository.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, webhook *domain.Webhook) error {
+		webhook.ID = 1
+		return nil
+	})
+
+	s := NewService(repository, nil, domain.SpendingLimits{})
+
+	webhook, err := s.CreateWebhook(context.Background(), domain.WebhookInput{
+		URL:        "https://merchant.example/hooks",
+		EventTypes: []string{domain.EventTypeDeposit},
+		ClientID:   "shop",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, webhook.ID)
+	assert.Equal(t, "shop", webhook.ClientID)
+	assert.True(t, webhook.Active)
+	assert.Len(t, webhook.Secret, 64)
+
+	_, err = s.CreateWebhook(context.Background(), domain.WebhookInput{
+		URL:        "ftp://merchant.example/hooks",
+		EventTypes: []string{domain.EventTypeDeposit},
+	})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+}
+
+func TestService_UpdateWebhook(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+
+	stored := &domain.Webhook{
+		ID:         1,
+		ClientID:   "shop",
+		URL:        "https://merchant.example/hooks",
+		EventTypes: []string{domain.EventTypeDeposit},
+		Secret:     "old secret",
+		Active:     true,
+	}
+	repository.EXPECT().GetWebhook(gomock.Any(), "shop", 1).Return(stored, nil)
+	repository.EXPECT().UpdateWebhook(gomock.Any(), gomock.Any()).Return(nil)
+	repository.EXPECT().GetWebhook(gomock.Any(), "other", 1).Return(nil, nil)
+
+	s := NewService(repository, nil, domain.SpendingLimits{})
+
+	inactive := false
+	webhook, err := s.UpdateWebhook(context.Background(), 1, domain.WebhookInput{
+		URL:        "https://merchant.example/v2/hooks",
+		EventTypes: []string{domain.EventTypeTransfer},
+		Active:     &inactive,
+		ClientID:   "shop",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://merchant.example/v2/hooks", webhook.URL)
+	assert.Equal(t, []string{domain.EventTypeTransfer}, webhook.EventTypes)
+	assert.Equal(t, "old secret", webhook.Secret)
+	assert.False(t, webhook.Active)
+
+	_, err = s.UpdateWebhook(context.Background(), 1, domain.WebhookInput{
+		URL:        "https://merchant.example/hooks",
+		EventTypes: []string{domain.EventTypeDeposit},
+		ClientID:   "other",
+	})
+	assert.ErrorIs(t, err, domain.ErrWebhookNotFound)
+}
+
+func TestService_ListWebhookAttempts(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+	repository.EXPECT().GetWebhook(gomock.Any(), "shop", 1).Return(&domain.Webhook{ID: 1, ClientID: "shop"}, nil)
+	repository.EXPECT().ListWebhookAttempts(gomock.Any(), domain.WebhookAttemptFilter{
+		WebhookID: 1,
+		Limit:     domain.DefaultWebhookAttemptsLimit,
+		ClientID:  "shop",
+	}).Return([]domain.WebhookAttempt{}, nil)
+	repository.EXPECT().GetWebhook(gomock.Any(), "other", 1).Return(nil, nil)
+
+	s := NewService(repository, nil, domain.SpendingLimits{})
+
+	_, err := s.ListWebhookAttempts(context.Background(), domain.WebhookAttemptFilter{WebhookID: 1, ClientID: "shop"})
+	assert.NoError(t, err)
+
+	_, err = s.ListWebhookAttempts(context.Background(), domain.WebhookAttemptFilter{WebhookID: 1, ClientID: "other"})
+	assert.ErrorIs(t, err, domain.ErrWebhookNotFound)
+
+	_, err = s.ListWebhookAttempts(context.Background(), domain.WebhookAttemptFilter{WebhookID: 1, Limit: domain.MaxWebhookAttemptsLimit + 1})
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+}
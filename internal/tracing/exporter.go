@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"io"
+	"sync"
+	"time"
+)
+
+type writerExporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewWriterExporter returns an exporter writing every span to w as a single
+// line of JSON, for looking at traces without a collector.
+func NewWriterExporter(w io.Writer) sdktrace.SpanExporter {
+	return &writerExporter{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+type writtenSpan struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	StartTime    time.Time              `json:"start_time"`
+	DurationMS   float64                `json:"duration_ms"`
+	Status       string                 `json:"status"`
+	Error        string                 `json:"error,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Events       []writtenEvent         `json:"events,omitempty"`
+}
+
+type writtenEvent struct {
+	Name       string                 `json:"name"`
+	Time       time.Time              `json:"time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func (e *writerExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, span := range spans {
+		written := writtenSpan{
+			TraceID:    span.SpanContext().TraceID().String(),
+			SpanID:     span.SpanContext().SpanID().String(),
+			Name:       span.Name(),
+			Kind:       span.SpanKind().String(),
+			StartTime:  span.StartTime().UTC(),
+			DurationMS: float64(span.EndTime().Sub(span.StartTime()).Microseconds()) / 1000,
+			Status:     span.Status().Code.String(),
+			Attributes: attributeMap(span.Attributes()),
+		}
+		if span.Parent().IsValid() {
+			written.ParentSpanID = span.Parent().SpanID().String()
+		}
+		if span.Status().Code == codes.Error {
+			written.Error = span.Status().Description
+		}
+		for _, event := range span.Events() {
+			written.Events = append(written.Events, writtenEvent{
+				Name:       event.Name,
+				Time:       event.Time.UTC(),
+				Attributes: attributeMap(event.Attributes),
+			})
+		}
+
+		if err := e.encoder.Encode(written); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *writerExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func attributeMap(attributes []attribute.KeyValue) map[string]interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(attributes))
+	for _, kv := range attributes {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
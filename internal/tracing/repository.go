@@ -0,0 +1,286 @@
+package tracing
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"time"
+)
+
+type tracedRepository struct {
+	next domain.Repository
+}
+
+// NewRepository wraps the repository, running every call in a span named
+// after the method, e.g. "Repository.MakeP2PTransfer". The queries it runs
+// are traced by WrapConnector below it.
+func NewRepository(next domain.Repository) domain.Repository {
+	return &tracedRepository{
+		next: next,
+	}
+}
+
+func (t *tracedRepository) GetUser(ctx context.Context, userID int) (*domain.User, error) {
+	ctx, span := start(ctx, "Repository.GetUser")
+	result, err := t.next.GetUser(ctx, userID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) CreateUser(ctx context.Context, user *domain.User) error {
+	ctx, span := start(ctx, "Repository.CreateUser")
+	err := t.next.CreateUser(ctx, user)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) UpdateUser(ctx context.Context, userID int, user *domain.User) error {
+	ctx, span := start(ctx, "Repository.UpdateUser")
+	err := t.next.UpdateUser(ctx, userID, user)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) MakeBalanceOperation(ctx context.Context, input domain.BalanceOperationInput, check domain.SpendingCheck) error {
+	ctx, span := start(ctx, "Repository.MakeBalanceOperation")
+	err := t.next.MakeBalanceOperation(ctx, input, check)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) MakeP2PTransfer(ctx context.Context, p2pInput domain.P2PInput, check domain.SpendingCheck) error {
+	ctx, span := start(ctx, "Repository.MakeP2PTransfer")
+	err := t.next.MakeP2PTransfer(ctx, p2pInput, check)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) CreateTransaction(ctx context.Context, transaction *domain.Transaction) error {
+	ctx, span := start(ctx, "Repository.CreateTransaction")
+	err := t.next.CreateTransaction(ctx, transaction)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) GetTransactionsByUserID(ctx context.Context, userID int) ([]domain.Transaction, error) {
+	ctx, span := start(ctx, "Repository.GetTransactionsByUserID")
+	result, err := t.next.GetTransactionsByUserID(ctx, userID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	ctx, span := start(ctx, "Repository.ListTransactions")
+	result, err := t.next.ListTransactions(ctx, filter)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) GetReservationByOrderID(ctx context.Context, orderID int) (*domain.Reservation, error) {
+	ctx, span := start(ctx, "Repository.GetReservationByOrderID")
+	result, err := t.next.GetReservationByOrderID(ctx, orderID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) ReserveFunds(ctx context.Context, input domain.ReservationInput, check domain.SpendingCheck) error {
+	ctx, span := start(ctx, "Repository.ReserveFunds")
+	err := t.next.ReserveFunds(ctx, input, check)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) CommitReservation(ctx context.Context, input domain.ReservationInput) error {
+	ctx, span := start(ctx, "Repository.CommitReservation")
+	err := t.next.CommitReservation(ctx, input)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) ReleaseReservation(ctx context.Context, input domain.ReservationInput) error {
+	ctx, span := start(ctx, "Repository.ReleaseReservation")
+	err := t.next.ReleaseReservation(ctx, input)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) GetRevenueByService(ctx context.Context, from, to time.Time) ([]domain.ServiceRevenue, error) {
+	ctx, span := start(ctx, "Repository.GetRevenueByService")
+	result, err := t.next.GetRevenueByService(ctx, from, to)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) GetIdempotencyKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	ctx, span := start(ctx, "Repository.GetIdempotencyKey")
+	result, err := t.next.GetIdempotencyKey(ctx, key)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) CreateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) (bool, error) {
+	ctx, span := start(ctx, "Repository.CreateIdempotencyKey")
+	result, err := t.next.CreateIdempotencyKey(ctx, idempotencyKey)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) UpdateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) error {
+	ctx, span := start(ctx, "Repository.UpdateIdempotencyKey")
+	err := t.next.UpdateIdempotencyKey(ctx, idempotencyKey)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	ctx, span := start(ctx, "Repository.DeleteIdempotencyKey")
+	err := t.next.DeleteIdempotencyKey(ctx, key)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.Event, error) {
+	ctx, span := start(ctx, "Repository.ClaimEvents")
+	result, err := t.next.ClaimEvents(ctx, limit, lease)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) MarkEventPublished(ctx context.Context, eventID int64) error {
+	ctx, span := start(ctx, "Repository.MarkEventPublished")
+	err := t.next.MarkEventPublished(ctx, eventID)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) MarkEventFailed(ctx context.Context, eventID int64, nextAttemptAt time.Time, lastError string) error {
+	ctx, span := start(ctx, "Repository.MarkEventFailed")
+	err := t.next.MarkEventFailed(ctx, eventID, nextAttemptAt, lastError)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) CreateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	ctx, span := start(ctx, "Repository.CreateWebhook")
+	err := t.next.CreateWebhook(ctx, webhook)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) GetWebhook(ctx context.Context, clientID string, webhookID int) (*domain.Webhook, error) {
+	ctx, span := start(ctx, "Repository.GetWebhook")
+	result, err := t.next.GetWebhook(ctx, clientID, webhookID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) ListWebhooks(ctx context.Context, clientID string) ([]domain.Webhook, error) {
+	ctx, span := start(ctx, "Repository.ListWebhooks")
+	result, err := t.next.ListWebhooks(ctx, clientID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) UpdateWebhook(ctx context.Context, webhook *domain.Webhook) error {
+	ctx, span := start(ctx, "Repository.UpdateWebhook")
+	err := t.next.UpdateWebhook(ctx, webhook)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) DeleteWebhook(ctx context.Context, clientID string, webhookID int) error {
+	ctx, span := start(ctx, "Repository.DeleteWebhook")
+	err := t.next.DeleteWebhook(ctx, clientID, webhookID)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) CreateWebhookDeliveries(ctx context.Context, event domain.Event, userIDs []int) error {
+	ctx, span := start(ctx, "Repository.CreateWebhookDeliveries")
+	err := t.next.CreateWebhookDeliveries(ctx, event, userIDs)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	ctx, span := start(ctx, "Repository.ClaimWebhookDeliveries")
+	result, err := t.next.ClaimWebhookDeliveries(ctx, limit, lease)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) RecordWebhookAttempt(ctx context.Context, attempt *domain.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	ctx, span := start(ctx, "Repository.RecordWebhookAttempt")
+	err := t.next.RecordWebhookAttempt(ctx, attempt, status, nextAttemptAt)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) ListWebhookAttempts(ctx context.Context, filter domain.WebhookAttemptFilter) ([]domain.WebhookAttempt, error) {
+	ctx, span := start(ctx, "Repository.ListWebhookAttempts")
+	result, err := t.next.ListWebhookAttempts(ctx, filter)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) CreateAPIKey(ctx context.Context, apiKey *domain.APIKey) error {
+	ctx, span := start(ctx, "Repository.CreateAPIKey")
+	err := t.next.CreateAPIKey(ctx, apiKey)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	ctx, span := start(ctx, "Repository.GetAPIKeyByHash")
+	result, err := t.next.GetAPIKeyByHash(ctx, hash)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	ctx, span := start(ctx, "Repository.ListAPIKeys")
+	result, err := t.next.ListAPIKeys(ctx)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) RevokeAPIKey(ctx context.Context, apiKeyID int) error {
+	ctx, span := start(ctx, "Repository.RevokeAPIKey")
+	err := t.next.RevokeAPIKey(ctx, apiKeyID)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *domain.RateLimitBucket, now time.Time)) error {
+	ctx, span := start(ctx, "Repository.UpdateRateLimitBucket")
+	err := t.next.UpdateRateLimitBucket(ctx, key, update)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) DeleteRateLimitBuckets(ctx context.Context, updatedBefore time.Time) error {
+	ctx, span := start(ctx, "Repository.DeleteRateLimitBuckets")
+	err := t.next.DeleteRateLimitBuckets(ctx, updatedBefore)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) GetSpendingLimits(ctx context.Context, userID int) (*domain.SpendingLimits, error) {
+	ctx, span := start(ctx, "Repository.GetSpendingLimits")
+	result, err := t.next.GetSpendingLimits(ctx, userID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedRepository) SetSpendingLimits(ctx context.Context, userID int, limits domain.SpendingLimits) error {
+	ctx, span := start(ctx, "Repository.SetSpendingLimits")
+	err := t.next.SetSpendingLimits(ctx, userID, limits)
+	end(span, err)
+	return err
+}
+
+func (t *tracedRepository) DeleteSpendingLimits(ctx context.Context, userID int) error {
+	ctx, span := start(ctx, "Repository.DeleteSpendingLimits")
+	err := t.next.DeleteSpendingLimits(ctx, userID)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,214 @@
+package tracing
+
+import (
+	"context"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+)
+
+type tracedService struct {
+	next domain.Service
+}
+
+// NewService wraps the service, running every call in a span named after the
+// method, e.g. "Service.Transfer".
+func NewService(next domain.Service) domain.Service {
+	return &tracedService{
+		next: next,
+	}
+}
+
+func (t *tracedService) GetUser(ctx context.Context, userID int) (*domain.User, error) {
+	ctx, span := start(ctx, "Service.GetUser")
+	result, err := t.next.GetUser(ctx, userID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) CreateUser(ctx context.Context, user *domain.User) error {
+	ctx, span := start(ctx, "Service.CreateUser")
+	err := t.next.CreateUser(ctx, user)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) UpdateUser(ctx context.Context, userID int, user *domain.User) error {
+	ctx, span := start(ctx, "Service.UpdateUser")
+	err := t.next.UpdateUser(ctx, userID, user)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) GetBalance(ctx context.Context, input domain.GetBalanceInput) (domain.Money, error) {
+	ctx, span := start(ctx, "Service.GetBalance")
+	result, err := t.next.GetBalance(ctx, input)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) Deposit(ctx context.Context, input domain.BalanceOperationInput) error {
+	ctx, span := start(ctx, "Service.Deposit")
+	err := t.next.Deposit(ctx, input)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) Withdraw(ctx context.Context, input domain.BalanceOperationInput) error {
+	ctx, span := start(ctx, "Service.Withdraw")
+	err := t.next.Withdraw(ctx, input)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) Transfer(ctx context.Context, input domain.P2PInput) error {
+	ctx, span := start(ctx, "Service.Transfer")
+	err := t.next.Transfer(ctx, input)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) CreateTransaction(ctx context.Context, transaction *domain.Transaction) error {
+	ctx, span := start(ctx, "Service.CreateTransaction")
+	err := t.next.CreateTransaction(ctx, transaction)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) GetTransactionsByUserID(ctx context.Context, userID int) ([]domain.Transaction, error) {
+	ctx, span := start(ctx, "Service.GetTransactionsByUserID")
+	result, err := t.next.GetTransactionsByUserID(ctx, userID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) ListTransactions(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	ctx, span := start(ctx, "Service.ListTransactions")
+	result, err := t.next.ListTransactions(ctx, filter)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) GetReservationByOrderID(ctx context.Context, orderID int) (*domain.Reservation, error) {
+	ctx, span := start(ctx, "Service.GetReservationByOrderID")
+	result, err := t.next.GetReservationByOrderID(ctx, orderID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) ReserveFunds(ctx context.Context, input domain.ReservationInput) error {
+	ctx, span := start(ctx, "Service.ReserveFunds")
+	err := t.next.ReserveFunds(ctx, input)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) CommitReservation(ctx context.Context, input domain.ReservationInput) error {
+	ctx, span := start(ctx, "Service.CommitReservation")
+	err := t.next.CommitReservation(ctx, input)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) ReleaseReservation(ctx context.Context, input domain.ReservationInput) error {
+	ctx, span := start(ctx, "Service.ReleaseReservation")
+	err := t.next.ReleaseReservation(ctx, input)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) GetRevenueReport(ctx context.Context, input domain.RevenueReportInput) ([]domain.ServiceRevenue, error) {
+	ctx, span := start(ctx, "Service.GetRevenueReport")
+	result, err := t.next.GetRevenueReport(ctx, input)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) GetIdempotencyKey(ctx context.Context, key string) (*domain.IdempotencyKey, error) {
+	ctx, span := start(ctx, "Service.GetIdempotencyKey")
+	result, err := t.next.GetIdempotencyKey(ctx, key)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) CreateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) (bool, error) {
+	ctx, span := start(ctx, "Service.CreateIdempotencyKey")
+	result, err := t.next.CreateIdempotencyKey(ctx, idempotencyKey)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) UpdateIdempotencyKey(ctx context.Context, idempotencyKey *domain.IdempotencyKey) error {
+	ctx, span := start(ctx, "Service.UpdateIdempotencyKey")
+	err := t.next.UpdateIdempotencyKey(ctx, idempotencyKey)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	ctx, span := start(ctx, "Service.DeleteIdempotencyKey")
+	err := t.next.DeleteIdempotencyKey(ctx, key)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) CreateWebhook(ctx context.Context, input domain.WebhookInput) (*domain.Webhook, error) {
+	ctx, span := start(ctx, "Service.CreateWebhook")
+	result, err := t.next.CreateWebhook(ctx, input)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) GetWebhook(ctx context.Context, clientID string, webhookID int) (*domain.Webhook, error) {
+	ctx, span := start(ctx, "Service.GetWebhook")
+	result, err := t.next.GetWebhook(ctx, clientID, webhookID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) ListWebhooks(ctx context.Context, clientID string) ([]domain.Webhook, error) {
+	ctx, span := start(ctx, "Service.ListWebhooks")
+	result, err := t.next.ListWebhooks(ctx, clientID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) UpdateWebhook(ctx context.Context, webhookID int, input domain.WebhookInput) (*domain.Webhook, error) {
+	ctx, span := start(ctx, "Service.UpdateWebhook")
+	result, err := t.next.UpdateWebhook(ctx, webhookID, input)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) DeleteWebhook(ctx context.Context, clientID string, webhookID int) error {
+	ctx, span := start(ctx, "Service.DeleteWebhook")
+	err := t.next.DeleteWebhook(ctx, clientID, webhookID)
+	end(span, err)
+	return err
+}
+
+func (t *tracedService) ListWebhookAttempts(ctx context.Context, filter domain.WebhookAttemptFilter) ([]domain.WebhookAttempt, error) {
+	ctx, span := start(ctx, "Service.ListWebhookAttempts")
+	result, err := t.next.ListWebhookAttempts(ctx, filter)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) GetSpendingLimits(ctx context.Context, userID int) (*domain.UserSpendingLimits, error) {
+	ctx, span := start(ctx, "Service.GetSpendingLimits")
+	result, err := t.next.GetSpendingLimits(ctx, userID)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) SetSpendingLimits(ctx context.Context, input domain.SpendingLimitsInput) (*domain.UserSpendingLimits, error) {
+	ctx, span := start(ctx, "Service.SetSpendingLimits")
+	result, err := t.next.SetSpendingLimits(ctx, input)
+	end(span, err)
+	return result, err
+}
+
+func (t *tracedService) DeleteSpendingLimits(ctx context.Context, userID int) error {
+	ctx, span := start(ctx, "Service.DeleteSpendingLimits")
+	err := t.next.DeleteSpendingLimits(ctx, userID)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// QueryNamer names a query for its span, returning "" for queries it does
+// not know. Unknown queries are named after their SQL verb.
+type QueryNamer func(query string) string
+
+type connector struct {
+	driver.Connector
+	name QueryNamer
+}
+
+// WrapConnector traces the queries and transactions run over the connections
+// of c. Only work done on behalf of a traced request gets spans: queries
+// without a span in their context, like the polling of background workers,
+// are not traced.
+func WrapConnector(c driver.Connector, name QueryNamer) driver.Connector {
+	return &connector{
+		Connector: c,
+		name:      name,
+	}
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, name: c.name}, nil
+}
+
+type tracedConn struct {
+	driver.Conn
+	name QueryNamer
+}
+
+var (
+	_ driver.ConnBeginTx        = (*tracedConn)(nil)
+	_ driver.ConnPrepareContext = (*tracedConn)(nil)
+	_ driver.ExecerContext      = (*tracedConn)(nil)
+	_ driver.QueryerContext     = (*tracedConn)(nil)
+	_ driver.Pinger             = (*tracedConn)(nil)
+)
+
+// startSQLSpan starts a client span for the statement, unless ctx is not
+// traced. The returned function ends the span, recording err.
+func startSQLSpan(ctx context.Context, name string, statement string) func(err error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return func(error) {}
+	}
+
+	attributes := []attribute.KeyValue{semconv.DBSystemPostgreSQL}
+	if statement != "" {
+		attributes = append(attributes, semconv.DBStatementKey.String(statement))
+	}
+	_, span := Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	return func(err error) {
+		if err != nil && err != driver.ErrSkip {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (c *tracedConn) queryName(query string) string {
+	if name := c.name(query); name != "" {
+		return name
+	}
+	if fields := strings.Fields(query); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "SQL"
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	end := startSQLSpan(ctx, c.queryName(query), query)
+	result, err := execer.ExecContext(ctx, query, args)
+	end(err)
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	end := startSQLSpan(ctx, c.queryName(query), query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	end(err)
+	return rows, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx traces the statements starting and ending the transaction. The
+// commit or rollback belongs to the context the transaction was started
+// with.
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	end := startSQLSpan(ctx, "BEGIN", "")
+
+	var tx driver.Tx
+	var err error
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	end(err)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedTx{Tx: tx, ctx: ctx}, nil
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+type tracedTx struct {
+	driver.Tx
+	ctx context.Context
+}
+
+func (tx *tracedTx) Commit() error {
+	end := startSQLSpan(tx.ctx, "COMMIT", "")
+	err := tx.Tx.Commit()
+	end(err)
+	return err
+}
+
+func (tx *tracedTx) Rollback() error {
+	end := startSQLSpan(tx.ctx, "ROLLBACK", "")
+	err := tx.Tx.Rollback()
+	end(err)
+	return err
+}
@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// record installs a tracer provider keeping the ended spans in memory.
+func record() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
+
+func TestNewWriterExporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(NewWriterExporter(buf)))
+	tracer := provider.Tracer(InstrumentationName)
+
+	ctx, parent := tracer.Start(context.Background(), "POST /api/v1/p2p")
+	_, child := tracer.Start(ctx, "Service.Transfer")
+	child.SetStatus(codes.Error, "insufficient funds")
+	child.End()
+	parent.End()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var span map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &span))
+	assert.Equal(t, span["name"], "Service.Transfer")
+	assert.Equal(t, span["trace_id"], parent.SpanContext().TraceID().String())
+	assert.Equal(t, span["parent_span_id"], parent.SpanContext().SpanID().String())
+	assert.Equal(t, span["status"], "Error")
+	assert.Equal(t, span["error"], "insufficient funds")
+}
+
+func TestNewOTLPExporter(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer server.Close()
+
+	exporter, err := newOTLPExporter(server.URL + "/collector/")
+	require.NoError(t, err)
+
+	provider := sdktrace.NewTracerProvider()
+	_, span := provider.Tracer(InstrumentationName).Start(context.Background(), "QueryGetUser")
+	span.End()
+
+	require.NoError(t, exporter.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{span.(sdktrace.ReadOnlySpan)}))
+	request := <-requests
+	assert.Equal(t, request.URL.Path, "/collector/v1/traces")
+	assert.Equal(t, request.Header.Get("Content-Type"), "application/x-protobuf")
+}
+
+// dsnConnector opens connections of a driver the way sql.Open does.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func TestWrapConnector(t *testing.T) {
+	recorder := record()
+
+	mockDB, mock, err := sqlmock.NewWithDSN("tracing_test")
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	names := map[string]string{"UPDATE users SET balance = $1 WHERE id = $2": "QueryUpdateUser"}
+	db := sql.OpenDB(WrapConnector(dsnConnector{dsn: "tracing_test", driver: mockDB.Driver()}, func(query string) string {
+		return names[query]
+	}))
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("connection reset"))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM outbox").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx, parent := Tracer().Start(context.Background(), "Repository.UpdateUser")
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET balance = $1 WHERE id = $2", 100, 1)
+	require.NoError(t, err)
+	_, err = tx.QueryContext(ctx, "SELECT 1")
+	require.Error(t, err)
+	require.NoError(t, tx.Commit())
+	parent.End()
+
+	// Without a traced request there is nothing to attach the span to.
+	_, err = db.ExecContext(context.Background(), "DELETE FROM outbox")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	spans := recorder.Ended()
+	assert.Equal(t, []string{"BEGIN", "QueryUpdateUser", "SELECT", "COMMIT", "Repository.UpdateUser"}, spanNames(spans))
+	for _, span := range spans[:4] {
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+	assert.Contains(t, spans[1].Attributes(), semconv.DBStatementKey.String("UPDATE users SET balance = $1 WHERE id = $2"))
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+}
+
+func TestNewService(t *testing.T) {
+	recorder := record()
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	next := mock_domain.NewMockService(c)
+	next.EXPECT().GetUser(gomock.Any(), 1).Return(&domain.User{ID: 1}, nil).Times(2)
+	next.EXPECT().Transfer(gomock.Any(), gomock.Any()).Return(domain.ErrInsufficientFunds)
+
+	s := NewService(next)
+	ctx, parent := Tracer().Start(context.Background(), "POST /api/v1/p2p")
+	_, _ = s.GetUser(ctx, 1)
+	_ = s.Transfer(ctx, domain.P2PInput{FromUserID: 1, ToUserID: 2})
+	parent.End()
+	_, _ = s.GetUser(context.Background(), 1)
+
+	spans := recorder.Ended()
+	assert.Equal(t, []string{"Service.GetUser", "Service.Transfer", "POST /api/v1/p2p"}, spanNames(spans))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	"github.com/lov3allmy/avito-test-go/internal/logging"
+	"github.com/lov3allmy/avito-test-go/internal/retry"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DispatcherConfig controls how often queued deliveries are sent and how
+// failures are retried; zero fields are filled in from the Default constants.
+type DispatcherConfig struct {
+	retry.PollConfig
+	MaxAttempts int
+}
+
+const (
+	DefaultInterval    = time.Second
+	DefaultBatchSize   = 100
+	DefaultLease       = time.Minute
+	DefaultBackoff     = 10 * time.Second
+	DefaultMaxBackoff  = time.Hour
+	DefaultMaxAttempts = 10
+)
+
+// Dispatcher POSTs queued events to webhooks. A delivery succeeds on any 2xx
+// response; other responses and network errors are retried with exponential
+// backoff, and after MaxAttempts failed attempts the delivery is moved to the
+// dead state and not tried again.
+type Dispatcher struct {
+	repository domain.Repository
+	client     *http.Client
+	config     DispatcherConfig
+	now        func() time.Time
+}
+
+var dispatcherDefaults = retry.PollConfig{
+	Interval:   DefaultInterval,
+	BatchSize:  DefaultBatchSize,
+	Lease:      DefaultLease,
+	Backoff:    DefaultBackoff,
+	MaxBackoff: DefaultMaxBackoff,
+}
+
+func NewDispatcher(repository domain.Repository, client *http.Client, config DispatcherConfig) *Dispatcher {
+	config.PollConfig = config.PollConfig.WithDefaults(dispatcherDefaults)
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+
+	return &Dispatcher{
+		repository: repository,
+		client:     client,
+		config:     config,
+		now:        time.Now,
+	}
+}
+
+// Run sends due deliveries every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	retry.Poll(ctx, d.config.Interval, func(ctx context.Context) {
+		if _, err := d.DispatchPending(ctx); err != nil {
+			logging.FromContext(ctx).Error("dispatching webhooks failed", "error", err)
+		}
+	})
+}
+
+// DispatchPending claims one batch of due deliveries and sends them. It
+// returns the number of deliveries that succeeded.
+func (d *Dispatcher) DispatchPending(ctx context.Context) (int, error) {
+	deliveries, err := d.repository.ClaimWebhookDeliveries(ctx, d.config.BatchSize, d.config.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		attempt := d.send(ctx, delivery)
+
+		status := domain.WebhookDeliveryStatusDelivered
+		nextAttemptAt := d.now()
+		if attempt.Error != nil {
+			status = domain.WebhookDeliveryStatusPending
+			nextAttemptAt = nextAttemptAt.Add(d.config.Delay(delivery.Attempts))
+			if attempt.Attempt >= d.config.MaxAttempts {
+				status = domain.WebhookDeliveryStatusDead
+			}
+		}
+
+		if err := d.repository.RecordWebhookAttempt(ctx, attempt, status, nextAttemptAt); err != nil {
+			return delivered, err
+		}
+		if status == domain.WebhookDeliveryStatusDelivered {
+			delivered++
+		}
+	}
+
+	return delivered, nil
+}
+
+// send makes one attempt to deliver the event. A failed attempt has its Error
+// set.
+func (d *Dispatcher) send(ctx context.Context, delivery domain.WebhookDelivery) *domain.WebhookAttempt {
+	attempt := &domain.WebhookAttempt{
+		DeliveryID: delivery.ID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Attempt:    delivery.Attempts + 1,
+	}
+
+	started := d.now()
+	statusCode, err := d.post(ctx, delivery, started)
+	attempt.DurationMS = int(d.now().Sub(started) / time.Millisecond)
+
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+	if err != nil {
+		message := err.Error()
+		attempt.Error = &message
+	}
+	return attempt
+}
+
+func (d *Dispatcher) post(ctx context.Context, delivery domain.WebhookDelivery, sentAt time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := sentAt.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
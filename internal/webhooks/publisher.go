@@ -0,0 +1,47 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+)
+
+type publisher struct {
+	repository domain.Repository
+}
+
+// NewEventPublisher returns an outbox publisher queueing every event for the
+// webhooks subscribed to it. The dispatcher delivers queued events later.
+func NewEventPublisher(repository domain.Repository) domain.EventPublisher {
+	return &publisher{
+		repository: repository,
+	}
+}
+
+func (p *publisher) Publish(ctx context.Context, event domain.Event) error {
+	userIDs, err := eventUserIDs(event)
+	if err != nil {
+		return err
+	}
+	return p.repository.CreateWebhookDeliveries(ctx, event, userIDs)
+}
+
+// eventUserIDs lists the users whose balance the event changed.
+func eventUserIDs(event domain.Event) ([]int, error) {
+	var payload struct {
+		UserID     int `json:"user_id"`
+		FromUserID int `json:"from_user_id"`
+		ToUserID   int `json:"to_user_id"`
+	}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	var userIDs []int
+	for _, userID := range []int{payload.UserID, payload.FromUserID, payload.ToUserID} {
+		if userID != 0 {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
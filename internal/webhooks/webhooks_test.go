@@ -0,0 +1,174 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/lov3allmy/avito-test-go/internal/domain"
+	mock_domain "github.com/lov3allmy/avito-test-go/internal/mocks"
+	"github.com/lov3allmy/avito-test-go/internal/retry"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	signature := Sign(testSecret, 1649678400, body)
+
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.True(t, Verify(testSecret, 1649678400, body, signature))
+	assert.False(t, Verify(testSecret, 1649678401, body, signature))
+	assert.False(t, Verify("another secret", 1649678400, body, signature))
+	assert.False(t, Verify(testSecret, 1649678400, []byte(`{"id":2}`), signature))
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+
+	event := domain.Event{
+		ID:      1,
+		Type:    domain.EventTypeTransfer,
+		Payload: json.RawMessage(`{"from_user_id":2,"to_user_id":3,"amount":"10.00"}`),
+	}
+	repository.EXPECT().CreateWebhookDeliveries(gomock.Any(), event, []int{2, 3}).Return(nil)
+
+	assert.NoError(t, NewEventPublisher(repository).Publish(context.Background(), event))
+}
+
+func TestDispatcher_DispatchPending(t *testing.T) {
+	body := []byte(`{"id":1,"type":"balance.deposit","payload":{"user_id":1,"amount":"10.00"},"created_at":"2022-04-11T12:00:00Z"}`)
+	now := time.Date(2022, 4, 11, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		responseStatus int
+		attempts       int
+		expectedStatus string
+		expectedNext   time.Time
+		expectedCount  int
+	}{
+		{
+			name:           "Delivered",
+			responseStatus: http.StatusOK,
+			expectedStatus: domain.WebhookDeliveryStatusDelivered,
+			expectedNext:   now,
+			expectedCount:  1,
+		},
+		{
+			name:           "Retried",
+			responseStatus: http.StatusServiceUnavailable,
+			attempts:       2,
+			expectedStatus: domain.WebhookDeliveryStatusPending,
+			expectedNext:   now.Add(4 * time.Second),
+		},
+		{
+			name:           "Dead letter",
+			responseStatus: http.StatusInternalServerError,
+			attempts:       4,
+			expectedStatus: domain.WebhookDeliveryStatusDead,
+			expectedNext:   now.Add(10 * time.Second),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repository := mock_domain.NewMockRepository(c)
+
+			received := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received++
+				requestBody, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, body, requestBody)
+
+				timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+				assert.NoError(t, err)
+				assert.Equal(t, now.Unix(), timestamp)
+				assert.True(t, Verify(testSecret, timestamp, requestBody, r.Header.Get(HeaderSignature)))
+				assert.Equal(t, domain.EventTypeDeposit, r.Header.Get(HeaderEvent))
+				assert.Equal(t, "7", r.Header.Get(HeaderDelivery))
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+				w.WriteHeader(test.responseStatus)
+			}))
+			defer server.Close()
+
+			delivery := domain.WebhookDelivery{
+				ID:        7,
+				WebhookID: 1,
+				EventID:   1,
+				EventType: domain.EventTypeDeposit,
+				Body:      body,
+				Status:    domain.WebhookDeliveryStatusPending,
+				Attempts:  test.attempts,
+				URL:       server.URL,
+				Secret:    testSecret,
+			}
+
+			repository.EXPECT().ClaimWebhookDeliveries(gomock.Any(), 10, DefaultLease).Return([]domain.WebhookDelivery{delivery}, nil)
+			repository.EXPECT().RecordWebhookAttempt(gomock.Any(), gomock.Any(), test.expectedStatus, test.expectedNext).
+				DoAndReturn(func(_ context.Context, attempt *domain.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+					assert.Equal(t, int64(7), attempt.DeliveryID)
+					assert.Equal(t, test.attempts+1, attempt.Attempt)
+					assert.Equal(t, test.responseStatus, *attempt.StatusCode)
+					assert.Equal(t, test.responseStatus >= 300, attempt.Error != nil)
+					return nil
+				})
+
+			dispatcher := NewDispatcher(repository, server.Client(), DispatcherConfig{
+				PollConfig: retry.PollConfig{
+					BatchSize:  10,
+					Backoff:    time.Second,
+					MaxBackoff: 10 * time.Second,
+				},
+				MaxAttempts: 5,
+			})
+			dispatcher.now = func() time.Time { return now }
+
+			delivered, err := dispatcher.DispatchPending(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCount, delivered)
+			assert.Equal(t, 1, received)
+		})
+	}
+}
+
+func TestDispatcher_DispatchPending_Unreachable(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repository := mock_domain.NewMockRepository(c)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	repository.EXPECT().ClaimWebhookDeliveries(gomock.Any(), DefaultBatchSize, DefaultLease).Return([]domain.WebhookDelivery{
+		{ID: 1, EventType: domain.EventTypeDeposit, Body: []byte(`{}`), URL: url, Secret: testSecret},
+	}, nil)
+	repository.EXPECT().RecordWebhookAttempt(gomock.Any(), gomock.Any(), domain.WebhookDeliveryStatusPending, gomock.Any()).
+		DoAndReturn(func(_ context.Context, attempt *domain.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+			assert.Nil(t, attempt.StatusCode)
+			assert.NotNil(t, attempt.Error)
+			return nil
+		})
+
+	dispatcher := NewDispatcher(repository, http.DefaultClient, DispatcherConfig{})
+
+	delivered, err := dispatcher.DispatchPending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+}
@@ -1,7 +0,0 @@
-CREATE DATABASE avito_test_go;
-\c avito_test_go
-
-CREATE TABLE users (
-    id INT PRIMARY KEY,
-    balance INT NOT NULL
-);